@@ -8,6 +8,7 @@ import { AuthProvider } from "./context/AuthContext"
 import Home from "./pages/Home"
 import Login from "./pages/Login"
 import Register from "./pages/Register"
+import ResetPassword from "./pages/ResetPassword"
 import Dashboard from "./pages/Dashboard"
 import Vote from "./pages/Vote"
 import Results from "./pages/Results"
@@ -21,6 +22,7 @@ function App() {
           <Route path="/" element={<Home />} />
           <Route path="/login" element={<Login />} />
           <Route path="/register" element={<Register />} />
+          <Route path="/reset-password" element={<ResetPassword />} />
           <Route path="/dashboard" element={<Dashboard />} />
           <Route path="/vote" element={<Vote />} />
           <Route path="/results" element={<Results />} />
@@ -127,6 +127,15 @@ const Login = () => {
           </form>
 
           <p className="mt-4 text-center text-md text-gray-600">
+            <Link
+              to="/reset-password"
+              className="text-blue-600 hover:underline font-semibold"
+            >
+              Forgot your password?
+            </Link>
+          </p>
+
+          <p className="mt-2 text-center text-md text-gray-600">
             Don't have an account?{" "}
             <Link
               to="/register"
@@ -39,7 +39,7 @@ const Register = () => {
 
       const data = await res.json();
       alert(
-        `Registration successful!\nYour username: ${data.username}\nYour password: ${data.password}`
+        `Registration successful!\n${data.message}.\nConfirm your email address before logging in.`
       );
       navigate("/login");
     } catch (err) {
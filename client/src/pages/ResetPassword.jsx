@@ -0,0 +1,132 @@
+import React, { useState } from "react";
+import { Link, useNavigate, useSearchParams } from "react-router-dom";
+import Navbar from "../components/Navbar";
+import Footer from "../components/Footer";
+
+// Without a token the voter asks for a reset link by email; the link brings
+// them back here with the token to choose a new password
+const ResetPassword = () => {
+  const [searchParams] = useSearchParams();
+  const token = searchParams.get("token");
+
+  const [formData, setFormData] = useState({
+    voterId: "",
+    email: "",
+    password: "",
+    confirm: "",
+  });
+  const [error, setError] = useState("");
+  const [notice, setNotice] = useState("");
+  const navigate = useNavigate();
+
+  const handleChange = (e) => {
+    setFormData((prev) => ({ ...prev, [e.target.name]: e.target.value }));
+  };
+
+  const post = async (endpoint, payload) => {
+    const res = await fetch(`http://localhost:8080${endpoint}`, {
+      method: "POST",
+      headers: {
+        "Content-Type": "application/json",
+      },
+      body: JSON.stringify(payload),
+    });
+    const data = await res.json();
+    if (!res.ok) {
+      throw new Error(data.error || "Request failed");
+    }
+    return data;
+  };
+
+  const handleSubmit = async (e) => {
+    e.preventDefault();
+    setError("");
+    setNotice("");
+
+    try {
+      if (!token) {
+        const { voterId, email } = formData;
+        const data = await post("/password-reset", { voterId, email });
+        setNotice(data.message);
+        return;
+      }
+      if (formData.password !== formData.confirm) {
+        throw new Error("Passwords do not match");
+      }
+      const data = await post("/password-reset/confirm", { token, password: formData.password });
+      alert(data.message);
+      navigate("/login");
+    } catch (err) {
+      setError(err.message);
+    }
+  };
+
+  const inputClass =
+    "w-full bg-gray-100 rounded-md px-4 py-2.5 placeholder-gray-400 focus:outline-none focus:ring-2 focus:ring-[#21978B]";
+  const field = (name, label, type, placeholder) => (
+    <div>
+      <label htmlFor={name} className="block mb-1 text-sm font-semibold text-gray-800">
+        {label}
+      </label>
+      <input
+        type={type}
+        id={name}
+        name={name}
+        placeholder={placeholder}
+        value={formData[name]}
+        onChange={handleChange}
+        required
+        className={inputClass}
+      />
+    </div>
+  );
+
+  return (
+    <div className="min-h-screen flex flex-col justify-between bg-[#F4F3F2]">
+      <Navbar />
+
+      <main className="flex flex-1 items-center justify-center px-6 py-6">
+        <div className="bg-white rounded-xl shadow-md px-6 py-4 w-full max-w-sm">
+          <h2 className="text-2xl font-extrabold text-gray-900 text-center mb-4">
+            {token ? "Choose a New Password" : "Reset Password"}
+          </h2>
+
+          <form onSubmit={handleSubmit} className="space-y-4">
+            {token ? (
+              <>
+                {field("password", "New Password", "password", "At least 8 characters")}
+                {field("confirm", "Confirm Password", "password", "")}
+              </>
+            ) : (
+              <>
+                {field("voterId", "Voter ID", "text", "e.g. V1234567")}
+                {field("email", "Email", "email", "abc@example.com")}
+              </>
+            )}
+
+            {error && <p className="text-red-600 text-sm">{error}</p>}
+            {notice && <p className="text-green-700 text-sm">{notice}</p>}
+
+            <button
+              type="submit"
+              className="w-full bg-[#21978B] text-white py-2.5 rounded-md font-semibold cursor-pointer"
+            >
+              {token ? "Set Password" : "Send Reset Link"}
+            </button>
+          </form>
+
+          <p className="mt-4 text-center text-md text-gray-600">
+            Remembered it?{" "}
+            <Link to="/login" className="text-blue-600 hover:underline font-semibold">
+              Login
+            </Link>
+          </p>
+        </div>
+      </main>
+
+      <Footer />
+    </div>
+  );
+};
+
+export default ResetPassword;
@@ -11,6 +11,7 @@ type Blockchain struct {
 	Chain     []Block `json:"chain"`
 	mutex     sync.RWMutex
 	listeners []chan Transaction
+	closed    bool
 }
 
 // BlockchainStats provides statistics about the blockchain
@@ -56,9 +57,10 @@ func CreateGenesisBlock() Block {
 	return genesis
 }
 
-// NewBlockchain creates a new blockchain instance
-func NewBlockchain() *Blockchain {
-	InitDB()
+// NewBlockchain creates a new blockchain instance stored in the database at
+// path
+func NewBlockchain(path string) *Blockchain {
+	InitDB(path)
 	blocks, err := LoadBlocks()
 	if err != nil || len(blocks) == 0 {
 		log.Println("Creating new blockchain with genesis block")
@@ -82,6 +84,10 @@ func (bc *Blockchain) AddBlock(transactions []Transaction) {
 	bc.mutex.Lock()
 	defer bc.mutex.Unlock()
 
+	if bc.closed {
+		log.Printf("Warning: blockchain is closed; %d transaction(s) not recorded", len(transactions))
+		return
+	}
 	if len(bc.Chain) == 0 {
 		log.Println("Warning: Empty blockchain, creating genesis block first")
 		genesis := CreateGenesisBlock()
@@ -93,7 +99,9 @@ func (bc *Blockchain) AddBlock(transactions []Transaction) {
 	newBlock := NewBlock(len(bc.Chain), lastBlock.Hash, transactions)
 
 	bc.Chain = append(bc.Chain, newBlock)
-	SaveBlock(newBlock)
+	if err := SaveBlock(newBlock); err != nil {
+		log.Printf("Failed to save block %d: %v", newBlock.Index, err)
+	}
 
 	// Notify listeners about new transactions
 	for _, tx := range transactions {
@@ -104,6 +112,16 @@ func (bc *Blockchain) AddBlock(transactions []Transaction) {
 		newBlock.Index, newBlock.Hash, newBlock.GetFormattedTimestamp(), len(transactions))
 }
 
+// Close waits for a block being added to be saved, then flushes and closes
+// the database. Transactions added afterwards are not recorded.
+func (bc *Blockchain) Close() error {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+
+	bc.closed = true
+	return CloseDB()
+}
+
 // AddTransaction adds a single transaction to the blockchain
 func (bc *Blockchain) AddTransaction(tx Transaction) {
 	bc.AddBlock([]Transaction{tx})
@@ -308,6 +326,25 @@ func (bc *Blockchain) GetTransactionByID(txID string) (*Transaction, error) {
 	return nil, nil
 }
 
+// LatestBlock returns the block at the head of the chain
+func (bc *Blockchain) LatestBlock() Block {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+
+	if len(bc.Chain) == 0 {
+		return Block{}
+	}
+	return bc.Chain[len(bc.Chain)-1]
+}
+
+// Blocks returns a copy of the chain's blocks
+func (bc *Blockchain) Blocks() []Block {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+
+	return append([]Block(nil), bc.Chain...)
+}
+
 // GetBlockByIndex returns a block by its index
 func (bc *Blockchain) GetBlockByIndex(index int) (*Block, error) {
 	bc.mutex.RLock()
@@ -2,6 +2,7 @@ package blockchain
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 
 	"go.etcd.io/bbolt"
@@ -11,10 +12,13 @@ var db *bbolt.DB
 
 const bucketName = "Blocks"
 
-// InitDB opens or creates the blockchain DB file.
-func InitDB() {
+// DBFile is the name of the chain database in the data directory
+const DBFile = "chain.db"
+
+// InitDB opens or creates the blockchain DB file at path.
+func InitDB(path string) {
 	var err error
-	db, err = bbolt.Open("chain.db", 0600, nil)
+	db, err = bbolt.Open(path, 0600, nil)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -26,9 +30,26 @@ func InitDB() {
 	})
 }
 
+// CloseDB flushes the DB file to disk and closes it. Blocks cannot be saved
+// afterwards.
+func CloseDB() error {
+	if db == nil {
+		return nil
+	}
+	err := db.Sync()
+	if closeErr := db.Close(); err == nil {
+		err = closeErr
+	}
+	db = nil
+	return err
+}
+
 // SaveBlock stores a block in the database.
 // It uses block index as the key.
 func SaveBlock(block Block) error {
+	if db == nil {
+		return errors.New("chain database is closed")
+	}
 	return db.Update(func(tx *bbolt.Tx) error {
 		b := tx.Bucket([]byte(bucketName))
 
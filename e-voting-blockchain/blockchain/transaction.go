@@ -10,22 +10,59 @@ import (
 type TransactionType string
 
 const (
-	TxTypeVote            TransactionType = "VOTE"
-	TxTypeVoterRegister   TransactionType = "VOTER_REGISTER"
-	TxTypeAddCandidate    TransactionType = "ADD_CANDIDATE"
-	TxTypeUpdateCandidate TransactionType = "UPDATE_CANDIDATE"
-	TxTypeDeleteCandidate TransactionType = "DELETE_CANDIDATE"
-	TxTypeAddParty        TransactionType = "ADD_PARTY"
-	TxTypeUpdateParty     TransactionType = "UPDATE_PARTY"
-	TxTypeDeleteParty     TransactionType = "DELETE_PARTY"
-	TxTypeStartElection   TransactionType = "START_ELECTION"
-	TxTypeStopElection    TransactionType = "STOP_ELECTION"
-	TxTypeDeleteVoter     TransactionType = "DELETE_VOTER"
-	TxTypeAdminLogin      TransactionType = "ADMIN_LOGIN"
-	TxTypeUserLogin       TransactionType = "USER_LOGIN"
-	TxTypeAddUser         TransactionType = "ADD_USER"
-	TxTypeUpdateUser      TransactionType = "UPDATE_USER"
-	TxTypeDeleteUser      TransactionType = "DELETE_USER"
+	TxTypeVote                TransactionType = "VOTE"
+	TxTypeVoterRegister       TransactionType = "VOTER_REGISTER"
+	TxTypeEmailVerified       TransactionType = "EMAIL_VERIFIED"
+	TxTypeAddCandidate        TransactionType = "ADD_CANDIDATE"
+	TxTypeUpdateCandidate     TransactionType = "UPDATE_CANDIDATE"
+	TxTypeDeleteCandidate     TransactionType = "DELETE_CANDIDATE"
+	TxTypeCandidateStatus     TransactionType = "CANDIDATE_STATUS"
+	TxTypeSubmitNomination    TransactionType = "SUBMIT_NOMINATION"
+	TxTypeReviewNomination    TransactionType = "REVIEW_NOMINATION"
+	TxTypeApproveNomination   TransactionType = "APPROVE_NOMINATION"
+	TxTypeRejectNomination    TransactionType = "REJECT_NOMINATION"
+	TxTypeNominationPolicy    TransactionType = "NOMINATION_POLICY"
+	TxTypeAddParty            TransactionType = "ADD_PARTY"
+	TxTypeUpdateParty         TransactionType = "UPDATE_PARTY"
+	TxTypeDeleteParty         TransactionType = "DELETE_PARTY"
+	TxTypeStartElection       TransactionType = "START_ELECTION"
+	TxTypeStopElection        TransactionType = "STOP_ELECTION"
+	TxTypeElectionState       TransactionType = "ELECTION_STATE"
+	TxTypePauseElection       TransactionType = "PAUSE_ELECTION"
+	TxTypeResumeElection      TransactionType = "RESUME_ELECTION"
+	TxTypeExtendElection      TransactionType = "EXTEND_ELECTION"
+	TxTypeCertifyResults      TransactionType = "CERTIFY_RESULTS"
+	TxTypeTiePolicy           TransactionType = "TIE_POLICY"
+	TxTypeTieResolution       TransactionType = "TIE_RESOLUTION"
+	TxTypeRunoffTriggered     TransactionType = "RUNOFF_TRIGGERED"
+	TxTypeRunoffCreated       TransactionType = "RUNOFF_CREATED"
+	TxTypeWriteInAdjudication TransactionType = "WRITE_IN_ADJUDICATION"
+	TxTypeProvisionalBallot   TransactionType = "PROVISIONAL_BALLOT"
+	TxTypeProvisionalDecision TransactionType = "PROVISIONAL_DECISION"
+	TxTypeDeleteVoter         TransactionType = "DELETE_VOTER"
+	TxTypeAdminLogin          TransactionType = "ADMIN_LOGIN"
+	TxTypeAddAdmin            TransactionType = "ADD_ADMIN"
+	TxTypeUpdateAdmin         TransactionType = "UPDATE_ADMIN"
+	TxTypeDeleteAdmin         TransactionType = "DELETE_ADMIN"
+	TxTypeUserLogin           TransactionType = "USER_LOGIN"
+	TxTypeClearLockout        TransactionType = "CLEAR_LOGIN_LOCKOUT"
+	TxTypeTwoFactorEnrolled   TransactionType = "TWO_FACTOR_ENROLLED"
+	TxTypeTwoFactorReset      TransactionType = "TWO_FACTOR_RESET"
+	TxTypeRecoveryCodeUsed    TransactionType = "RECOVERY_CODE_USED"
+	TxTypeAddUser             TransactionType = "ADD_USER"
+	TxTypeUpdateUser          TransactionType = "UPDATE_USER"
+	TxTypeDeleteUser          TransactionType = "DELETE_USER"
+	TxTypeAddQuestion         TransactionType = "ADD_QUESTION"
+	TxTypeDeleteQuestion      TransactionType = "DELETE_QUESTION"
+	TxTypeProposalCreated     TransactionType = "PROPOSAL_CREATED"
+	TxTypeProposalApproved    TransactionType = "PROPOSAL_APPROVED"
+	TxTypeProposalExecuted    TransactionType = "PROPOSAL_EXECUTED"
+	TxTypeProposalCancelled   TransactionType = "PROPOSAL_CANCELLED"
+	TxTypeProposalPolicy      TransactionType = "PROPOSAL_POLICY"
+
+	TxTypePasswordResetRequested TransactionType = "PASSWORD_RESET_REQUESTED"
+	TxTypePasswordReset          TransactionType = "PASSWORD_RESET"
+	TxTypePasswordChanged        TransactionType = "PASSWORD_CHANGED"
 )
 
 // TransactionData contains the actual transaction information
@@ -85,12 +122,32 @@ func (t Transaction) GetSummary() string {
 		return t.Data.Actor + " voted for " + t.Data.Target
 	case TxTypeVoterRegister:
 		return "New voter registered: " + t.Data.Target
+	case TxTypeEmailVerified:
+		return "Voter confirmed email address: " + t.Data.Target
+	case TxTypePasswordResetRequested:
+		return "Password reset requested for " + t.Data.Target
+	case TxTypePasswordReset:
+		return "Password reset by " + t.Data.Target
+	case TxTypePasswordChanged:
+		return "Password changed by " + t.Data.Target
 	case TxTypeAddCandidate:
 		return "Admin added candidate: " + t.Data.Target
 	case TxTypeUpdateCandidate:
 		return "Admin updated candidate: " + t.Data.Target
 	case TxTypeDeleteCandidate:
 		return "Admin deleted candidate: " + t.Data.Target
+	case TxTypeCandidateStatus:
+		return "Candidate status changed: " + t.Data.Target
+	case TxTypeSubmitNomination:
+		return "Nomination submitted: " + t.Data.Target
+	case TxTypeReviewNomination:
+		return "Nomination under review by " + t.Data.Actor + ": " + t.Data.Target
+	case TxTypeApproveNomination:
+		return "Nomination approved: " + t.Data.Target
+	case TxTypeRejectNomination:
+		return "Nomination rejected: " + t.Data.Target
+	case TxTypeNominationPolicy:
+		return "Nomination rules loaded from " + t.Data.Target
 	case TxTypeAddParty:
 		return "Admin added party: " + t.Data.Target
 	case TxTypeUpdateParty:
@@ -101,12 +158,52 @@ func (t Transaction) GetSummary() string {
 		return "Admin started election: " + t.Data.Action
 	case TxTypeStopElection:
 		return "Admin stopped election"
+	case TxTypePauseElection:
+		return "Admin paused voting: " + t.Data.Action
+	case TxTypeResumeElection:
+		return "Admin resumed voting"
+	case TxTypeExtendElection:
+		return "Admin extended voting"
+	case TxTypeCertifyResults:
+		return "Final results certified: " + t.Data.Target
+	case TxTypeTiePolicy:
+		return "Tie policy set: " + t.Data.Target
+	case TxTypeTieResolution:
+		return "Tie resolved in contest: " + t.Data.Target
+	case TxTypeRunoffTriggered:
+		return "Runoff required after round " + t.Data.Target
+	case TxTypeRunoffCreated:
+		return "Runoff round created: round " + t.Data.Target
+	case TxTypeWriteInAdjudication:
+		return "Write-in adjudicated: " + t.Data.Target
+	case TxTypeElectionState:
+		return "Election moved to " + t.Data.Target + " by " + t.Data.Actor
 	case TxTypeDeleteVoter:
 		return "Admin deleted registered voter: " + t.Data.Target
-	case TxTypeAdminLogin:
-		return "Admin logged in: " + t.Data.Actor
-	case TxTypeUserLogin:
-		return "User logged in: " + t.Data.Actor
+	case TxTypeAdminLogin, TxTypeUserLogin:
+		return t.Data.Action + ": " + t.Data.Actor
+	case TxTypeClearLockout:
+		return "Login lockout cleared for " + t.Data.Target + " by " + t.Data.Actor
+	case TxTypeTwoFactorEnrolled:
+		return "Two-factor authentication enabled for " + t.Data.Target
+	case TxTypeTwoFactorReset:
+		return "Two-factor authentication reset for " + t.Data.Target + " by " + t.Data.Actor
+	case TxTypeRecoveryCodeUsed:
+		return "Recovery code used by " + t.Data.Target
+	case TxTypeAddQuestion:
+		return "Admin added ballot question: " + t.Data.Target
+	case TxTypeDeleteQuestion:
+		return "Admin removed ballot question: " + t.Data.Target
+	case TxTypeProposalCreated:
+		return t.Data.Actor + " proposed " + t.Data.Action + ": " + t.Data.Target
+	case TxTypeProposalApproved:
+		return t.Data.Actor + " approved proposal " + t.Data.Target
+	case TxTypeProposalExecuted:
+		return "Approved proposal carried out: " + t.Data.Target
+	case TxTypeProposalCancelled:
+		return "Proposal withdrawn: " + t.Data.Target
+	case TxTypeProposalPolicy:
+		return "Proposal quorum changed: " + t.Data.Target
 	default:
 		return t.Data.Action
 	}
@@ -5,16 +5,25 @@ package main
 import (
 	"e-voting-blockchain/blockchain"
 	"e-voting-blockchain/contracts"
+	"e-voting-blockchain/internal/config"
 	"fmt"
 	"log"
+	"os"
 	"time"
 )
 
 func main() {
 	fmt.Println("🚀 Initializing test data with proper timestamps...")
 
+	// Write to the data directory the server uses
+	cfg, err := config.Load(os.Args[0], os.Args[1:])
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	storage := contracts.NewStorage(cfg.Storage.DataDir)
+
 	// Initialize blockchain
-	chain := blockchain.NewBlockchain()
+	chain := blockchain.NewBlockchain(storage.Path(blockchain.DBFile))
 
 	// Create some test transactions with proper timestamps
 	fmt.Println("📝 Creating test transactions...")
@@ -168,7 +177,7 @@ func main() {
 
 	// Initialize election data
 	fmt.Println("🗳️  Initializing election data...")
-	election := contracts.NewElection()
+	election := storage.NewElection()
 
 	// Add test parties
 	election.AddParty("PARTY001", "Democratic Party", "A test political party", "#0066CC")
@@ -3,7 +3,10 @@ package main
 import (
 	"e-voting-blockchain/blockchain"
 	"e-voting-blockchain/contracts"
+	"e-voting-blockchain/internal/config"
 	"fmt"
+	"log"
+	"os"
 	"strings"
 	"time"
 )
@@ -12,8 +15,16 @@ func main() {
 	fmt.Println("🔍 E-VOTING BLOCKCHAIN INSPECTOR")
 	// fmt.Println("=" * 50)
 
+	// Read the data directory the server uses
+	cfg, err := config.Load(os.Args[0], os.Args[1:])
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	storage := contracts.NewStorage(cfg.Storage.DataDir)
+	dbPath := storage.Path(blockchain.DBFile)
+
 	// Initialize and load blockchain
-	blockchain.InitDB()
+	blockchain.InitDB(dbPath)
 	blocks, err := blockchain.LoadBlocks()
 	if err != nil || len(blocks) == 0 {
 		fmt.Println("No blockchain data found.")
@@ -22,7 +33,7 @@ func main() {
 	}
 
 	// Create blockchain instance for integrity checking
-	chain := blockchain.NewBlockchain()
+	chain := blockchain.NewBlockchain(dbPath)
 
 	// Display blockchain overview
 	fmt.Printf("\n📊 BLOCKCHAIN OVERVIEW\n")
@@ -110,7 +121,7 @@ func main() {
 
 	// Load and display election state
 	fmt.Printf("\n🗳️  ELECTION STATE\n")
-	election, err := contracts.LoadElection()
+	election, err := storage.LoadElection()
 	if err != nil {
 		fmt.Printf("Error loading election data: %v\n", err)
 	} else {
@@ -20,15 +20,30 @@
 package main
 
 import (
+	"e-voting-blockchain/contracts"
+	"e-voting-blockchain/internal/config"
+	"errors"
+	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
 )
 
 func main() {
+	// Read the settings the server uses, so the same data directory is reset
+	cfg, err := config.Load(os.Args[0], os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Printf("❌ Failed to load configuration: %v\n", err)
+		os.Exit(1)
+	}
+	storage := contracts.NewStorage(cfg.Storage.DataDir)
+
 	fmt.Println("🧹 SYSTEM RESET UTILITY")
 	// fmt.Println("=" * 30)
-	fmt.Println("⚠️  WARNING: This will delete ALL data!")
+	fmt.Printf("⚠️  WARNING: This will delete ALL data in %s!\n", cfg.Storage.DataDir)
 	fmt.Println("Press Enter to continue or Ctrl+C to cancel...")
 
 	// Wait for user confirmation
@@ -51,9 +66,8 @@ func main() {
 		"voters.json",
 		"users.json",
 
-		// Admin data
+		// Admin data; the admin accounts in admin.json are kept
 		"admin_sessions.json",
-		"admin.json",
 
 		// Logs
 		"app.log",
@@ -65,6 +79,14 @@ func main() {
 		"backup.json",
 	}
 
+	for i, file := range filesToDelete {
+		filesToDelete[i] = storage.Path(file)
+	}
+
+	// Archived rounds of runoff elections and of earlier elections
+	rounds, _ := filepath.Glob(storage.Path("election*_round*.json"))
+	filesToDelete = append(filesToDelete, rounds...)
+
 	// Directories to clean
 	dirsToClean := []string{
 		"data",
@@ -72,9 +94,12 @@ func main() {
 		"temp",
 		"uploads",
 	}
+	for i, dir := range dirsToClean {
+		dirsToClean[i] = storage.Path(dir)
+	}
 
 	deletedFiles := 0
-	errors := 0
+	failures := 0
 
 	fmt.Println("\n🗑️  Deleting files...")
 
@@ -83,7 +108,7 @@ func main() {
 		if _, err := os.Stat(file); err == nil {
 			if err := os.Remove(file); err != nil {
 				fmt.Printf("❌ Failed to delete %s: %v\n", file, err)
-				errors++
+				failures++
 			} else {
 				fmt.Printf("✅ Deleted %s\n", file)
 				deletedFiles++
@@ -102,7 +127,7 @@ func main() {
 				if !info.IsDir() {
 					if err := os.Remove(path); err != nil {
 						fmt.Printf("❌ Failed to delete %s: %v\n", path, err)
-						errors++
+						failures++
 					} else {
 						fmt.Printf("✅ Deleted %s\n", path)
 						deletedFiles++
@@ -113,7 +138,7 @@ func main() {
 
 			if err != nil {
 				fmt.Printf("❌ Error cleaning directory %s: %v\n", dir, err)
-				errors++
+				failures++
 			}
 
 			// Try to remove the directory itself if it's empty
@@ -127,8 +152,8 @@ func main() {
 	fmt.Println("\n📊 RESET SUMMARY")
 	// fmt.Println("-" * 20)
 	fmt.Printf("✅ Files deleted: %d\n", deletedFiles)
-	if errors > 0 {
-		fmt.Printf("❌ Errors encountered: %d\n", errors)
+	if failures > 0 {
+		fmt.Printf("❌ Errors encountered: %d\n", failures)
 		fmt.Println("\n⚠️  Some files could not be deleted.")
 		fmt.Println("   This is normal if they don't exist or are in use.")
 	} else {
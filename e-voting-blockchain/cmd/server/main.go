@@ -1,16 +1,62 @@
 package main
 
 import (
+	"context"
+	"e-voting-blockchain/internal/config"
 	"e-voting-blockchain/internal/server" // Import the server with handlers
+	"errors"
+	"flag"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 )
 
 func main() {
-	// Setup all routes using internal/server/routes.go
-	r := server.SetupRoutes()
+	// Read the settings from the config file, .env, the environment and flags
+	cfg, err := config.Load(os.Args[0], os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	// Load the election, blockchain and accounts and set up the routes
+	srv, err := server.New(cfg)
+	if err != nil {
+		log.Fatalf("Failed to start server: %v", err)
+	}
+
+	// Stop on Ctrl-C or when the service manager asks
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	// Start the web server
-	log.Println("Server started on http://localhost:8080")
-	log.Fatal(http.ListenAndServe(":8080", r)) // Start listening for HTTP requests
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case <-ctx.Done():
+		log.Println("Shutting down...")
+		err = nil
+	case err = <-serveErr:
+		log.Printf("Server failed: %v", err)
+	}
+
+	// Let requests in progress finish, then close the data cleanly
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+	if shutdownErr := srv.Shutdown(shutdownCtx); shutdownErr != nil {
+		log.Printf("Shutdown incomplete: %v", shutdownErr)
+	}
+	log.Println("Server stopped")
+
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		cancel()
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,226 @@
+package contracts
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AdminAccountFile stores the admin accounts
+const AdminAccountFile = "admin.json"
+
+// AdminRole is what an admin account is trusted to do
+type AdminRole string
+
+const (
+	RoleSuperAdmin AdminRole = "super-admin" // Everything, including admin accounts
+	RoleOfficer    AdminRole = "officer"     // Runs the election and its ballot
+	RoleRegistrar  AdminRole = "registrar"   // Manages voters
+	RoleObserver   AdminRole = "observer"    // Read-only
+)
+
+// Permission is an action an admin route requires
+type Permission string
+
+const (
+	PermView         Permission = "view"          // Read election and audit data
+	PermManageBallot Permission = "manage_ballot" // Candidates, parties, questions and nominations
+	PermRunElection  Permission = "run_election"  // Open, close and count the election
+	PermManageVoters Permission = "manage_voters" // Voter records and registrations
+	PermManageAdmins Permission = "manage_admins" // Admin accounts and signing keys
+)
+
+var rolePermissions = map[AdminRole][]Permission{
+	RoleSuperAdmin: {PermView, PermManageBallot, PermRunElection, PermManageVoters, PermManageAdmins},
+	RoleOfficer:    {PermView, PermManageBallot, PermRunElection},
+	RoleRegistrar:  {PermView, PermManageVoters},
+	RoleObserver:   {PermView},
+}
+
+// ParseAdminRole validates a role name
+func ParseAdminRole(s string) (AdminRole, error) {
+	role := AdminRole(strings.ToLower(strings.TrimSpace(s)))
+	if _, ok := rolePermissions[role]; !ok {
+		return "", fmt.Errorf("unknown role %q; expected super-admin, officer, registrar or observer", s)
+	}
+	return role, nil
+}
+
+// Can reports whether the role grants a permission
+func (r AdminRole) Can(p Permission) bool {
+	for _, granted := range rolePermissions[r] {
+		if granted == p {
+			return true
+		}
+	}
+	return false
+}
+
+// Permissions lists the permissions the role grants
+func (r AdminRole) Permissions() []Permission {
+	return append([]Permission(nil), rolePermissions[r]...)
+}
+
+// AdminAccount is an admin's login. Password is an argon2id hash; a
+// plaintext value from an older setup is hashed on first start.
+type AdminAccount struct {
+	Username  string     `json:"username"`
+	Password  string     `json:"password"`
+	Role      AdminRole  `json:"role"`
+	Disabled  bool       `json:"disabled,omitempty"`
+	TwoFactor *TwoFactor `json:"twoFactor,omitempty"`
+	CreatedBy string     `json:"createdBy,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+	UpdatedAt time.Time  `json:"updatedAt,omitempty"`
+}
+
+// AdminStore holds the admin accounts keyed by username
+type AdminStore struct {
+	Accounts map[string]AdminAccount `json:"accounts"`
+
+	storage *Storage
+}
+
+// NewAdminStore starts an admin store with no accounts
+func (s *Storage) NewAdminStore() *AdminStore {
+	return &AdminStore{Accounts: make(map[string]AdminAccount), storage: s}
+}
+
+// LoadAdminStore reads the admin accounts. A file holding the single admin
+// account of an older setup is read as that account with the super-admin
+// role.
+func (s *Storage) LoadAdminStore() (*AdminStore, error) {
+	data, err := s.ReadFile(AdminAccountFile)
+	if err != nil {
+		return nil, err
+	}
+
+	store := &AdminStore{storage: s}
+	if err := json.Unmarshal(data, store); err != nil {
+		return nil, err
+	}
+	if store.Accounts == nil {
+		var legacy AdminAccount
+		if err := json.Unmarshal(data, &legacy); err != nil || legacy.Username == "" {
+			return nil, fmt.Errorf("invalid admin accounts in %s", AdminAccountFile)
+		}
+		legacy.Role = RoleSuperAdmin
+		store.Accounts = map[string]AdminAccount{legacy.Username: legacy}
+	}
+	return store, nil
+}
+
+// Save writes the admin accounts, readable only by the owner
+func (s *AdminStore) Save() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return s.storage.WriteFile(AdminAccountFile, data, 0600)
+}
+
+// GetAdmin returns an admin account
+func (s *AdminStore) GetAdmin(username string) (AdminAccount, bool) {
+	account, exists := s.Accounts[username]
+	return account, exists
+}
+
+// ListAdmins returns the admin accounts ordered by username
+func (s *AdminStore) ListAdmins() []AdminAccount {
+	list := make([]AdminAccount, 0, len(s.Accounts))
+	for _, account := range s.Accounts {
+		list = append(list, account)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Username < list[j].Username })
+	return list
+}
+
+// AddAdmin creates an admin account with an already hashed password
+func (s *AdminStore) AddAdmin(username, passwordHash string, role AdminRole, createdBy string, at time.Time) (AdminAccount, error) {
+	if s.Accounts == nil {
+		s.Accounts = make(map[string]AdminAccount)
+	}
+	username = strings.TrimSpace(username)
+	if username == "" {
+		return AdminAccount{}, errors.New("username is required")
+	}
+	if _, exists := s.Accounts[username]; exists {
+		return AdminAccount{}, errors.New("an admin with this username already exists")
+	}
+	account := AdminAccount{
+		Username:  username,
+		Password:  passwordHash,
+		Role:      role,
+		CreatedBy: createdBy,
+		CreatedAt: at,
+	}
+	s.Accounts[username] = account
+	return account, nil
+}
+
+// UpdateAdmin changes an account's role, disabled flag or password hash;
+// empty or nil values are left unchanged. The last active super-admin can
+// be neither demoted nor disabled.
+func (s *AdminStore) UpdateAdmin(username string, role AdminRole, disabled *bool, passwordHash string, at time.Time) (AdminAccount, error) {
+	account, exists := s.Accounts[username]
+	if !exists {
+		return AdminAccount{}, errors.New("admin not found")
+	}
+	demoted := role != "" && role != RoleSuperAdmin
+	if (demoted || (disabled != nil && *disabled)) && s.lastSuperAdmin(username) {
+		return AdminAccount{}, errors.New("cannot demote or disable the last active super-admin")
+	}
+	if role != "" {
+		account.Role = role
+	}
+	if disabled != nil {
+		account.Disabled = *disabled
+	}
+	if passwordHash != "" {
+		account.Password = passwordHash
+	}
+	account.UpdatedAt = at
+	s.Accounts[username] = account
+	return account, nil
+}
+
+// SetTwoFactor stores an account's TOTP enrolment; nil removes it
+func (s *AdminStore) SetTwoFactor(username string, tf *TwoFactor) error {
+	account, exists := s.Accounts[username]
+	if !exists {
+		return errors.New("admin not found")
+	}
+	account.TwoFactor = tf
+	s.Accounts[username] = account
+	return nil
+}
+
+// DeleteAdmin removes an admin account, other than the last active
+// super-admin
+func (s *AdminStore) DeleteAdmin(username string) error {
+	if _, exists := s.Accounts[username]; !exists {
+		return errors.New("admin not found")
+	}
+	if s.lastSuperAdmin(username) {
+		return errors.New("cannot delete the last active super-admin")
+	}
+	delete(s.Accounts, username)
+	return nil
+}
+
+// lastSuperAdmin reports whether the account is the only active super-admin
+func (s *AdminStore) lastSuperAdmin(username string) bool {
+	account := s.Accounts[username]
+	if account.Role != RoleSuperAdmin || account.Disabled {
+		return false
+	}
+	for name, other := range s.Accounts {
+		if name != username && other.Role == RoleSuperAdmin && !other.Disabled {
+			return false
+		}
+	}
+	return true
+}
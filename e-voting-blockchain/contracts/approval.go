@@ -0,0 +1,73 @@
+package contracts
+
+import "errors"
+
+// ApprovalRow is a candidate's line in an approval count
+type ApprovalRow struct {
+	CandidateID  string  `json:"candidateId"`
+	Approvals    int     `json:"approvals"`
+	ApprovalRate float64 `json:"approvalRate"` // Percentage of ballots approving the candidate
+}
+
+// approvalStrategy lets voters tick any number of candidates. The candidates
+// approved on the most ballots win.
+type approvalStrategy struct{}
+
+func (approvalStrategy) Type() BallotType { return BallotApproval }
+func (approvalStrategy) MultiSeat() bool  { return true }
+
+func (approvalStrategy) Validate(e *Election, b Ballot) error {
+	if len(b.Choices) == 0 {
+		return errors.New("approval ballot must approve at least one candidate")
+	}
+	if len(b.Scores) > 0 {
+		return errors.New("approval ballot cannot carry scores")
+	}
+	return e.validateCandidateIDs(b.Choices)
+}
+
+func (approvalStrategy) Record(e *Election, b Ballot) {
+	for _, id := range b.Choices {
+		addVotes(e, id, 1)
+	}
+}
+
+func (approvalStrategy) Count(e *Election) CountResult {
+	ballots := e.raceBallots()
+	ids := e.candidateIDs()
+	approvals := make(map[string]int, len(ids))
+	tallies := make(map[string]float64, len(ids))
+	for _, id := range ids {
+		approvals[id] = 0
+	}
+	for _, b := range ballots {
+		for _, id := range b.Choices {
+			if _, ok := approvals[id]; ok {
+				approvals[id]++
+			}
+		}
+	}
+	for id, n := range approvals {
+		tallies[id] = float64(n)
+	}
+
+	sortByTally(ids, tallies)
+	rows := make([]ApprovalRow, 0, len(ids))
+	for _, id := range ids {
+		rows = append(rows, ApprovalRow{
+			CandidateID:  id,
+			Approvals:    approvals[id],
+			ApprovalRate: percentage(tallies[id], float64(len(ballots))),
+		})
+	}
+
+	winners := topWinners(tallies, e.GetSeats())
+	return CountResult{
+		Method:       BallotApproval,
+		Seats:        e.GetSeats(),
+		TotalBallots: len(ballots),
+		Winners:      winners,
+		Rounds:       []CountRound{{Round: 1, Tallies: tallies, Elected: winners}},
+		Breakdown:    rows,
+	}
+}
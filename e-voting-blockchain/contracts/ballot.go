@@ -0,0 +1,264 @@
+package contracts
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// BallotType selects how ballots are filled in and counted
+type BallotType string
+
+const (
+	BallotSingleChoice       BallotType = "single"   // First-past-the-post, one candidate per ballot
+	BallotInstantRunoff      BallotType = "irv"      // Ranked ballot, single winner by instant runoff
+	BallotSingleTransferable BallotType = "stv"      // Ranked ballot, multi-seat by single transferable vote
+	BallotApproval           BallotType = "approval" // Tick any number of candidates
+	BallotScore              BallotType = "score"    // Rate each candidate from 0 to MaxScore
+)
+
+// DefaultMaxScore is the top of the rating scale for score ballots
+const DefaultMaxScore = 5
+
+// Ballot is a single cast ballot. Choices holds the selected candidates; for
+// ranked ballot types it is the voter's preference order, most preferred
+// first. Score ballots use Scores instead, and Special holds a special
+// option chosen in place of any candidate. WriteIn holds a normalised
+// write-in name given instead of a listed candidate. Answers maps each question
+// contest on the ballot to the chosen option. Sequence orders ballots as
+// they were cast; SupersededBy is set when a revision replaces the ballot.
+// Provisional names the provisional ballot an accepted ballot came from.
+type Ballot struct {
+	VoterID string            `json:"voterId"`
+	Choices []string          `json:"choices,omitempty"`
+	Scores  map[string]int    `json:"scores,omitempty"`
+	Special SpecialOption     `json:"special,omitempty"`
+	WriteIn string            `json:"writeIn,omitempty"`
+	Answers map[string]string `json:"answers,omitempty"`
+	CastAt  time.Time         `json:"castAt"`
+
+	Sequence     int `json:"sequence,omitempty"`
+	Supersedes   int `json:"supersedes,omitempty"`
+	SupersededBy int `json:"supersededBy,omitempty"`
+
+	Provisional string `json:"provisional,omitempty"`
+}
+
+// BallotConfig describes how an election's ballots are filled in and counted
+type BallotConfig struct {
+	Type     BallotType `json:"ballotType"`
+	Seats    int        `json:"seats,omitempty"`
+	MaxScore int        `json:"maxScore,omitempty"`
+
+	SpecialOptions []SpecialOption `json:"specialOptions,omitempty"`
+	AllowRevision  bool            `json:"allowRevision,omitempty"`
+	AllowWriteIns  bool            `json:"allowWriteIns,omitempty"`
+
+	TiePolicy         TiePolicy `json:"tiePolicy,omitempty"`
+	TieSeedCommitment string    `json:"tieSeedCommitment,omitempty"`
+}
+
+// IsRanked reports whether ballots of this type carry a preference order
+func (bt BallotType) IsRanked() bool {
+	return bt == BallotInstantRunoff || bt == BallotSingleTransferable
+}
+
+// ParseBallotType validates a ballot type string, defaulting to single choice
+func ParseBallotType(s string) (BallotType, error) {
+	if s == "" {
+		return BallotSingleChoice, nil
+	}
+	if _, err := StrategyFor(BallotType(s)); err != nil {
+		return "", err
+	}
+	return BallotType(s), nil
+}
+
+// GetBallotType returns the configured ballot type, treating elections saved
+// before ballot types existed as single choice
+func (e *Election) GetBallotType() BallotType {
+	if e.BallotType == "" {
+		return BallotSingleChoice
+	}
+	return e.BallotType
+}
+
+// GetSeats returns the number of seats to fill, at least one
+func (e *Election) GetSeats() int {
+	if e.Seats < 1 {
+		return 1
+	}
+	return e.Seats
+}
+
+// GetMaxScore returns the top of the rating scale for score ballots
+func (e *Election) GetMaxScore() int {
+	if e.MaxScore < 1 {
+		return DefaultMaxScore
+	}
+	return e.MaxScore
+}
+
+// GetBallotConfig returns the election's effective ballot configuration
+func (e *Election) GetBallotConfig() BallotConfig {
+	cfg := BallotConfig{Type: e.GetBallotType(), Seats: e.GetSeats(), SpecialOptions: e.SpecialOptions, AllowRevision: e.AllowRevision}
+	if cfg.Type == BallotScore {
+		cfg.MaxScore = e.GetMaxScore()
+	}
+	cfg.AllowWriteIns = e.AllowWriteIns
+	cfg.TiePolicy = e.GetTiePolicy()
+	cfg.TieSeedCommitment = e.TieSeedCommitment
+	return cfg
+}
+
+// Strategy returns the counting strategy for the election's ballot type
+func (e *Election) Strategy() CountingStrategy {
+	s, err := StrategyFor(e.GetBallotType())
+	if err != nil {
+		s, _ = StrategyFor(BallotSingleChoice)
+	}
+	return s
+}
+
+// ConfigureBallot sets the ballot type and its parameters. It can only be
+// changed while the election is being set up, before voting opens.
+func (e *Election) ConfigureBallot(cfg BallotConfig) error {
+	if !e.ballotEditable() {
+		return errors.New("cannot change ballot type once voting has opened")
+	}
+	strategy, err := StrategyFor(cfg.Type)
+	if err != nil {
+		return err
+	}
+	if cfg.Seats < 1 {
+		cfg.Seats = 1
+	}
+	if !strategy.MultiSeat() && cfg.Seats != 1 {
+		return fmt.Errorf("%s elections can only fill one seat", cfg.Type)
+	}
+	if cfg.MaxScore < 0 {
+		return errors.New("maximum score cannot be negative")
+	}
+
+	e.BallotType = cfg.Type
+	e.Seats = cfg.Seats
+	e.MaxScore = 0
+	if cfg.Type == BallotScore {
+		e.MaxScore = cfg.MaxScore
+	}
+	return nil
+}
+
+// validateCandidateIDs checks that every ID names a known candidate, once
+func (e *Election) validateCandidateIDs(ids []string) error {
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if _, ok := e.Candidates[id]; !ok {
+			return errors.New("invalid candidate")
+		}
+		if seen[id] {
+			return fmt.Errorf("candidate %s selected more than once", id)
+		}
+		seen[id] = true
+	}
+	return nil
+}
+
+// CastBallot validates a ballot and records it. The candidate race is
+// validated by the election's counting strategy, which also updates each
+// candidate's running Votes total so the candidate list stays meaningful
+// while voting is open. A special option such as NOTA takes the place of the
+// candidate choices and is kept out of the strategy, as is a write-in until
+// it is adjudicated. A voter may leave any
+// contest blank but must vote in at least one. When vote revision is on, a
+// voter who has already voted replaces their earlier ballot. The recorded
+// ballot is returned.
+func (e *Election) CastBallot(ballot Ballot) (Ballot, error) {
+	e.initializeMaps()
+
+	if e.State() == StatePaused {
+		return Ballot{}, ErrElectionPaused
+	}
+	if !e.IsElectionActive() {
+		return Ballot{}, errors.New("election is not active")
+	}
+	if e.Voters[ballot.VoterID] && !e.AllowRevision {
+		return Ballot{}, errors.New("voter has already voted")
+	}
+	if err := e.validateBallot(&ballot); err != nil {
+		return Ballot{}, err
+	}
+	return e.storeBallot(ballot, time.Now()), nil
+}
+
+// validateBallot checks a ballot's contents against the ballot's contests,
+// normalising its special option and write-in
+func (e *Election) validateBallot(ballot *Ballot) error {
+	normalizeSpecial(ballot)
+
+	if !ballot.votesInRace() && ballot.Special == "" && ballot.WriteIn == "" && len(ballot.Answers) == 0 {
+		return errors.New("ballot is empty")
+	}
+	if err := e.validateSpecial(*ballot); err != nil {
+		return err
+	}
+	if err := e.validateWriteIn(ballot); err != nil {
+		return err
+	}
+	if ballot.votesInRace() {
+		if err := e.Strategy().Validate(e, *ballot); err != nil {
+			return err
+		}
+		if err := e.validateSelectable(*ballot); err != nil {
+			return err
+		}
+	}
+	return e.validateAnswers(ballot.Answers)
+}
+
+// storeBallot records a validated ballot, marks the voter as having voted
+// and updates the running totals
+func (e *Election) storeBallot(ballot Ballot, now time.Time) Ballot {
+	if user, exists := e.Users[ballot.VoterID]; exists {
+		user.HasVoted = true
+		user.VotedAt = now
+		e.Users[ballot.VoterID] = user
+	}
+
+	ballot.Choices = append([]string(nil), ballot.Choices...)
+	ballot.CastAt = now
+	ballot.Sequence = len(e.Ballots) + 1
+	ballot.SupersededBy = 0
+	ballot.Supersedes = 0
+	revised := e.Voters[ballot.VoterID]
+	if revised {
+		ballot.Supersedes = e.supersedePrevious(ballot.VoterID, ballot.Sequence)
+	}
+	e.Ballots = append(e.Ballots, ballot)
+	e.Voters[ballot.VoterID] = true
+
+	if revised {
+		e.rebuildRunningTotals()
+	} else if counted := e.withWriteIn(ballot); counted.votesInRace() {
+		e.Strategy().Record(e, counted)
+	}
+	return ballot
+}
+
+// Count runs the counting strategy for the election's ballot type
+func (e *Election) Count() CountResult {
+	e.initializeMaps()
+	return e.Strategy().Count(e)
+}
+
+// candidateIDs returns the IDs of the candidates that take part in the
+// count, per their status and the withdrawn vote policy
+func (e *Election) candidateIDs() []string {
+	ids := make([]string, 0, len(e.Candidates))
+	for id, c := range e.Candidates {
+		if e.counted(c) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,188 @@
+package contracts
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// CandidateStatus is where a candidate stands in the election
+type CandidateStatus string
+
+const (
+	CandidateNominated    CandidateStatus = "nominated"    // Put forward, not yet on the ballot
+	CandidateApproved     CandidateStatus = "approved"     // On the ballot
+	CandidateWithdrawn    CandidateStatus = "withdrawn"    // Stood down; votes already cast are kept
+	CandidateDisqualified CandidateStatus = "disqualified" // Removed by the officials; never counted
+)
+
+// allowedCandidateStatus lists the legal next statuses for each status.
+// Reinstating a withdrawn or disqualified candidate is only possible before
+// voting opens.
+var allowedCandidateStatus = map[CandidateStatus][]CandidateStatus{
+	CandidateNominated:    {CandidateApproved, CandidateDisqualified},
+	CandidateApproved:     {CandidateWithdrawn, CandidateDisqualified},
+	CandidateWithdrawn:    {CandidateApproved, CandidateDisqualified},
+	CandidateDisqualified: {CandidateApproved},
+}
+
+// ErrCandidatesLocked is returned when candidates are added, edited or
+// deleted after voting has opened
+var ErrCandidatesLocked = errors.New("candidates are locked once voting has opened; withdraw or disqualify the candidate instead")
+
+// WithdrawnVotePolicy decides how votes for withdrawn candidates are counted
+type WithdrawnVotePolicy string
+
+const (
+	// WithdrawnVotesSeparate keeps withdrawn candidates out of the count and
+	// reports their votes beside it. Ranked ballots pass to later preferences.
+	WithdrawnVotesSeparate WithdrawnVotePolicy = "separate"
+	// WithdrawnVotesCount keeps withdrawn candidates in the count, as where
+	// the law leaves a withdrawn name on the ballot and able to win
+	WithdrawnVotesCount WithdrawnVotePolicy = "count"
+)
+
+// ExcludedCandidate is a candidate left out of the count, with the votes
+// recorded for them before they were excluded
+type ExcludedCandidate struct {
+	CandidateID string          `json:"candidateId"`
+	Name        string          `json:"name"`
+	Status      CandidateStatus `json:"status"`
+	Reason      string          `json:"reason,omitempty"`
+	Votes       int             `json:"votes"`
+}
+
+// ParseCandidateStatus validates a candidate status name
+func ParseCandidateStatus(s string) (CandidateStatus, error) {
+	status := CandidateStatus(s)
+	if _, ok := allowedCandidateStatus[status]; !ok {
+		return "", fmt.Errorf("unknown candidate status %q", s)
+	}
+	return status, nil
+}
+
+// ParseWithdrawnVotePolicy validates a withdrawn vote policy name
+func ParseWithdrawnVotePolicy(s string) (WithdrawnVotePolicy, error) {
+	switch p := WithdrawnVotePolicy(s); p {
+	case WithdrawnVotesSeparate, WithdrawnVotesCount:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown withdrawn vote policy %q", s)
+}
+
+// GetStatus returns the candidate's status. Candidates saved before statuses
+// existed were already on the ballot.
+func (c Candidate) GetStatus() CandidateStatus {
+	if c.Status == "" {
+		return CandidateApproved
+	}
+	return c.Status
+}
+
+// GetWithdrawnPolicy returns the configured withdrawn vote policy
+func (e *Election) GetWithdrawnPolicy() WithdrawnVotePolicy {
+	if e.WithdrawnPolicy == "" {
+		return WithdrawnVotesSeparate
+	}
+	return e.WithdrawnPolicy
+}
+
+// ConfigureWithdrawnPolicy sets the withdrawn vote policy, which is fixed
+// once voting opens
+func (e *Election) ConfigureWithdrawnPolicy(p WithdrawnVotePolicy) error {
+	if !e.ballotEditable() {
+		return errors.New("cannot change the withdrawn vote policy once voting has opened")
+	}
+	e.WithdrawnPolicy = p
+	return nil
+}
+
+// SetCandidateStatus moves a candidate to a new status. Withdrawal and
+// disqualification need a reason because both are recorded on the chain.
+func (e *Election) SetCandidateStatus(id string, status CandidateStatus, reason string, at time.Time) (Candidate, error) {
+	e.initializeMaps()
+
+	c, exists := e.Candidates[id]
+	if !exists {
+		return Candidate{}, errors.New("candidate not found")
+	}
+	from := c.GetStatus()
+	legal := false
+	for _, next := range allowedCandidateStatus[from] {
+		if next == status {
+			legal = true
+			break
+		}
+	}
+	if !legal {
+		return Candidate{}, fmt.Errorf("cannot move candidate from %s to %s", from, status)
+	}
+	if status == CandidateApproved && !e.ballotEditable() {
+		return Candidate{}, errors.New("cannot put a candidate on the ballot once voting has opened")
+	}
+	if reason == "" && (status == CandidateWithdrawn || status == CandidateDisqualified) {
+		return Candidate{}, fmt.Errorf("a reason is required to mark a candidate %s", status)
+	}
+
+	c.Status = status
+	c.StatusReason = reason
+	c.StatusChangedAt = at
+	e.Candidates[id] = c
+	return c, nil
+}
+
+// counted reports whether a candidate takes part in the count
+func (e *Election) counted(c Candidate) bool {
+	switch c.GetStatus() {
+	case CandidateApproved:
+		return true
+	case CandidateWithdrawn:
+		return e.GetWithdrawnPolicy() == WithdrawnVotesCount
+	}
+	return false
+}
+
+// validateSelectable checks that a ballot only selects candidates who are on
+// the ballot now; votes for a candidate stop once they withdraw
+func (e *Election) validateSelectable(b Ballot) error {
+	ids := append([]string(nil), b.Choices...)
+	for id := range b.Scores {
+		ids = append(ids, id)
+	}
+	for _, id := range ids {
+		if status := e.Candidates[id].GetStatus(); status != CandidateApproved {
+			return fmt.Errorf("candidate %s is %s", id, status)
+		}
+	}
+	return nil
+}
+
+// ExcludedCandidates lists the withdrawn and disqualified candidates left out
+// of the count, with the votes recorded for them
+func (e *Election) ExcludedCandidates() []ExcludedCandidate {
+	excluded := []ExcludedCandidate{}
+	for id, c := range e.Candidates {
+		if e.counted(c) || c.GetStatus() == CandidateNominated {
+			continue
+		}
+		excluded = append(excluded, ExcludedCandidate{
+			CandidateID: id,
+			Name:        c.Name,
+			Status:      c.GetStatus(),
+			Reason:      c.StatusReason,
+			Votes:       c.Votes,
+		})
+	}
+	sort.Slice(excluded, func(i, j int) bool { return excluded[i].CandidateID < excluded[j].CandidateID })
+	return excluded
+}
+
+// ensureCandidatesEditable rejects adding, editing or deleting candidates
+// once voting has opened; withdrawal and disqualification are used instead
+func (e *Election) ensureCandidatesEditable() error {
+	if !e.ballotEditable() {
+		return ErrCandidatesLocked
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package contracts
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ResultDocument is the final result of a closed election. Its hash is what
+// gets signed and anchored on the chain.
+type ResultDocument struct {
+	Description      string              `json:"description"`
+	StartTime        time.Time           `json:"startTime"`
+	EndTime          time.Time           `json:"endTime"`
+	ClosedAt         time.Time           `json:"closedAt"`
+	Ballot           BallotConfig        `json:"ballot"`
+	BallotsCast      int                 `json:"ballotsCast"`
+	RegisteredVoters int                 `json:"registeredVoters"`
+	Superseded       int                 `json:"supersededBallots,omitempty"` // Ballots replaced by a revision, kept for audit
+	Provisional      *ProvisionalSummary `json:"provisional,omitempty"`
+	Contests         []ContestResult     `json:"contests"`
+	ChainHeight      int                 `json:"chainHeight"`   // Blocks on the chain when the document was made
+	ChainHeadHash    string              `json:"chainHeadHash"` // Hash of the last of those blocks
+	GeneratedAt      time.Time           `json:"generatedAt"`
+}
+
+// CertifiedResult is a signed result document together with the chain
+// transaction that anchors it
+type CertifiedResult struct {
+	Document      ResultDocument `json:"document"`
+	DocumentHash  string         `json:"documentHash"` // SHA-256 of the document's JSON encoding
+	Signature     string         `json:"signature"`    // Base64 Ed25519 signature of DocumentHash
+	PublicKey     string         `json:"publicKey"`    // Base64 Ed25519 key to verify Signature
+	TransactionID string         `json:"transactionId"`
+	CertifiedAt   time.Time      `json:"certifiedAt"`
+}
+
+// BuildResultDocument assembles the final result of a closed election,
+// anchored to the given chain head
+func (e *Election) BuildResultDocument(chainHeight int, chainHeadHash string, at time.Time) (ResultDocument, error) {
+	if !e.PollsClosed() {
+		return ResultDocument{}, errors.New("results can only be certified after polls close")
+	}
+
+	registered := 0
+	if users, err := e.storage.LoadRegisteredUsers(); err == nil {
+		registered = len(users)
+	}
+
+	var provisional *ProvisionalSummary
+	if counts := e.ProvisionalCounts(); counts.Submitted > 0 {
+		provisional = &counts
+	}
+
+	return ResultDocument{
+		Description:      e.Status.Description,
+		StartTime:        e.Status.StartTime,
+		EndTime:          e.Status.EndTime,
+		ClosedAt:         e.Status.ClosedAt,
+		Ballot:           e.GetBallotConfig(),
+		BallotsCast:      len(e.Voters),
+		RegisteredVoters: registered,
+		Superseded:       e.SupersededBallots(),
+		Provisional:      provisional,
+		Contests:         e.ContestResults(),
+		ChainHeight:      chainHeight,
+		ChainHeadHash:    chainHeadHash,
+		GeneratedAt:      at,
+	}, nil
+}
+
+// Hash returns the hex SHA-256 of the document's JSON encoding
+func (d ResultDocument) Hash() (string, error) {
+	data, err := json.Marshal(d)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(data)), nil
+}
+
+// RecordCertification stores the certified result of a tallied election and
+// moves it to certified
+func (e *Election) RecordCertification(cert CertifiedResult) (StateTransition, error) {
+	if e.State() != StateTallied {
+		return StateTransition{}, errors.New("only a tallied election can be certified")
+	}
+	t, err := e.transition(StateCertified, "final results certified", cert.CertifiedAt)
+	if err != nil {
+		return StateTransition{}, err
+	}
+	e.Certification = &cert
+	return t, nil
+}
@@ -0,0 +1,284 @@
+package contracts
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ContestKind distinguishes the contests that can appear on one ballot
+type ContestKind string
+
+const (
+	ContestCandidateRace ContestKind = "candidate_race"
+	ContestQuestion      ContestKind = "question"
+)
+
+// CandidateRaceID is the contest ID of the election's candidate race
+const CandidateRaceID = "candidates"
+
+// QuestionOption is one of the fixed answers to a question
+type QuestionOption struct {
+	ID    string `json:"id"`
+	Label string `json:"label"`
+}
+
+// Question is a referendum or other fixed-option contest on the ballot
+type Question struct {
+	ID          string           `json:"id"`
+	Title       string           `json:"title"`
+	Description string           `json:"description,omitempty"`
+	Options     []QuestionOption `json:"options"`
+}
+
+// OptionResult is the count for one answer to a question
+type OptionResult struct {
+	OptionID string  `json:"optionId"`
+	Label    string  `json:"label"`
+	Votes    int     `json:"votes"`
+	Share    float64 `json:"share"` // Percentage of ballots answering the question
+}
+
+// ContestResult is the separately tallied result of one contest
+type ContestResult struct {
+	ContestID string         `json:"contestId"`
+	Title     string         `json:"title"`
+	Kind      ContestKind    `json:"kind"`
+	Ballots   int            `json:"ballots"` // Ballots that voted in this contest
+	Blank     int            `json:"blank"`   // Ballots that left this contest empty
+	Count     *CountResult   `json:"count,omitempty"`
+	Options   []OptionResult `json:"options,omitempty"`
+	Outcome   string         `json:"outcome,omitempty"` // Winning option of a question, empty on an unresolved tie
+
+	// Seats and Winners are the contest's outcome: the candidates or option
+	// elected, including those decided by a tie resolution. Tie reports a
+	// tie for the last seats and how, if at all, it was resolved.
+	Seats   int         `json:"seats"`
+	Winners []string    `json:"winners"`
+	Tie     *ContestTie `json:"tie,omitempty"`
+	// Runoff lists the candidates going through to a runoff when the race
+	// produced no winner for its last seats
+	Runoff []string `json:"runoff,omitempty"`
+
+	// Special holds the NOTA and abstention counts of the candidate race.
+	// They are not part of Ballots or Count and never decide a winner.
+	Special []SpecialOptionResult `json:"specialOptions,omitempty"`
+	// Excluded lists withdrawn and disqualified candidates left out of the
+	// count, with the votes they had received
+	Excluded []ExcludedCandidate `json:"excludedCandidates,omitempty"`
+	// WriteIns summarises write-in ballots; mapped ones are in Count
+	WriteIns *WriteInTally `json:"writeIns,omitempty"`
+}
+
+// defaultQuestionOptions are used when a question is added without options
+var defaultQuestionOptions = []QuestionOption{
+	{ID: "yes", Label: "Yes"},
+	{ID: "no", Label: "No"},
+}
+
+// AddQuestion adds a question contest to the ballot. Questions with no
+// options are yes/no questions. The ballot is fixed once voting starts.
+func (e *Election) AddQuestion(id, title, description string, options []QuestionOption) error {
+	e.initializeMaps()
+
+	if err := e.ensureBallotEditable(); err != nil {
+		return err
+	}
+	if id == "" || title == "" {
+		return errors.New("question ID and title are required")
+	}
+	if id == CandidateRaceID {
+		return fmt.Errorf("%q is reserved for the candidate race", id)
+	}
+	if _, exists := e.Questions[id]; exists {
+		return errors.New("question with that ID already exists")
+	}
+
+	if len(options) == 0 {
+		options = defaultQuestionOptions
+	}
+	if len(options) < 2 {
+		return errors.New("a question needs at least two options")
+	}
+	seen := make(map[string]bool, len(options))
+	for _, opt := range options {
+		if opt.ID == "" || opt.Label == "" {
+			return errors.New("every option needs an ID and a label")
+		}
+		if seen[opt.ID] {
+			return fmt.Errorf("option %s listed more than once", opt.ID)
+		}
+		seen[opt.ID] = true
+	}
+
+	e.Questions[id] = Question{
+		ID:          id,
+		Title:       title,
+		Description: description,
+		Options:     append([]QuestionOption(nil), options...),
+	}
+	return nil
+}
+
+// RemoveQuestion removes a question contest before voting starts
+func (e *Election) RemoveQuestion(id string) error {
+	e.initializeMaps()
+
+	if err := e.ensureBallotEditable(); err != nil {
+		return err
+	}
+	if _, exists := e.Questions[id]; !exists {
+		return errors.New("question not found")
+	}
+	delete(e.Questions, id)
+	return nil
+}
+
+// GetQuestion returns a question contest by ID
+func (e *Election) GetQuestion(id string) (Question, error) {
+	e.initializeMaps()
+
+	q, exists := e.Questions[id]
+	if !exists {
+		return Question{}, errors.New("question not found")
+	}
+	return q, nil
+}
+
+// ListQuestions returns the question contests ordered by ID
+func (e *Election) ListQuestions() []Question {
+	e.initializeMaps()
+
+	list := make([]Question, 0, len(e.Questions))
+	for _, q := range e.Questions {
+		list = append(list, q)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+// ensureBallotEditable rejects changes to the ballot's contests once voting
+// has started
+func (e *Election) ensureBallotEditable() error {
+	if !e.ballotEditable() {
+		return errors.New("cannot change ballot contests once voting has opened")
+	}
+	return nil
+}
+
+// validateAnswers checks each answer names a known question and option
+func (e *Election) validateAnswers(answers map[string]string) error {
+	for questionID, optionID := range answers {
+		q, exists := e.Questions[questionID]
+		if !exists {
+			return fmt.Errorf("invalid question %s", questionID)
+		}
+		valid := false
+		for _, opt := range q.Options {
+			if opt.ID == optionID {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return fmt.Errorf("invalid option %s for question %s", optionID, questionID)
+		}
+	}
+	return nil
+}
+
+// votesInRace reports whether a ballot takes part in the candidate race
+func (b Ballot) votesInRace() bool {
+	return len(b.Choices) > 0 || len(b.Scores) > 0
+}
+
+// raceBallots returns the counted ballots that take part in the candidate race
+func (e *Election) raceBallots() []Ballot {
+	ballots := make([]Ballot, 0, len(e.Ballots))
+	for _, b := range e.countedBallots() {
+		if b.votesInRace() {
+			ballots = append(ballots, b)
+		}
+	}
+	return ballots
+}
+
+// ContestResults tallies every contest on the ballot separately: the
+// candidate race with the election's counting strategy, then each question
+func (e *Election) ContestResults() []ContestResult {
+	e.initializeMaps()
+
+	results := make([]ContestResult, 0, len(e.Questions)+1)
+	if len(e.Candidates) > 0 {
+		count := e.Count()
+		race := ContestResult{
+			ContestID: CandidateRaceID,
+			Title:     e.Status.Description,
+			Kind:      ContestCandidateRace,
+			Ballots:   count.TotalBallots,
+			Count:     &count,
+			Special:   e.SpecialOptionResults(),
+			Excluded:  e.ExcludedCandidates(),
+			WriteIns:  e.WriteInResults(),
+		}
+		for _, b := range e.countedBallots() {
+			if !b.votesInRace() && b.Special == "" && b.WriteIn == "" {
+				race.Blank++
+			}
+		}
+		var final map[string]float64
+		if len(count.Rounds) > 0 {
+			final = count.Rounds[len(count.Rounds)-1].Tallies
+		}
+		if count.Tie != nil || !e.applyRunoffRule(&race, final, count.Seats) {
+			e.decideWinners(&race, final, count.Winners, count.Seats)
+		}
+		results = append(results, race)
+	}
+
+	for _, q := range e.ListQuestions() {
+		results = append(results, e.tallyQuestion(q))
+	}
+	return results
+}
+
+// tallyQuestion counts the answers given to one question
+func (e *Election) tallyQuestion(q Question) ContestResult {
+	ballots := e.countedBallots()
+	votes := make(map[string]int, len(q.Options))
+	answered := 0
+	for _, b := range ballots {
+		if optionID, ok := b.Answers[q.ID]; ok {
+			votes[optionID]++
+			answered++
+		}
+	}
+
+	result := ContestResult{
+		ContestID: q.ID,
+		Title:     q.Title,
+		Kind:      ContestQuestion,
+		Ballots:   answered,
+		Blank:     len(ballots) - answered,
+		Options:   make([]OptionResult, 0, len(q.Options)),
+	}
+	tallies := make(map[string]float64, len(q.Options))
+	for _, opt := range q.Options {
+		tallies[opt.ID] = float64(votes[opt.ID])
+		result.Options = append(result.Options, OptionResult{
+			OptionID: opt.ID,
+			Label:    opt.Label,
+			Votes:    votes[opt.ID],
+			Share:    percentage(float64(votes[opt.ID]), float64(answered)),
+		})
+	}
+	var winners []string
+	if winner, ok := leader(tallies); ok {
+		winners = []string{winner}
+	}
+	e.decideWinners(&result, tallies, winners, 1)
+	if len(result.Winners) == 1 {
+		result.Outcome = result.Winners[0]
+	}
+	return result
+}
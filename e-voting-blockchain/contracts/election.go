@@ -3,7 +3,7 @@ package contracts
 import (
 	"encoding/json"
 	"errors"
-	"os"
+	"strings"
 	"time"
 )
 
@@ -24,7 +24,12 @@ type Candidate struct {
 	PartyName   string `json:"partyName"` // Denormalized for easy access
 	Age         int    `json:"age"`
 	ImageURL    string `json:"imageUrl,omitempty"`
-	Votes       int    `json:"votes"`
+	Votes       int    `json:"votes"` // Running total kept by the election's counting strategy
+	NomineeID   string `json:"nomineeId,omitempty"`
+
+	Status          CandidateStatus `json:"status,omitempty"`
+	StatusReason    string          `json:"statusReason,omitempty"`
+	StatusChangedAt time.Time       `json:"statusChangedAt,omitempty"`
 }
 
 // User represents a registered voter
@@ -38,12 +43,18 @@ type User struct {
 	VotedAt  time.Time `json:"votedAt,omitempty"`
 }
 
-// ElectionStatus represents the current state of the election
+// ElectionStatus represents the current state of the election. IsActive is
+// kept in step with State for clients that predate the lifecycle states.
 type ElectionStatus struct {
-	IsActive    bool      `json:"isActive"`
-	StartTime   time.Time `json:"startTime"`
-	EndTime     time.Time `json:"endTime"`
-	Description string    `json:"description"`
+	IsActive       bool          `json:"isActive"`
+	State          ElectionState `json:"state"`
+	StateChangedAt time.Time     `json:"stateChangedAt,omitempty"`
+	StartTime      time.Time     `json:"startTime"`
+	EndTime        time.Time     `json:"endTime"` // Effective end, including pauses and extensions
+	ClosedAt       time.Time     `json:"closedAt,omitempty"`
+	Description    string        `json:"description"`
+	Suspensions    []Suspension  `json:"suspensions,omitempty"`
+	Extensions     []Extension   `json:"extensions,omitempty"`
 }
 
 // Election with enhanced structure
@@ -53,6 +64,43 @@ type Election struct {
 	Users      map[string]User      `json:"users"`
 	Parties    map[string]Party     `json:"parties"`
 	Status     ElectionStatus       `json:"status"`
+	BallotType BallotType           `json:"ballotType,omitempty"`
+	Seats      int                  `json:"seats,omitempty"`
+	MaxScore   int                  `json:"maxScore,omitempty"`
+	Questions  map[string]Question  `json:"questions,omitempty"`
+	Ballots    []Ballot             `json:"ballots,omitempty"`
+
+	SpecialOptions []SpecialOption `json:"specialOptions,omitempty"`
+	AllowRevision  bool            `json:"allowRevision,omitempty"`
+
+	AllowWriteIns bool                           `json:"allowWriteIns,omitempty"`
+	WriteIns      map[string]WriteInAdjudication `json:"writeIns,omitempty"` // Decisions keyed by normalised spelling
+
+	Provisionals map[string]ProvisionalBallot `json:"provisionals,omitempty"` // Sealed ballots from challenged voters
+
+	WithdrawnPolicy WithdrawnVotePolicy `json:"withdrawnPolicy,omitempty"`
+
+	TiePolicy         TiePolicy                `json:"tiePolicy,omitempty"`
+	TieSeed           string                   `json:"tieSeed,omitempty"` // Secret until a lot is drawn
+	TieSeedCommitment string                   `json:"tieSeedCommitment,omitempty"`
+	TieResolutions    map[string]TieResolution `json:"tieResolutions,omitempty"`
+
+	Round         int         `json:"round,omitempty"`
+	Runoff        *RunoffRule `json:"runoff,omitempty"`
+	PreviousRound *RoundLink  `json:"previousRound,omitempty"`
+	NextRound     *RoundLink  `json:"nextRound,omitempty"`
+
+	Number           int           `json:"number,omitempty"` // Of the elections held on this server
+	PreviousElection *ElectionLink `json:"previousElection,omitempty"`
+
+	NominationRules *NominationRules      `json:"nominationRules,omitempty"`
+	Nominations     map[string]Nomination `json:"nominations,omitempty"`
+
+	ResultVisibility ResultVisibility `json:"resultVisibility,omitempty"`
+	Certification    *CertifiedResult `json:"certification,omitempty"`
+
+	// storage is where the election is saved
+	storage *Storage
 }
 
 // NewElection creates an empty election
@@ -62,10 +110,14 @@ func NewElection() *Election {
 		Voters:     make(map[string]bool),
 		Users:      make(map[string]User),
 		Parties:    make(map[string]Party),
+		Questions:  make(map[string]Question),
 		Status: ElectionStatus{
 			IsActive:    false,
+			State:       StateDraft,
 			Description: "Election not started",
 		},
+		BallotType: BallotSingleChoice,
+		Seats:      1,
 	}
 }
 
@@ -83,6 +135,15 @@ func (e *Election) initializeMaps() {
 	if e.Parties == nil {
 		e.Parties = make(map[string]Party)
 	}
+	if e.Questions == nil {
+		e.Questions = make(map[string]Question)
+	}
+	if e.Nominations == nil {
+		e.Nominations = make(map[string]Nomination)
+	}
+	if e.Provisionals == nil {
+		e.Provisionals = make(map[string]ProvisionalBallot)
+	}
 }
 
 // Party Management Methods
@@ -143,47 +204,13 @@ func (e *Election) ListParties() []Party {
 	return parties
 }
 
-// Election Management Methods
-func (e *Election) StartElection(description string, duration time.Duration) error {
-	if e.Status.IsActive {
-		return errors.New("election is already active")
-	}
-	now := time.Now()
-	e.Status = ElectionStatus{
-		IsActive:    true,
-		StartTime:   now,
-		EndTime:     now.Add(duration),
-		Description: description,
-	}
-	return nil
-}
-
-func (e *Election) StopElection() error {
-	if !e.Status.IsActive {
-		return errors.New("election is not active")
-	}
-	e.Status.IsActive = false
-	e.Status.Description = "Election ended"
-	return nil
-}
-
-func (e *Election) IsElectionActive() bool {
-	if !e.Status.IsActive {
-		return false
-	}
-	// Check if election time has expired
-	if time.Now().After(e.Status.EndTime) {
-		e.Status.IsActive = false
-		e.Status.Description = "Election time expired"
-		return false
-	}
-	return true
-}
-
 // Enhanced Candidate Methods
 func (e *Election) AddCandidate(id, name, bio, partyID string, age int, imageURL string) error {
 	e.initializeMaps()
 
+	if err := e.ensureCandidatesEditable(); err != nil {
+		return err
+	}
 	if _, exists := e.Candidates[id]; exists {
 		return errors.New("candidate with that ID already exists")
 	}
@@ -196,6 +223,11 @@ func (e *Election) AddCandidate(id, name, bio, partyID string, age int, imageURL
 			return errors.New("invalid party ID")
 		}
 	}
+	// Candidates added directly skip review but not the nomination rules
+	n := Nomination{CandidateID: id, Name: name, Bio: bio, PartyID: partyID, Age: age, ImageURL: imageURL}
+	if violations := e.checkNomination(n); len(violations) > 0 {
+		return errors.New(strings.Join(violations, "; "))
+	}
 	e.Candidates[id] = Candidate{
 		CandidateID: id,
 		Name:        name,
@@ -205,6 +237,7 @@ func (e *Election) AddCandidate(id, name, bio, partyID string, age int, imageURL
 		Age:         age,
 		ImageURL:    imageURL,
 		Votes:       0,
+		Status:      CandidateApproved,
 	}
 	return nil
 }
@@ -212,6 +245,9 @@ func (e *Election) AddCandidate(id, name, bio, partyID string, age int, imageURL
 func (e *Election) UpdateCandidate(id, name, bio, partyID string, age int, imageURL string) error {
 	e.initializeMaps()
 
+	if err := e.ensureCandidatesEditable(); err != nil {
+		return err
+	}
 	candidate, exists := e.Candidates[id]
 	if !exists {
 		return errors.New("candidate not found")
@@ -225,6 +261,10 @@ func (e *Election) UpdateCandidate(id, name, bio, partyID string, age int, image
 			return errors.New("invalid party ID")
 		}
 	}
+	n := Nomination{CandidateID: id, NomineeID: candidate.NomineeID, Name: name, Bio: bio, PartyID: partyID, Age: age, ImageURL: imageURL}
+	if violations := e.checkNomination(n); len(violations) > 0 {
+		return errors.New(strings.Join(violations, "; "))
+	}
 	candidate.Name = name
 	candidate.Bio = bio
 	candidate.PartyID = partyID
@@ -239,11 +279,10 @@ func (e *Election) UpdateCandidate(id, name, bio, partyID string, age int, image
 func (e *Election) GetStatistics() map[string]interface{} {
 	e.initializeMaps()
 
-	totalVotes := 0
+	// Candidate totals are approvals or scores for some ballot types, so
+	// count ballots cast rather than summing them
+	totalVotes := len(e.Voters)
 	votedUsers := 0
-	for _, candidate := range e.Candidates {
-		totalVotes += candidate.Votes
-	}
 	for _, user := range e.Users {
 		if user.HasVoted {
 			votedUsers++
@@ -252,52 +291,37 @@ func (e *Election) GetStatistics() map[string]interface{} {
 
 	// Get count of registered users from the file
 	registeredUsersCount := 0
-	if registeredUsers, err := LoadRegisteredUsers(); err == nil {
+	if registeredUsers, err := e.storage.LoadRegisteredUsers(); err == nil {
 		registeredUsersCount = len(registeredUsers)
 	}
 
 	return map[string]interface{}{
 		"totalCandidates": len(e.Candidates),
 		"totalParties":    len(e.Parties),
+		"totalQuestions":  len(e.Questions),
 		"totalUsers":      len(e.Users),
 		"totalVoters":     len(e.Voters),
 		"totalVotes":      totalVotes,
+		"totalBallots":    len(e.Ballots),
+		"superseded":      e.SupersededBallots(),
+		"provisional":     e.ProvisionalCounts(),
 		"votedUsers":      votedUsers,
 		"pendingVoters":   len(e.Users) - votedUsers,
 		"registeredUsers": registeredUsersCount, // Count from registered_voters.json
 		"electionStatus":  e.Status,
+		"ballot":          e.GetBallotConfig(),
 	}
 }
 
 // GetRegisteredVoters returns the full slice of registered users from disk.
 func (e *Election) GetRegisteredVoters() ([]RegisteredUser, error) {
-	return LoadRegisteredUsers()
+	return e.storage.LoadRegisteredUsers()
 }
 
-// Vote method with election status check
+// Vote casts a single-choice ballot for one candidate
 func (e *Election) Vote(voterID, candidateID string) error {
-	e.initializeMaps()
-
-	if !e.IsElectionActive() {
-		return errors.New("election is not active")
-	}
-	if e.Voters[voterID] {
-		return errors.New("voter has already voted")
-	}
-	candidate, ok := e.Candidates[candidateID]
-	if !ok {
-		return errors.New("invalid candidate")
-	}
-	// Update user voting status
-	if user, exists := e.Users[voterID]; exists {
-		user.HasVoted = true
-		user.VotedAt = time.Now()
-		e.Users[voterID] = user
-	}
-	candidate.Votes++
-	e.Candidates[candidateID] = candidate
-	e.Voters[voterID] = true
-	return nil
+	_, err := e.CastBallot(Ballot{VoterID: voterID, Choices: []string{candidateID}})
+	return err
 }
 
 // Existing methods remain the same...
@@ -316,11 +340,18 @@ func (e *Election) SaveElection() error {
 	if err != nil {
 		return err
 	}
-	return os.WriteFile("election.json", data, 0644)
+	return e.storage.WriteFile("election.json", data, 0644)
+}
+
+// NewElection creates an empty election saved in the data directory
+func (s *Storage) NewElection() *Election {
+	e := NewElection()
+	e.storage = s
+	return e
 }
 
-func LoadElection() (*Election, error) {
-	data, err := os.ReadFile("election.json")
+func (s *Storage) LoadElection() (*Election, error) {
+	data, err := s.ReadFile("election.json")
 	if err != nil {
 		return nil, err
 	}
@@ -331,6 +362,8 @@ func LoadElection() (*Election, error) {
 
 	// Ensure all maps are properly initialized after loading
 	e.initializeMaps()
+	e.migrateState()
+	e.storage = s
 
 	return &e, nil
 }
@@ -341,6 +374,9 @@ func (e *Election) RemoveCandidate(id string) error {
 	if _, exists := e.Candidates[id]; !exists {
 		return errors.New("candidate not found")
 	}
+	if err := e.ensureCandidatesEditable(); err != nil {
+		return err
+	}
 	delete(e.Candidates, id)
 	return nil
 }
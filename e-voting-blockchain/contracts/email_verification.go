@@ -0,0 +1,62 @@
+package contracts
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"time"
+)
+
+// EmailVerification is a registration whose email address has not been
+// confirmed yet. Only the SHA-256 of the token sent in the link is kept.
+type EmailVerification struct {
+	TokenHash string    `json:"tokenHash"`
+	SentAt    time.Time `json:"sentAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// NewEmailVerification returns a token to send to the voter, and the pending
+// verification it confirms
+func NewEmailVerification(at time.Time, validFor time.Duration) (string, *EmailVerification, error) {
+	token, hash, err := NewSecretToken()
+	if err != nil {
+		return "", nil, err
+	}
+	return token, &EmailVerification{TokenHash: hash, SentAt: at, ExpiresAt: at.Add(validFor)}, nil
+}
+
+// Matches reports whether a token is the one sent for this verification,
+// whether or not it has expired
+func (v *EmailVerification) Matches(token string) bool {
+	return v != nil && subtle.ConstantTimeCompare([]byte(HashToken(token)), []byte(v.TokenHash)) == 1
+}
+
+// Expired reports whether the link can no longer be used
+func (v *EmailVerification) Expired(at time.Time) bool {
+	return at.After(v.ExpiresAt)
+}
+
+// EmailVerified reports whether the voter has confirmed their email
+// address. Registrations from before confirmation was required have nothing
+// pending and count as confirmed.
+func (u RegisteredUser) EmailVerified() bool {
+	return u.Verification == nil
+}
+
+// NewSecretToken returns a random single-use token for a link, and the hash
+// to store in its place
+func NewSecretToken() (token, hash string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token = hex.EncodeToString(buf)
+	return token, HashToken(token), nil
+}
+
+// HashToken is the stored form of a secret token
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
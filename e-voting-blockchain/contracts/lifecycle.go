@@ -0,0 +1,365 @@
+package contracts
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ElectionState is a step in the election lifecycle
+type ElectionState string
+
+const (
+	StateDraft            ElectionState = "draft"
+	StateRegistrationOpen ElectionState = "registration_open"
+	StateScheduled        ElectionState = "scheduled"
+	StateVoting           ElectionState = "voting"
+	StatePaused           ElectionState = "paused"
+	StateClosed           ElectionState = "closed"
+	StateTallied          ElectionState = "tallied"
+	StateCertified        ElectionState = "certified"
+	StateArchived         ElectionState = "archived"
+)
+
+// allowedTransitions lists the legal next states for each state
+var allowedTransitions = map[ElectionState][]ElectionState{
+	StateDraft:            {StateRegistrationOpen, StateScheduled, StateVoting},
+	StateRegistrationOpen: {StateDraft, StateScheduled, StateVoting},
+	StateScheduled:        {StateRegistrationOpen, StateVoting},
+	StateVoting:           {StatePaused, StateClosed},
+	StatePaused:           {StateVoting, StateClosed},
+	StateClosed:           {StateTallied},
+	StateTallied:          {StateCertified},
+	StateCertified:        {StateArchived},
+	StateArchived:         {StateDraft},
+}
+
+// StateTransition describes a change of lifecycle state
+type StateTransition struct {
+	From   ElectionState `json:"from"`
+	To     ElectionState `json:"to"`
+	At     time.Time     `json:"at"`
+	Reason string        `json:"reason,omitempty"`
+}
+
+// ParseElectionState validates a lifecycle state name
+func ParseElectionState(s string) (ElectionState, error) {
+	state := ElectionState(s)
+	if _, ok := allowedTransitions[state]; !ok {
+		return "", fmt.Errorf("unknown election state %q", s)
+	}
+	return state, nil
+}
+
+// State returns the current lifecycle state
+func (e *Election) State() ElectionState {
+	e.migrateState()
+	return e.Status.State
+}
+
+// migrateState derives a lifecycle state for elections saved before states
+// existed, from the old IsActive flag and start time
+func (e *Election) migrateState() {
+	if e.Status.State != "" {
+		return
+	}
+	switch {
+	case e.Status.IsActive:
+		e.Status.State = StateVoting
+	case !e.Status.StartTime.IsZero():
+		e.Status.State = StateClosed
+	default:
+		e.Status.State = StateDraft
+	}
+}
+
+// CanTransition reports whether moving to the given state is legal
+func (e *Election) CanTransition(to ElectionState) bool {
+	for _, next := range allowedTransitions[e.State()] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// transition moves the election to a new state if the move is legal
+func (e *Election) transition(to ElectionState, reason string, at time.Time) (StateTransition, error) {
+	from := e.State()
+	if !e.CanTransition(to) {
+		return StateTransition{}, fmt.Errorf("cannot move election from %s to %s", from, to)
+	}
+	e.Status.State = to
+	e.Status.StateChangedAt = at
+	e.Status.IsActive = to == StateVoting
+	if to == StateClosed {
+		e.Status.ClosedAt = at
+	}
+	return StateTransition{From: from, To: to, At: at, Reason: reason}, nil
+}
+
+// Transition moves the election to a state that needs no extra parameters.
+// Voting is entered through StartElection, ScheduleElection or ResumeVoting,
+// paused through PauseVoting and certified through RecordCertification,
+// instead.
+func (e *Election) Transition(to ElectionState, reason string) (StateTransition, error) {
+	switch to {
+	case StateVoting, StateScheduled, StatePaused:
+		return StateTransition{}, fmt.Errorf("use the start, schedule, pause or resume operations to move the election to %s", to)
+	case StateCertified:
+		return StateTransition{}, errors.New("use the certify operation to certify the results")
+	case StateDraft:
+		if e.State() == StateArchived {
+			return StateTransition{}, errors.New("use the new election operation to follow an archived election")
+		}
+	}
+	return e.transition(to, reason, time.Now())
+}
+
+// StartElection opens voting immediately for the given duration
+func (e *Election) StartElection(description string, duration time.Duration) error {
+	switch e.State() {
+	case StateVoting:
+		return errors.New("election is already active")
+	case StatePaused:
+		return errors.New("election is paused; resume it instead of starting it again")
+	}
+	now := time.Now()
+	if _, err := e.transition(StateVoting, "started manually", now); err != nil {
+		return err
+	}
+	e.Status.StartTime = now
+	e.Status.EndTime = now.Add(duration)
+	e.Status.Description = description
+	return nil
+}
+
+// SetupOptions are the ballot settings that can be given when an election is
+// started or scheduled. Nil or empty ones leave the setting as configured.
+type SetupOptions struct {
+	Ballot          *BallotConfig
+	Visibility      ResultVisibility
+	SpecialOptions  []SpecialOption
+	AllowRevision   *bool
+	AllowWriteIns   *bool
+	WithdrawnPolicy WithdrawnVotePolicy
+	TiePolicy       TiePolicy
+	TieSeed         string // For drawing lots
+	Runoff          *RunoffRule
+}
+
+// StartWith applies the setup options and opens voting, all or nothing: if
+// any option or the start itself is refused, the election is left as it
+// was. The tie seed commitment is returned when lots are to be drawn.
+func (e *Election) StartWith(description string, duration time.Duration, opts SetupOptions) (commitment string, err error) {
+	staged, commitment, err := e.withOptions(opts)
+	if err != nil {
+		return "", err
+	}
+	if err := staged.StartElection(description, duration); err != nil {
+		return "", err
+	}
+	*e = staged
+	return commitment, nil
+}
+
+// ScheduleWith applies the setup options and schedules the voting window,
+// all or nothing like StartWith
+func (e *Election) ScheduleWith(description string, start, end time.Time, opts SetupOptions) (commitment string, err error) {
+	staged, commitment, err := e.withOptions(opts)
+	if err != nil {
+		return "", err
+	}
+	if err := staged.ScheduleElection(description, start, end); err != nil {
+		return "", err
+	}
+	*e = staged
+	return commitment, nil
+}
+
+// withOptions returns a copy of the election with the setup options applied.
+// The options only replace fields, so the copy shares nothing it changes.
+func (e *Election) withOptions(opts SetupOptions) (staged Election, commitment string, err error) {
+	staged = *e
+	if opts.Ballot != nil {
+		if err := staged.ConfigureBallot(*opts.Ballot); err != nil {
+			return Election{}, "", err
+		}
+	}
+	if opts.Visibility != "" {
+		if err := staged.SetResultVisibility(opts.Visibility); err != nil {
+			return Election{}, "", err
+		}
+	}
+	if opts.SpecialOptions != nil {
+		if err := staged.ConfigureSpecialOptions(opts.SpecialOptions); err != nil {
+			return Election{}, "", err
+		}
+	}
+	if opts.AllowRevision != nil {
+		if err := staged.ConfigureRevision(*opts.AllowRevision); err != nil {
+			return Election{}, "", err
+		}
+	}
+	if opts.AllowWriteIns != nil {
+		if err := staged.ConfigureWriteIns(*opts.AllowWriteIns); err != nil {
+			return Election{}, "", err
+		}
+	}
+	if opts.WithdrawnPolicy != "" {
+		if err := staged.ConfigureWithdrawnPolicy(opts.WithdrawnPolicy); err != nil {
+			return Election{}, "", err
+		}
+	}
+	if opts.TiePolicy != "" {
+		if commitment, err = staged.ConfigureTiePolicy(opts.TiePolicy, opts.TieSeed); err != nil {
+			return Election{}, "", err
+		}
+	}
+	if opts.Runoff != nil {
+		if err := staged.ConfigureRunoff(opts.Runoff); err != nil {
+			return Election{}, "", err
+		}
+	}
+	return staged, commitment, nil
+}
+
+// ScheduleElection sets the voting window for the scheduler to open and
+// close the election at the configured times
+func (e *Election) ScheduleElection(description string, start, end time.Time) error {
+	if !end.After(start) {
+		return errors.New("end time must be after start time")
+	}
+	if !end.After(time.Now()) {
+		return errors.New("end time must be in the future")
+	}
+	if e.State() != StateScheduled {
+		if _, err := e.transition(StateScheduled, "voting window scheduled", time.Now()); err != nil {
+			return err
+		}
+	}
+	e.Status.StartTime = start
+	e.Status.EndTime = end
+	e.Status.Description = description
+	return nil
+}
+
+// StopElection closes voting before the scheduled end
+func (e *Election) StopElection() error {
+	switch e.State() {
+	case StateVoting, StatePaused:
+	default:
+		return errors.New("election is not active")
+	}
+	_, err := e.transition(StateClosed, "stopped manually", time.Now())
+	return err
+}
+
+// IsElectionActive reports whether ballots can be cast right now. Expiry is
+// only reported here; the scheduler performs the transition to closed.
+func (e *Election) IsElectionActive() bool {
+	if e.State() != StateVoting {
+		return false
+	}
+	now := time.Now()
+	return !now.Before(e.Status.StartTime) && now.Before(e.Status.EndTime)
+}
+
+// ApplyScheduledTransitions opens a scheduled election once its start time
+// has arrived and closes a running one once its end time has passed. It
+// returns the transitions made so the caller can record them.
+func (e *Election) ApplyScheduledTransitions(now time.Time) []StateTransition {
+	var made []StateTransition
+
+	if e.State() == StateScheduled && !now.Before(e.Status.StartTime) {
+		if t, err := e.transition(StateVoting, "scheduled start time reached", now); err == nil {
+			made = append(made, t)
+		}
+	}
+	if e.State() == StateVoting && !now.Before(e.Status.EndTime) {
+		if t, err := e.transition(StateClosed, "scheduled end time reached", now); err == nil {
+			made = append(made, t)
+		}
+	}
+	return made
+}
+
+// ballotEditable reports whether candidates, contests and ballot settings
+// may still change, which is only before voting has been opened
+func (e *Election) ballotEditable() bool {
+	switch e.State() {
+	case StateDraft, StateRegistrationOpen, StateScheduled:
+		return len(e.Ballots) == 0 && len(e.Voters) == 0
+	}
+	return false
+}
+
+// ElectionLink connects an election to the one held before it on the server
+type ElectionLink struct {
+	Number        int       `json:"number"`
+	Round         int       `json:"round"` // Its last round
+	File          string    `json:"file"`  // Where the last round is archived
+	Description   string    `json:"description"`
+	DocumentHash  string    `json:"documentHash,omitempty"`
+	TransactionID string    `json:"transactionId,omitempty"` // The ELECTION_STATE transaction
+	ArchivedAt    time.Time `json:"archivedAt"`
+}
+
+// GetNumber returns the election's number among those held on the server,
+// 1 for the first
+func (e *Election) GetNumber() int {
+	if e.Number < 1 {
+		return 1
+	}
+	return e.Number
+}
+
+// NextElection builds the election that follows an archived one: a draft
+// with the same parties and electorate, none of whom has voted, and no
+// candidates or ballot settings. The archived election is left unchanged;
+// the caller saves it to its round file with SaveRound before the new one
+// takes its place.
+func (e *Election) NextElection(description string, at time.Time) (*Election, StateTransition, error) {
+	e.initializeMaps()
+
+	if !e.CanTransition(StateDraft) {
+		return nil, StateTransition{}, fmt.Errorf("only an archived election can be followed by a new one; the election is %s", e.State())
+	}
+
+	next := NewElection()
+	next.storage = e.storage
+	next.Number = e.GetNumber() + 1
+	if description != "" {
+		next.Status.Description = description
+	}
+	for id, p := range e.Parties {
+		next.Parties[id] = p
+	}
+	next.copyElectorate(e)
+	next.Status.StateChangedAt = at
+
+	link := &ElectionLink{
+		Number:      e.GetNumber(),
+		Round:       e.GetRound(),
+		File:        RoundFile(e.GetNumber(), e.GetRound()),
+		Description: e.Status.Description,
+		ArchivedAt:  at,
+	}
+	if e.Certification != nil {
+		link.DocumentHash = e.Certification.DocumentHash
+	}
+	next.PreviousElection = link
+
+	t := StateTransition{From: e.State(), To: StateDraft, At: at, Reason: fmt.Sprintf("election %d archived; election %d created", link.Number, next.Number)}
+	return next, t, nil
+}
+
+// copyElectorate gives the election the voters of another, none of whom has
+// voted in it yet
+func (e *Election) copyElectorate(from *Election) {
+	for id, u := range from.Users {
+		u.HasVoted = false
+		u.VotedAt = time.Time{}
+		e.Users[id] = u
+	}
+}
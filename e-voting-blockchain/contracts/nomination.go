@@ -0,0 +1,325 @@
+package contracts
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// PolicyDir is the directory in the data directory that nomination
+	// policy files are kept in
+	PolicyDir = "policies"
+
+	// DefaultNominationPolicyFile is read from the policy directory when no
+	// policy file is named
+	DefaultNominationPolicyFile = "nomination_policy.json"
+)
+
+// ErrPolicyName is returned for a policy file named by anything but a plain
+// file name
+var ErrPolicyName = errors.New("a nomination policy is named by its file name in the policy directory")
+
+// NominationRules are the eligibility rules a nomination is checked against
+type NominationRules struct {
+	MinAge         int      `json:"minAge"`
+	MaxPerParty    int      `json:"maxPerParty,omitempty"`    // Party quota; 0 means no limit
+	RequiredFields []string `json:"requiredFields,omitempty"` // Any of name, bio, partyId, imageUrl, nomineeId
+	OneCandidacy   bool     `json:"oneCandidacy"`             // A nominee may only stand once per election
+	Source         string   `json:"source,omitempty"`         // Policy file the rules were loaded from
+}
+
+// DefaultNominationRules apply until a policy file is loaded
+var DefaultNominationRules = NominationRules{
+	MinAge:         18,
+	RequiredFields: []string{"name"},
+	OneCandidacy:   true,
+}
+
+// NominationStatus is where a nomination is in the review pipeline
+type NominationStatus string
+
+const (
+	NominationSubmitted   NominationStatus = "submitted"
+	NominationUnderReview NominationStatus = "under_review"
+	NominationApproved    NominationStatus = "approved"
+	NominationRejected    NominationStatus = "rejected"
+)
+
+// Nomination puts a nominee forward as a candidate. A nomination that passes
+// the rules adds the nominee as a nominated candidate, who joins the ballot
+// once an election officer approves the nomination.
+type Nomination struct {
+	ID          string           `json:"id"`
+	CandidateID string           `json:"candidateId"`
+	NomineeID   string           `json:"nomineeId,omitempty"` // Identifies the person, e.g. a citizenship number
+	Name        string           `json:"name"`
+	Bio         string           `json:"bio"`
+	PartyID     string           `json:"partyId"`
+	Age         int              `json:"age"`
+	ImageURL    string           `json:"imageUrl,omitempty"`
+	Status      NominationStatus `json:"status"`
+	Violations  []string         `json:"violations,omitempty"`
+	SubmittedBy string           `json:"submittedBy"`
+	SubmittedAt time.Time        `json:"submittedAt"`
+	ReviewedBy  string           `json:"reviewedBy,omitempty"`
+	ReviewedAt  time.Time        `json:"reviewedAt,omitempty"`
+	Reason      string           `json:"reason,omitempty"`
+	DecidedAt   time.Time        `json:"decidedAt,omitempty"`
+}
+
+// LoadNominationRules reads a nomination policy file from the policy
+// directory. Only a plain file name is accepted, and the file is opened
+// within the directory so a link cannot lead out of it. Errors name the file
+// but never the underlying cause, which could reveal what lies outside it.
+func (s *Storage) LoadNominationRules(name string) (NominationRules, error) {
+	if name == "" {
+		name = DefaultNominationPolicyFile
+	}
+	if strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return NominationRules{}, ErrPolicyName
+	}
+
+	root, err := os.OpenRoot(s.Path(PolicyDir))
+	if err != nil {
+		return NominationRules{}, errors.New("the nomination policy directory could not be opened")
+	}
+	defer root.Close()
+	f, err := root.Open(name)
+	if err != nil {
+		return NominationRules{}, fmt.Errorf("nomination policy %s not found", name)
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return NominationRules{}, fmt.Errorf("nomination policy %s could not be read", name)
+	}
+
+	var rules NominationRules
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return NominationRules{}, fmt.Errorf("nomination policy %s is not valid JSON", name)
+	}
+	if rules.MinAge < 0 || rules.MaxPerParty < 0 {
+		return NominationRules{}, errors.New("nomination policy limits cannot be negative")
+	}
+	for _, field := range rules.RequiredFields {
+		if _, ok := nominationFields[field]; !ok {
+			return NominationRules{}, fmt.Errorf("unknown required field %q in nomination policy", field)
+		}
+	}
+	rules.Source = name
+	return rules, nil
+}
+
+// nominationFields reads each field a policy can require
+var nominationFields = map[string]func(n Nomination) string{
+	"name":      func(n Nomination) string { return n.Name },
+	"bio":       func(n Nomination) string { return n.Bio },
+	"partyId":   func(n Nomination) string { return n.PartyID },
+	"imageUrl":  func(n Nomination) string { return n.ImageURL },
+	"nomineeId": func(n Nomination) string { return n.NomineeID },
+}
+
+// GetNominationRules returns the election's rules, or the defaults when no
+// policy has been loaded
+func (e *Election) GetNominationRules() NominationRules {
+	if e.NominationRules == nil {
+		return DefaultNominationRules
+	}
+	return *e.NominationRules
+}
+
+// SetNominationRules replaces the election's rules. Nominations already
+// decided are not revisited.
+func (e *Election) SetNominationRules(rules NominationRules) error {
+	if err := e.ensureCandidatesEditable(); err != nil {
+		return err
+	}
+	e.NominationRules = &rules
+	return nil
+}
+
+// checkNomination returns every rule the nomination breaks. The candidate
+// being decided, if any, is not counted against itself.
+func (e *Election) checkNomination(n Nomination) []string {
+	rules := e.GetNominationRules()
+	var violations []string
+
+	for _, field := range rules.RequiredFields {
+		if get, ok := nominationFields[field]; ok && strings.TrimSpace(get(n)) == "" {
+			violations = append(violations, field+" is required")
+		}
+	}
+	if n.Age < rules.MinAge {
+		violations = append(violations, fmt.Sprintf("nominee must be at least %d years old", rules.MinAge))
+	}
+	if n.PartyID != "" {
+		if _, ok := e.Parties[n.PartyID]; !ok {
+			violations = append(violations, "invalid party ID")
+		}
+	}
+
+	standing := 0
+	for id, c := range e.Candidates {
+		if id == n.CandidateID {
+			continue
+		}
+		switch c.GetStatus() {
+		case CandidateWithdrawn, CandidateDisqualified:
+			continue
+		}
+		if rules.MaxPerParty > 0 && n.PartyID != "" && c.PartyID == n.PartyID {
+			standing++
+		}
+		if rules.OneCandidacy && n.NomineeID != "" && c.NomineeID == n.NomineeID {
+			violations = append(violations, "nominee already stands in this election as "+id)
+		}
+	}
+	if rules.MaxPerParty > 0 && standing >= rules.MaxPerParty {
+		violations = append(violations, fmt.Sprintf("party already has %d candidates, the most allowed", rules.MaxPerParty))
+	}
+	return violations
+}
+
+// SubmitNomination records a nomination and checks it against the rules. A
+// nomination that breaks any rule is recorded as rejected with the
+// violations; otherwise the nominee is added as a nominated candidate
+// awaiting review.
+func (e *Election) SubmitNomination(n Nomination, submittedBy string, at time.Time) (Nomination, error) {
+	e.initializeMaps()
+
+	if err := e.ensureCandidatesEditable(); err != nil {
+		return Nomination{}, err
+	}
+	if n.CandidateID == "" {
+		return Nomination{}, errors.New("candidate ID is required")
+	}
+	if _, exists := e.Candidates[n.CandidateID]; exists {
+		return Nomination{}, errors.New("candidate with that ID already exists")
+	}
+
+	n.ID = fmt.Sprintf("NOM-%04d", len(e.Nominations)+1)
+	n.SubmittedBy = submittedBy
+	n.SubmittedAt = at
+	n.Status = NominationSubmitted
+
+	if n.Violations = e.checkNomination(n); len(n.Violations) > 0 {
+		n.Status = NominationRejected
+		n.Reason = "does not meet the nomination rules"
+		n.DecidedAt = at
+		e.Nominations[n.ID] = n
+		return n, nil
+	}
+
+	e.Candidates[n.CandidateID] = Candidate{
+		CandidateID:     n.CandidateID,
+		NomineeID:       n.NomineeID,
+		Name:            n.Name,
+		Bio:             n.Bio,
+		PartyID:         n.PartyID,
+		PartyName:       e.partyName(n.PartyID),
+		Age:             n.Age,
+		ImageURL:        n.ImageURL,
+		Status:          CandidateNominated,
+		StatusChangedAt: at,
+	}
+	e.Nominations[n.ID] = n
+	return n, nil
+}
+
+// ReviewNomination assigns a nomination to the officer reviewing it
+func (e *Election) ReviewNomination(id, officer string, at time.Time) (Nomination, error) {
+	n, err := e.GetNomination(id)
+	if err != nil {
+		return Nomination{}, err
+	}
+	if n.Status != NominationSubmitted {
+		return Nomination{}, fmt.Errorf("cannot review a nomination that is %s", n.Status)
+	}
+	n.Status = NominationUnderReview
+	n.ReviewedBy = officer
+	n.ReviewedAt = at
+	e.Nominations[id] = n
+	return n, nil
+}
+
+// DecideNomination approves or rejects a nomination. Approval checks the
+// rules again, since other candidates may have been approved meanwhile, and
+// puts the candidate on the ballot. Rejection needs a reason and removes the
+// nominated candidate, who was never on the ballot.
+func (e *Election) DecideNomination(id string, approve bool, officer, reason string, at time.Time) (Nomination, error) {
+	n, err := e.GetNomination(id)
+	if err != nil {
+		return Nomination{}, err
+	}
+	if n.Status != NominationSubmitted && n.Status != NominationUnderReview {
+		return Nomination{}, fmt.Errorf("nomination has already been %s", n.Status)
+	}
+	if err := e.ensureCandidatesEditable(); err != nil {
+		return Nomination{}, err
+	}
+
+	if approve {
+		if violations := e.checkNomination(n); len(violations) > 0 {
+			return Nomination{}, fmt.Errorf("nomination no longer meets the rules: %s", strings.Join(violations, "; "))
+		}
+		if _, err := e.SetCandidateStatus(n.CandidateID, CandidateApproved, reason, at); err != nil {
+			return Nomination{}, err
+		}
+		n.Status = NominationApproved
+	} else {
+		if reason == "" {
+			return Nomination{}, errors.New("a reason is required to reject a nomination")
+		}
+		delete(e.Candidates, n.CandidateID)
+		n.Status = NominationRejected
+	}
+
+	if n.ReviewedBy == "" {
+		n.ReviewedBy = officer
+		n.ReviewedAt = at
+	}
+	n.Reason = reason
+	n.DecidedAt = at
+	e.Nominations[id] = n
+	return n, nil
+}
+
+// GetNomination looks up a nomination by ID
+func (e *Election) GetNomination(id string) (Nomination, error) {
+	e.initializeMaps()
+
+	n, exists := e.Nominations[id]
+	if !exists {
+		return Nomination{}, errors.New("nomination not found")
+	}
+	return n, nil
+}
+
+// ListNominations returns the nominations in the order they were submitted,
+// optionally only those with the given status
+func (e *Election) ListNominations(status NominationStatus) []Nomination {
+	e.initializeMaps()
+
+	list := make([]Nomination, 0, len(e.Nominations))
+	for _, n := range e.Nominations {
+		if status == "" || n.Status == status {
+			list = append(list, n)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+// partyName returns the display name of a party, Independent for none
+func (e *Election) partyName(partyID string) string {
+	if party, exists := e.Parties[partyID]; exists && partyID != "" {
+		return party.Name
+	}
+	return "Independent"
+}
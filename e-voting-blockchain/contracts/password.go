@@ -0,0 +1,144 @@
+package contracts
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Passwords are stored as argon2id hashes in the PHC string format:
+// $argon2id$v=19$m=<KiB>,t=<passes>,p=<threads>$<salt>$<hash>. The
+// parameters travel with each hash so they can be raised later without
+// breaking existing records.
+const (
+	argonTime    = 1
+	argonMemory  = 64 * 1024
+	argonThreads = 4
+	argonKeyLen  = 32
+	argonSaltLen = 16
+)
+
+// argonSlots limits how many hashes are computed at once. Each takes
+// argonMemory, so a burst of logins queues here instead of exhausting the
+// server's memory.
+var argonSlots = make(chan struct{}, 4)
+
+// argonKey computes an argon2id key in one of the argonSlots
+func argonKey(password, salt []byte, time, memory uint32, threads uint8, keyLen uint32) []byte {
+	argonSlots <- struct{}{}
+	defer func() { <-argonSlots }()
+	return argon2.IDKey(password, salt, time, memory, threads, keyLen)
+}
+
+// HashPassword returns the argon2id hash of a password with a random salt
+func HashPassword(password string) (string, error) {
+	salt := make([]byte, argonSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argonKey([]byte(password), salt, argonTime, argonMemory, argonThreads, argonKeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, argonMemory, argonTime, argonThreads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// IsPasswordHash reports whether a stored password is already hashed
+func IsPasswordHash(stored string) bool {
+	return strings.HasPrefix(stored, "$argon2id$")
+}
+
+// VerifyPassword checks a password against its stored hash in constant time.
+// A malformed or unhashed stored value never matches.
+func VerifyPassword(stored, password string) bool {
+	salt, key, time, memory, threads, err := decodePasswordHash(stored)
+	if err != nil {
+		return false
+	}
+	candidate := argonKey([]byte(password), salt, time, memory, threads, uint32(len(key)))
+	return subtle.ConstantTimeCompare(key, candidate) == 1
+}
+
+// VerifyNoPassword spends the time of a password check without an account,
+// so a failed login takes as long whether or not the user name exists
+func VerifyNoPassword(password string) {
+	HashPassword(password)
+}
+
+func decodePasswordHash(stored string) (salt, key []byte, time, memory uint32, threads uint8, err error) {
+	parts := strings.Split(stored, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return nil, nil, 0, 0, 0, errors.New("not an argon2id hash")
+	}
+	var version int
+	if _, err = fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, nil, 0, 0, 0, errors.New("unsupported argon2 version")
+	}
+	if _, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads); err != nil {
+		return nil, nil, 0, 0, 0, err
+	}
+	if salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return nil, nil, 0, 0, 0, err
+	}
+	if key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(key) == 0 {
+		return nil, nil, 0, 0, 0, errors.New("malformed password hash")
+	}
+	return salt, key, time, memory, threads, nil
+}
+
+// MigratePasswords hashes any plaintext passwords left in the voter registry
+// and the registered users file, returning how many records were changed.
+// Files that do not exist are skipped.
+func (s *Storage) MigratePasswords() (int, error) {
+	migrated := 0
+
+	if db, err := s.LoadVoterDatabase(); err == nil {
+		changed := 0
+		for id, record := range db.Records {
+			if record.Password == "" || IsPasswordHash(record.Password) {
+				continue
+			}
+			hash, err := HashPassword(record.Password)
+			if err != nil {
+				return migrated, err
+			}
+			record.Password = hash
+			db.Records[id] = record
+			changed++
+		}
+		if changed > 0 {
+			if err := db.Save(); err != nil {
+				return migrated, err
+			}
+			migrated += changed
+		}
+	}
+
+	if users, err := s.LoadRegisteredUsers(); err == nil {
+		changed := 0
+		for i, user := range users {
+			if user.Password == "" || IsPasswordHash(user.Password) {
+				continue
+			}
+			hash, err := HashPassword(user.Password)
+			if err != nil {
+				return migrated, err
+			}
+			users[i].Password = hash
+			changed++
+		}
+		if changed > 0 {
+			if err := s.SaveRegisteredUsers(users); err != nil {
+				return migrated, err
+			}
+			migrated += changed
+		}
+	}
+
+	return migrated, nil
+}
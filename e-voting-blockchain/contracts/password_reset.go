@@ -0,0 +1,51 @@
+package contracts
+
+import (
+	"crypto/subtle"
+	"time"
+)
+
+// PasswordReset is a voter's pending request to reset their password. Only
+// the SHA-256 of the token sent in the link is kept.
+type PasswordReset struct {
+	TokenHash   string    `json:"tokenHash"`
+	RequestedAt time.Time `json:"requestedAt"`
+	ExpiresAt   time.Time `json:"expiresAt"`
+}
+
+// NewPasswordReset returns a token to send to the voter, and the pending
+// reset it allows
+func NewPasswordReset(at time.Time, validFor time.Duration) (string, *PasswordReset, error) {
+	token, hash, err := NewSecretToken()
+	if err != nil {
+		return "", nil, err
+	}
+	return token, &PasswordReset{TokenHash: hash, RequestedAt: at, ExpiresAt: at.Add(validFor)}, nil
+}
+
+// Matches reports whether a token is the one sent for this reset, whether or
+// not it has expired
+func (p *PasswordReset) Matches(token string) bool {
+	return p != nil && subtle.ConstantTimeCompare([]byte(HashToken(token)), []byte(p.TokenHash)) == 1
+}
+
+// Expired reports whether the link can no longer be used
+func (p *PasswordReset) Expired(at time.Time) bool {
+	return at.After(p.ExpiresAt)
+}
+
+// SetPassword replaces the voter's password hash and cancels any pending
+// reset. Sessions started before at are ended.
+func (u *RegisteredUser) SetPassword(hash string, at time.Time) {
+	u.Password = hash
+	u.PasswordReset = nil
+	u.PasswordChangedAt = at
+}
+
+// SessionValid reports whether a session started at the given time is still
+// valid, that is whether it started after the password was last changed.
+// Token times are in whole seconds, so one started in the second of the
+// change is allowed.
+func (u RegisteredUser) SessionValid(startedAt time.Time) bool {
+	return !startedAt.Before(u.PasswordChangedAt.Truncate(time.Second))
+}
@@ -0,0 +1,302 @@
+package contracts
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"time"
+)
+
+// Critical admin actions are not one admin's decision. The admin who wants
+// one proposes it, other admins approve, and the action runs only once the
+// quorum set by the proposal policy has approved within the policy's window.
+
+// ProposalFile stores the proposals and the proposal policy. It is kept
+// across system resets, since a reset is itself a proposal.
+const ProposalFile = "proposals.json"
+
+// ProposalStatus is where a proposal is in approval
+type ProposalStatus string
+
+const (
+	ProposalPending   ProposalStatus = "pending"
+	ProposalApproved  ProposalStatus = "approved" // Quorum met; being carried out
+	ProposalExecuted  ProposalStatus = "executed"
+	ProposalExpired   ProposalStatus = "expired"
+	ProposalCancelled ProposalStatus = "cancelled"
+	ProposalFailed    ProposalStatus = "failed" // Approved, but the proposer could no longer carry it out
+)
+
+// ProposalPolicy is the quorum a proposal needs: Required approvals from
+// admins other than the proposer, within WindowHours of it being proposed.
+// A proposal never needs more approvals than it has admins able to give
+// them, so a lone first super-admin can set up the other accounts.
+type ProposalPolicy struct {
+	Required    int `json:"required"`
+	WindowHours int `json:"windowHours"`
+}
+
+// DefaultProposalPolicy needs one other admin to approve within a day
+var DefaultProposalPolicy = ProposalPolicy{Required: 1, WindowHours: 24}
+
+// Window is how long a proposal stays open for approval
+func (p ProposalPolicy) Window() time.Duration {
+	return time.Duration(p.WindowHours) * time.Hour
+}
+
+// ProposalApproval is one admin's approval of a proposal
+type ProposalApproval struct {
+	Admin         string    `json:"admin"`
+	ApprovedAt    time.Time `json:"approvedAt"`
+	TransactionID string    `json:"transactionId,omitempty"`
+}
+
+// Proposal is a critical admin request held until it is approved. The
+// request is kept as it was made so it can be carried out unchanged.
+type Proposal struct {
+	ID         string            `json:"id"`
+	Action     string            `json:"action"`
+	Permission Permission        `json:"permission"` // What approvers' roles must grant
+	Method     string            `json:"method"`
+	Path       string            `json:"path"`
+	Vars       map[string]string `json:"vars,omitempty"`
+	Body       string            `json:"body,omitempty"`
+	Reason     string            `json:"reason,omitempty"`
+
+	// Approvers are the admins who may approve, fixed when it is proposed:
+	// the other active admins whose role grants Permission or, if there are
+	// none, all the other active admins, with ApproversAnyRole set
+	Approvers        []string `json:"approvers,omitempty"`
+	ApproversAnyRole bool     `json:"approversAnyRole,omitempty"`
+
+	ProposedBy    string             `json:"proposedBy"`
+	ProposedAt    time.Time          `json:"proposedAt"`
+	ExpiresAt     time.Time          `json:"expiresAt"`
+	Required      int                `json:"required"`
+	Approvals     []ProposalApproval `json:"approvals"`
+	Status        ProposalStatus     `json:"status"`
+	TransactionID string             `json:"transactionId,omitempty"`
+
+	ExecutedBy   string          `json:"executedBy,omitempty"` // Whose approval completed the quorum
+	ExecutedAt   time.Time       `json:"executedAt,omitempty"`
+	ResultStatus int             `json:"resultStatus,omitempty"`
+	Result       json.RawMessage `json:"result,omitempty"`
+}
+
+// ProposalStore holds the proposals keyed by ID, and the proposal policy
+type ProposalStore struct {
+	Policy    ProposalPolicy      `json:"policy"`
+	Proposals map[string]Proposal `json:"proposals"`
+
+	storage *Storage
+}
+
+// LoadProposalStore reads the proposals, starting an empty store with the
+// default policy if there is none
+func (s *Storage) LoadProposalStore() (*ProposalStore, error) {
+	store := &ProposalStore{Policy: DefaultProposalPolicy, Proposals: make(map[string]Proposal), storage: s}
+	data, err := s.ReadFile(ProposalFile)
+	if os.IsNotExist(err) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, store); err != nil {
+		return nil, err
+	}
+	if store.Proposals == nil {
+		store.Proposals = make(map[string]Proposal)
+	}
+	return store, nil
+}
+
+// Save writes the proposals
+func (s *ProposalStore) Save() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return s.storage.WriteFile(ProposalFile, data, 0600)
+}
+
+// SetPolicy changes the quorum for proposals made from now on. Every
+// critical action needs at least one approval, and no more than the
+// approvers there are: the active admins other than the proposer.
+func (s *ProposalStore) SetPolicy(p ProposalPolicy, approvers int) error {
+	if p.Required < 1 {
+		return errors.New("critical actions need at least one approval")
+	}
+	if p.Required > approvers {
+		return fmt.Errorf("required approvals cannot exceed the %d other active admins who could approve", approvers)
+	}
+	if p.WindowHours <= 0 {
+		return errors.New("approval window must be positive")
+	}
+	s.Policy = p
+	return nil
+}
+
+// Propose records a request awaiting approval under the current policy,
+// needing no more approvals than it has approvers. An identical request
+// already pending is refused.
+func (s *ProposalStore) Propose(p Proposal, at time.Time) (Proposal, error) {
+	s.expire(at)
+	for _, other := range s.Proposals {
+		if other.Status == ProposalPending && other.Action == p.Action && other.Path == p.Path && other.Body == p.Body {
+			return Proposal{}, fmt.Errorf("the same request is already awaiting approval as %s", other.ID)
+		}
+	}
+	p.ID = fmt.Sprintf("PROP-%04d", len(s.Proposals)+1)
+	p.ProposedAt = at
+	p.ExpiresAt = at.Add(s.Policy.Window())
+	p.Required = min(s.Policy.Required, len(p.Approvers))
+	p.Approvals = []ProposalApproval{}
+	p.Status = ProposalPending
+	if p.QuorumMet() {
+		p.Status = ProposalApproved
+	}
+	s.Proposals[p.ID] = p
+	return p, nil
+}
+
+// RecordProposal stores the chain transaction of a new proposal
+func (s *ProposalStore) RecordProposal(id, txID string) {
+	p := s.Proposals[id]
+	p.TransactionID = txID
+	s.Proposals[id] = p
+}
+
+// Approve adds an admin's approval to a pending proposal. Once the quorum is
+// met the proposal is approved, and no further approval can carry it out a
+// second time. The proposer cannot approve their own proposal, only the
+// proposal's approvers can, and unless any role may approve, the
+// approver's role must still grant the action's permission.
+func (s *ProposalStore) Approve(id, admin string, role AdminRole, at time.Time) (Proposal, error) {
+	s.expire(at)
+	p, err := s.pending(id)
+	if err != nil {
+		return Proposal{}, err
+	}
+	if admin == p.ProposedBy {
+		return Proposal{}, errors.New("a proposal must be approved by admins other than its proposer")
+	}
+	if p.Approvers != nil && !slices.Contains(p.Approvers, admin) {
+		return Proposal{}, errors.New("you were not an admin able to approve this when it was proposed")
+	}
+	if !p.ApproversAnyRole && !role.Can(p.Permission) {
+		return Proposal{}, fmt.Errorf("approving %s requires the %s permission", p.Action, p.Permission)
+	}
+	for _, a := range p.Approvals {
+		if a.Admin == admin {
+			return Proposal{}, errors.New("you have already approved this proposal")
+		}
+	}
+	p.Approvals = append(p.Approvals, ProposalApproval{Admin: admin, ApprovedAt: at})
+	if p.QuorumMet() {
+		p.Status = ProposalApproved
+	}
+	s.Proposals[id] = p
+	return p, nil
+}
+
+// RecordApproval stores the chain transaction of the latest approval
+func (s *ProposalStore) RecordApproval(id, txID string) {
+	p := s.Proposals[id]
+	if n := len(p.Approvals); n > 0 {
+		p.Approvals[n-1].TransactionID = txID
+		s.Proposals[id] = p
+	}
+}
+
+// RecordExecution marks a proposal carried out, with the response its
+// request produced
+func (s *ProposalStore) RecordExecution(id, executedBy string, status int, result []byte, at time.Time) Proposal {
+	p := s.Proposals[id]
+	p.Status = ProposalExecuted
+	p.ExecutedBy = executedBy
+	p.ExecutedAt = at
+	p.ResultStatus = status
+	if json.Valid(result) {
+		p.Result = json.RawMessage(result)
+	}
+	s.Proposals[id] = p
+	return p
+}
+
+// RecordFailure marks an approved proposal that could not be carried out
+func (s *ProposalStore) RecordFailure(id, executedBy, reason string, at time.Time) Proposal {
+	p := s.Proposals[id]
+	p.Status = ProposalFailed
+	p.ExecutedBy = executedBy
+	p.ExecutedAt = at
+	p.Result, _ = json.Marshal(map[string]string{"error": reason})
+	s.Proposals[id] = p
+	return p
+}
+
+// Cancel withdraws a pending proposal; only its proposer can
+func (s *ProposalStore) Cancel(id, admin string, at time.Time) (Proposal, error) {
+	s.expire(at)
+	p, err := s.pending(id)
+	if err != nil {
+		return Proposal{}, err
+	}
+	if admin != p.ProposedBy {
+		return Proposal{}, errors.New("only the proposer can cancel a proposal")
+	}
+	p.Status = ProposalCancelled
+	s.Proposals[id] = p
+	return p, nil
+}
+
+// QuorumMet reports whether a proposal has the approvals it needs
+func (p Proposal) QuorumMet() bool {
+	return len(p.Approvals) >= p.Required
+}
+
+// GetProposal returns a proposal, marking it expired if its window has
+// passed
+func (s *ProposalStore) GetProposal(id string, at time.Time) (Proposal, bool) {
+	s.expire(at)
+	p, exists := s.Proposals[id]
+	return p, exists
+}
+
+// ListProposals returns the proposals newest first, optionally only those
+// with the given status
+func (s *ProposalStore) ListProposals(status ProposalStatus, at time.Time) []Proposal {
+	s.expire(at)
+	list := make([]Proposal, 0, len(s.Proposals))
+	for _, p := range s.Proposals {
+		if status == "" || p.Status == status {
+			list = append(list, p)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID > list[j].ID })
+	return list
+}
+
+func (s *ProposalStore) pending(id string) (Proposal, error) {
+	p, exists := s.Proposals[id]
+	if !exists {
+		return Proposal{}, errors.New("proposal not found")
+	}
+	if p.Status != ProposalPending {
+		return Proposal{}, fmt.Errorf("proposal is %s", p.Status)
+	}
+	return p, nil
+}
+
+// expire marks pending proposals whose window has passed
+func (s *ProposalStore) expire(at time.Time) {
+	for id, p := range s.Proposals {
+		if p.Status == ProposalPending && at.After(p.ExpiresAt) {
+			p.Status = ProposalExpired
+			s.Proposals[id] = p
+		}
+	}
+}
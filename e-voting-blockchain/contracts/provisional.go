@@ -0,0 +1,231 @@
+package contracts
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// A provisional ballot is cast by a voter whose details could not be
+// confirmed at the polls. The ballot is sealed, encrypted so that no one can
+// read it while the voter's eligibility is under review, and kept out of the
+// tally. An election officer then accepts it, which unseals it and counts it
+// like any other ballot, or rejects it unopened.
+
+// ProvisionalStatus is where a provisional ballot is in review
+type ProvisionalStatus string
+
+const (
+	ProvisionalPending  ProvisionalStatus = "pending"
+	ProvisionalAccepted ProvisionalStatus = "accepted"
+	ProvisionalRejected ProvisionalStatus = "rejected"
+)
+
+// ProvisionalBallot is a sealed ballot awaiting an eligibility decision
+type ProvisionalBallot struct {
+	ID          string            `json:"id"`
+	VoterID     string            `json:"voterId"`
+	Name        string            `json:"name"` // Details the voter gave, for review
+	DOB         string            `json:"dob"`
+	Challenge   string            `json:"challenge"`        // Why the voter could not be confirmed
+	Sealed      string            `json:"sealed,omitempty"` // Encrypted ballot; never returned by the API
+	SealHash    string            `json:"sealHash"`         // SHA-256 of the sealed ballot, as logged on the chain
+	Status      ProvisionalStatus `json:"status"`
+	SubmittedAt time.Time         `json:"submittedAt"`
+	DecidedBy   string            `json:"decidedBy,omitempty"`
+	DecidedAt   time.Time         `json:"decidedAt,omitempty"`
+	Reason      string            `json:"reason,omitempty"`
+	Sequence    int               `json:"sequence,omitempty"` // Of the counted ballot once accepted
+}
+
+// ProvisionalSummary counts provisional ballots by status
+type ProvisionalSummary struct {
+	Submitted int `json:"submitted"`
+	Pending   int `json:"pending"`
+	Accepted  int `json:"accepted"`
+	Rejected  int `json:"rejected"`
+}
+
+// sealBallot encrypts a ballot with AES-GCM under the given 32-byte key
+func sealBallot(key []byte, b Ballot) (string, error) {
+	gcm, err := sealCipher(key)
+	if err != nil {
+		return "", err
+	}
+	plain, err := json.Marshal(b)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, plain, nil)), nil
+}
+
+// unsealBallot decrypts a ballot sealed by sealBallot
+func unsealBallot(key []byte, sealed string) (Ballot, error) {
+	gcm, err := sealCipher(key)
+	if err != nil {
+		return Ballot{}, err
+	}
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil || len(data) < gcm.NonceSize() {
+		return Ballot{}, errors.New("sealed ballot is corrupt")
+	}
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return Ballot{}, errors.New("sealed ballot cannot be opened with this key")
+	}
+	var b Ballot
+	if err := json.Unmarshal(plain, &b); err != nil {
+		return Ballot{}, err
+	}
+	return b, nil
+}
+
+func sealCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// SubmitProvisional checks a provisional ballot's contents, seals it with the
+// key and holds it for review. The voter may have only one ballot pending and
+// must not already have voted.
+func (e *Election) SubmitProvisional(ballot Ballot, name, dob, challenge string, key []byte, at time.Time) (ProvisionalBallot, error) {
+	e.initializeMaps()
+
+	if e.State() == StatePaused {
+		return ProvisionalBallot{}, ErrElectionPaused
+	}
+	if !e.IsElectionActive() {
+		return ProvisionalBallot{}, errors.New("election is not active")
+	}
+	if ballot.VoterID == "" {
+		return ProvisionalBallot{}, errors.New("voter ID is required")
+	}
+	if e.Voters[ballot.VoterID] {
+		return ProvisionalBallot{}, errors.New("voter has already voted")
+	}
+	for _, p := range e.Provisionals {
+		if p.VoterID == ballot.VoterID && p.Status == ProvisionalPending {
+			return ProvisionalBallot{}, fmt.Errorf("voter already has provisional ballot %s under review", p.ID)
+		}
+	}
+	if err := e.validateBallot(&ballot); err != nil {
+		return ProvisionalBallot{}, err
+	}
+
+	sealed, err := sealBallot(key, ballot)
+	if err != nil {
+		return ProvisionalBallot{}, err
+	}
+	sum := sha256.Sum256([]byte(sealed))
+	p := ProvisionalBallot{
+		ID:          fmt.Sprintf("PROV-%04d", len(e.Provisionals)+1),
+		VoterID:     ballot.VoterID,
+		Name:        name,
+		DOB:         dob,
+		Challenge:   challenge,
+		Sealed:      sealed,
+		SealHash:    hex.EncodeToString(sum[:]),
+		Status:      ProvisionalPending,
+		SubmittedAt: at,
+	}
+	e.Provisionals[p.ID] = p
+	return p, nil
+}
+
+// DecideProvisional accepts or rejects a provisional ballot after review. An
+// accepted ballot is unsealed, checked again and counted; the counted ballot
+// is returned. A rejected ballot stays sealed and needs a reason. Decisions
+// can be made until the results are certified.
+func (e *Election) DecideProvisional(id string, accept bool, officer, reason string, key []byte, at time.Time) (ProvisionalBallot, *Ballot, error) {
+	e.initializeMaps()
+
+	switch e.State() {
+	case StateVoting, StatePaused, StateClosed:
+	default:
+		return ProvisionalBallot{}, nil, errors.New("provisional ballots can only be decided between opening the polls and certifying the results")
+	}
+	p, exists := e.Provisionals[id]
+	if !exists {
+		return ProvisionalBallot{}, nil, errors.New("provisional ballot not found")
+	}
+	if p.Status != ProvisionalPending {
+		return ProvisionalBallot{}, nil, fmt.Errorf("provisional ballot has already been %s", p.Status)
+	}
+
+	var counted *Ballot
+	if accept {
+		if e.Voters[p.VoterID] && !e.AllowRevision {
+			return ProvisionalBallot{}, nil, errors.New("voter has since voted; reject the provisional ballot instead")
+		}
+		ballot, err := unsealBallot(key, p.Sealed)
+		if err != nil {
+			return ProvisionalBallot{}, nil, err
+		}
+		if err := e.validateBallot(&ballot); err != nil {
+			return ProvisionalBallot{}, nil, fmt.Errorf("provisional ballot is no longer valid: %v", err)
+		}
+		ballot.Provisional = p.ID
+		ballot = e.storeBallot(ballot, at)
+		counted = &ballot
+		p.Status = ProvisionalAccepted
+		p.Sequence = ballot.Sequence
+	} else {
+		if reason == "" {
+			return ProvisionalBallot{}, nil, errors.New("a reason is required to reject a provisional ballot")
+		}
+		p.Status = ProvisionalRejected
+	}
+
+	p.DecidedBy = officer
+	p.DecidedAt = at
+	p.Reason = reason
+	e.Provisionals[id] = p
+	return p, counted, nil
+}
+
+// ListProvisionals returns the provisional ballots in the order they were
+// submitted, optionally only those with the given status
+func (e *Election) ListProvisionals(status ProvisionalStatus) []ProvisionalBallot {
+	e.initializeMaps()
+
+	list := make([]ProvisionalBallot, 0, len(e.Provisionals))
+	for _, p := range e.Provisionals {
+		if status == "" || p.Status == status {
+			list = append(list, p)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+// ProvisionalCounts summarises the provisional ballots by status
+func (e *Election) ProvisionalCounts() ProvisionalSummary {
+	var s ProvisionalSummary
+	for _, p := range e.Provisionals {
+		s.Submitted++
+		switch p.Status {
+		case ProvisionalPending:
+			s.Pending++
+		case ProvisionalAccepted:
+			s.Accepted++
+		case ProvisionalRejected:
+			s.Rejected++
+		}
+	}
+	return s
+}
@@ -0,0 +1,385 @@
+package contracts
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// CountResult is the outcome of counting an election's ballots
+type CountResult struct {
+	Method       BallotType   `json:"method"`
+	Seats        int          `json:"seats"`
+	Quota        float64      `json:"quota,omitempty"`
+	TotalBallots int          `json:"totalBallots"`
+	Winners      []string     `json:"winners"`
+	Rounds       []CountRound `json:"rounds"`
+	Breakdown    interface{}  `json:"breakdown,omitempty"` // Strategy-specific per-candidate detail
+	// Tie is a tie for last place that earlier rounds could not break and no
+	// tie resolution has settled; the count stops at that round
+	Tie *ContestTie `json:"tie,omitempty"`
+}
+
+// CountRound is the state of the count at the end of one round
+type CountRound struct {
+	Round      int                `json:"round"`
+	Tallies    map[string]float64 `json:"tallies"`
+	Exhausted  float64            `json:"exhausted"`
+	Elected    []string           `json:"elected,omitempty"`
+	Eliminated []string           `json:"eliminated,omitempty"`
+	Transfers  []Transfer         `json:"transfers,omitempty"`
+	Note       string             `json:"note,omitempty"`
+}
+
+// Transfer records votes moving from one candidate to another. An empty To
+// means the ballots were exhausted.
+type Transfer struct {
+	From   string  `json:"from"`
+	To     string  `json:"to"`
+	Votes  float64 `json:"votes"`
+	Reason string  `json:"reason"` // "elimination" or "surplus"
+}
+
+// rankedBallot is a ballot's position during a count
+type rankedBallot struct {
+	choices []string
+	pos     int
+	weight  float64
+}
+
+// current returns the candidate the ballot currently counts for, or "" if exhausted
+func (b *rankedBallot) current() string {
+	if b.pos < len(b.choices) {
+		return b.choices[b.pos]
+	}
+	return ""
+}
+
+// advance moves the ballot to its next preference that is still continuing
+func (b *rankedBallot) advance(continuing map[string]bool) {
+	for b.pos < len(b.choices) && !continuing[b.choices[b.pos]] {
+		b.pos++
+	}
+}
+
+// rankedCount holds the shared state of an IRV or STV count
+type rankedCount struct {
+	ballots    []*rankedBallot
+	continuing map[string]bool
+	rounds     []CountRound
+	resolved   map[int]TieResolution // Resolutions of last-place ties, by round
+}
+
+func newRankedCount(candidates []string, ballots []Ballot, resolved map[int]TieResolution) *rankedCount {
+	rc := &rankedCount{continuing: make(map[string]bool, len(candidates)), resolved: resolved}
+	for _, id := range candidates {
+		rc.continuing[id] = true
+	}
+	for _, b := range ballots {
+		rb := &rankedBallot{choices: b.Choices, weight: 1}
+		rb.advance(rc.continuing)
+		rc.ballots = append(rc.ballots, rb)
+	}
+	return rc
+}
+
+// tally sums ballot weights per continuing candidate
+func (rc *rankedCount) tally() (map[string]float64, float64) {
+	tallies := make(map[string]float64, len(rc.continuing))
+	for id, ok := range rc.continuing {
+		if ok {
+			tallies[id] = 0
+		}
+	}
+	exhausted := 0.0
+	for _, b := range rc.ballots {
+		if id := b.current(); id != "" {
+			tallies[id] += b.weight
+		} else {
+			exhausted += b.weight
+		}
+	}
+	for id, v := range tallies {
+		tallies[id] = roundVotes(v)
+	}
+	return tallies, roundVotes(exhausted)
+}
+
+// remaining returns the continuing candidates in a stable order
+func (rc *rankedCount) remaining() []string {
+	ids := make([]string, 0, len(rc.continuing))
+	for id, ok := range rc.continuing {
+		if ok {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// lowest picks the candidates to eliminate. Candidates without votes go out
+// together while enough others remain to fill the open seats. Other ties are
+// broken by looking back at earlier rounds for the candidate with fewer
+// votes. A tie the earlier rounds cannot break is settled by its recorded
+// tie resolution; without one it is returned and the count stops there.
+func (rc *rankedCount) lowest(tallies map[string]float64, open int) ([]string, string, *ContestTie) {
+	ids := rc.remaining()
+	min := math.Inf(1)
+	for _, id := range ids {
+		min = math.Min(min, tallies[id])
+	}
+	var tied []string
+	for _, id := range ids {
+		if tallies[id] == min {
+			tied = append(tied, id)
+		}
+	}
+	if len(tied) == 1 {
+		return tied, "", nil
+	}
+	if min == 0 && len(ids)-len(tied) >= open {
+		return tied, fmt.Sprintf("candidates without votes eliminated together: %v", tied), nil
+	}
+
+	votes := min
+	for i := len(rc.rounds) - 1; i >= 0 && len(tied) > 1; i-- {
+		prev := rc.rounds[i].Tallies
+		min = math.Inf(1)
+		for _, id := range tied {
+			min = math.Min(min, prev[id])
+		}
+		var still []string
+		for _, id := range tied {
+			if prev[id] == min {
+				still = append(still, id)
+			}
+		}
+		tied = still
+	}
+	if len(tied) == 1 {
+		return tied, fmt.Sprintf("tie for last place broken by earlier round totals: %s eliminated", tied[0]), nil
+	}
+
+	round := len(rc.rounds) + 1
+	if res, ok := rc.resolved[round]; ok && !res.Runoff && sameMembers(res.Tied, tied) {
+		for _, id := range tied {
+			if !contains(res.Winners, id) {
+				return []string{id}, fmt.Sprintf("tie for last place between %v resolved by %s: %s eliminated", tied, res.Policy, id), nil
+			}
+		}
+	}
+	return nil, fmt.Sprintf("tie for last place between %v awaits a tie resolution", tied), &ContestTie{
+		Candidates: tied,
+		Votes:      votes,
+		SeatsOpen:  len(tied) - 1,
+		Round:      round,
+	}
+}
+
+// eliminate takes candidates out of the count and passes their ballots on
+func (rc *rankedCount) eliminate(cr *CountRound, out []string) {
+	for _, id := range out {
+		rc.continuing[id] = false
+	}
+	cr.Eliminated = out
+	for _, id := range out {
+		cr.Transfers = append(cr.Transfers, rc.transfer(id, 1, "elimination")...)
+	}
+}
+
+// transfer moves every ballot sitting with from to its next continuing
+// preference, scaling its weight by factor, and reports where the votes went
+func (rc *rankedCount) transfer(from string, factor float64, reason string) []Transfer {
+	moved := make(map[string]float64)
+	for _, b := range rc.ballots {
+		if b.current() != from {
+			continue
+		}
+		b.weight *= factor
+		b.advance(rc.continuing)
+		moved[b.current()] += b.weight
+	}
+
+	dests := make([]string, 0, len(moved))
+	for to := range moved {
+		dests = append(dests, to)
+	}
+	sort.Strings(dests)
+
+	transfers := make([]Transfer, 0, len(dests))
+	for _, to := range dests {
+		if moved[to] == 0 {
+			continue
+		}
+		transfers = append(transfers, Transfer{From: from, To: to, Votes: roundVotes(moved[to]), Reason: reason})
+	}
+	return transfers
+}
+
+// CountInstantRunoff counts ranked ballots for a single winner. Each round the
+// candidate with the fewest votes is eliminated and their ballots pass to the
+// next continuing preference, until one candidate holds a majority of the
+// ballots still in play. Resolved holds the resolutions of last-place ties
+// that earlier rounds cannot break, by round.
+func CountInstantRunoff(candidates []string, ballots []Ballot, resolved map[int]TieResolution) CountResult {
+	rc := newRankedCount(candidates, ballots, resolved)
+	result := CountResult{
+		Method:       BallotInstantRunoff,
+		Seats:        1,
+		TotalBallots: len(ballots),
+		Winners:      []string{},
+	}
+
+	for round := 1; len(rc.remaining()) > 0; round++ {
+		tallies, exhausted := rc.tally()
+		cr := CountRound{Round: round, Tallies: tallies, Exhausted: exhausted}
+
+		active := 0.0
+		for _, v := range tallies {
+			active += v
+		}
+		if active == 0 {
+			cr.Note = "no ballots remain in play"
+			rc.rounds = append(rc.rounds, cr)
+			break
+		}
+
+		ids := rc.remaining()
+		if winner, ok := leader(tallies); ok && (tallies[winner] > active/2 || len(ids) == 1) {
+			cr.Elected = []string{winner}
+			result.Winners = cr.Elected
+			rc.rounds = append(rc.rounds, cr)
+			break
+		}
+
+		out, note, tie := rc.lowest(tallies, 1)
+		cr.Note = note
+		if tie != nil {
+			result.Tie = tie
+			rc.rounds = append(rc.rounds, cr)
+			break
+		}
+		rc.eliminate(&cr, out)
+		rc.rounds = append(rc.rounds, cr)
+	}
+
+	result.Rounds = rc.rounds
+	return result
+}
+
+// DroopQuota returns the smallest whole number of votes that only seats
+// candidates can reach: floor(valid / (seats + 1)) + 1
+func DroopQuota(valid, seats int) float64 {
+	return math.Floor(float64(valid)/float64(seats+1)) + 1
+}
+
+// CountSingleTransferable counts ranked ballots for several seats using the
+// Droop quota. Candidates reaching the quota are elected and their surplus is
+// passed on at a fractional value (surplus / candidate total) so every ballot
+// that elected them carries an equal share onward. When nobody reaches the
+// quota the lowest candidate is eliminated and their ballots transfer at
+// their current value. Last-place ties are settled as in CountInstantRunoff.
+func CountSingleTransferable(candidates []string, ballots []Ballot, seats int, resolved map[int]TieResolution) CountResult {
+	if seats < 1 {
+		seats = 1
+	}
+	rc := newRankedCount(candidates, ballots, resolved)
+
+	valid := 0
+	for _, b := range ballots {
+		if len(b.Choices) > 0 {
+			valid++
+		}
+	}
+	quota := DroopQuota(valid, seats)
+
+	result := CountResult{
+		Method:       BallotSingleTransferable,
+		Seats:        seats,
+		Quota:        quota,
+		TotalBallots: len(ballots),
+		Winners:      []string{},
+	}
+
+	for round := 1; len(result.Winners) < seats && len(rc.remaining()) > 0; round++ {
+		tallies, exhausted := rc.tally()
+		cr := CountRound{Round: round, Tallies: tallies, Exhausted: exhausted}
+		ids := rc.remaining()
+
+		// Fill the remaining seats once there are no more candidates than seats
+		if len(ids) <= seats-len(result.Winners) {
+			sortByTally(ids, tallies)
+			cr.Elected = ids
+			cr.Note = "remaining candidates elected to fill open seats"
+			result.Winners = append(result.Winners, ids...)
+			rc.rounds = append(rc.rounds, cr)
+			break
+		}
+
+		var reached []string
+		for _, id := range ids {
+			if tallies[id] >= quota {
+				reached = append(reached, id)
+			}
+		}
+
+		if len(reached) > 0 {
+			sortByTally(reached, tallies)
+			if over := len(result.Winners) + len(reached) - seats; over > 0 {
+				reached = reached[:len(reached)-over]
+			}
+			for _, id := range reached {
+				rc.continuing[id] = false
+			}
+			for _, id := range reached {
+				surplus := tallies[id] - quota
+				cr.Transfers = append(cr.Transfers, rc.transfer(id, surplus/tallies[id], "surplus")...)
+			}
+			cr.Elected = reached
+			result.Winners = append(result.Winners, reached...)
+		} else {
+			out, note, tie := rc.lowest(tallies, seats-len(result.Winners))
+			cr.Note = note
+			if tie != nil {
+				result.Tie = tie
+				rc.rounds = append(rc.rounds, cr)
+				break
+			}
+			rc.eliminate(&cr, out)
+		}
+		rc.rounds = append(rc.rounds, cr)
+	}
+
+	result.Rounds = rc.rounds
+	return result
+}
+
+// roundVotes rounds fractional vote values to six decimal places so that
+// repeated surplus transfers do not accumulate floating point noise
+func roundVotes(v float64) float64 {
+	return math.Round(v*1e6) / 1e6
+}
+
+// sortByTally orders candidate IDs by descending tally, then by ID
+func sortByTally(ids []string, tallies map[string]float64) {
+	sort.SliceStable(ids, func(i, j int) bool {
+		if tallies[ids[i]] != tallies[ids[j]] {
+			return tallies[ids[i]] > tallies[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+}
+
+// leader returns the candidate with the most votes if there is exactly one
+func leader(tallies map[string]float64) (string, bool) {
+	best, top, count := "", 0.0, 0
+	for id, v := range tallies {
+		switch {
+		case v > top:
+			best, top, count = id, v, 1
+		case v == top && v > 0:
+			count++
+		}
+	}
+	return best, count == 1
+}
@@ -0,0 +1,345 @@
+package contracts
+
+import (
+	"reflect"
+	"testing"
+)
+
+// ballots returns n ballots ranking the given candidates in order
+func ballots(n int, choices ...string) []Ballot {
+	list := make([]Ballot, n)
+	for i := range list {
+		list[i] = Ballot{Choices: choices}
+	}
+	return list
+}
+
+// concat joins groups of ballots into one list
+func concat(groups ...[]Ballot) []Ballot {
+	var all []Ballot
+	for _, g := range groups {
+		all = append(all, g...)
+	}
+	return all
+}
+
+// eliminated lists the candidates eliminated in each round, in round order
+func eliminated(result CountResult) []string {
+	var out []string
+	for _, round := range result.Rounds {
+		out = append(out, round.Eliminated...)
+	}
+	return out
+}
+
+func TestDroopQuota(t *testing.T) {
+	tests := []struct {
+		valid, seats int
+		want         float64
+	}{
+		{100, 1, 51},
+		{100, 2, 34},
+		{100, 3, 26},
+		{20, 3, 6},
+		{9, 2, 4},
+		{0, 1, 1},
+	}
+	for _, tt := range tests {
+		if got := DroopQuota(tt.valid, tt.seats); got != tt.want {
+			t.Errorf("DroopQuota(%d, %d) = %v, want %v", tt.valid, tt.seats, got, tt.want)
+		}
+	}
+}
+
+func TestCountInstantRunoff(t *testing.T) {
+	tests := []struct {
+		name       string
+		candidates []string
+		ballots    []Ballot
+		resolved   map[int]TieResolution
+		winners    []string
+		eliminated []string
+		exhausted  float64 // In the last round
+		tie        *ContestTie
+	}{
+		{
+			// The Tennessee capital example: Memphis leads on first
+			// preferences but Knoxville wins once Chattanooga and then
+			// Nashville are eliminated
+			name:       "tennessee capital",
+			candidates: []string{"memphis", "nashville", "chattanooga", "knoxville"},
+			ballots: concat(
+				ballots(42, "memphis", "nashville", "chattanooga", "knoxville"),
+				ballots(26, "nashville", "chattanooga", "knoxville", "memphis"),
+				ballots(15, "chattanooga", "knoxville", "nashville", "memphis"),
+				ballots(17, "knoxville", "chattanooga", "nashville", "memphis"),
+			),
+			winners:    []string{"knoxville"},
+			eliminated: []string{"chattanooga", "nashville"},
+		},
+		{
+			name:       "first round majority",
+			candidates: []string{"a", "b", "c"},
+			ballots:    concat(ballots(6, "a"), ballots(3, "b"), ballots(2, "c")),
+			winners:    []string{"a"},
+		},
+		{
+			// A majority is of the ballots still in play, not of all cast
+			name:       "exhausted ballots leave the majority",
+			candidates: []string{"a", "b", "c"},
+			ballots:    concat(ballots(4, "a"), ballots(3, "b"), ballots(2, "c")),
+			winners:    []string{"a"},
+			eliminated: []string{"c"},
+			exhausted:  2,
+		},
+		{
+			name:       "candidates without votes eliminated together",
+			candidates: []string{"a", "b", "c", "d", "e"},
+			ballots:    concat(ballots(2, "a"), ballots(2, "b"), ballots(1, "e", "a")),
+			winners:    []string{"a"},
+			eliminated: []string{"c", "d", "e"},
+		},
+		{
+			// b and c tie for last in round 2; c had fewer votes in round 1
+			name:       "last place tie broken by an earlier round",
+			candidates: []string{"a", "b", "c", "d"},
+			ballots:    concat(ballots(5, "a"), ballots(4, "b"), ballots(3, "c"), ballots(1, "d", "c")),
+			winners:    []string{"a"},
+			eliminated: []string{"d", "c"},
+			exhausted:  4,
+		},
+		{
+			name:       "unbroken last place tie stops the count",
+			candidates: []string{"a", "b", "c"},
+			ballots:    concat(ballots(3, "a"), ballots(2, "b", "a"), ballots(2, "c", "b")),
+			winners:    []string{},
+			tie:        &ContestTie{Candidates: []string{"b", "c"}, Votes: 2, SeatsOpen: 1, Round: 1},
+		},
+		{
+			name:       "resolved last place tie",
+			candidates: []string{"a", "b", "c"},
+			ballots:    concat(ballots(3, "a"), ballots(2, "b", "a"), ballots(2, "c", "b")),
+			resolved: map[int]TieResolution{
+				1: {Policy: TieByLot, Tied: []string{"b", "c"}, Winners: []string{"b"}, Round: 1},
+			},
+			winners:    []string{"b"},
+			eliminated: []string{"c"},
+		},
+		{
+			// A resolution recorded for other candidates does not apply
+			name:       "resolution of a different tie ignored",
+			candidates: []string{"a", "b", "c"},
+			ballots:    concat(ballots(3, "a"), ballots(2, "b", "a"), ballots(2, "c", "b")),
+			resolved: map[int]TieResolution{
+				1: {Policy: TieByLot, Tied: []string{"a", "b"}, Winners: []string{"a"}, Round: 1},
+			},
+			winners: []string{},
+			tie:     &ContestTie{Candidates: []string{"b", "c"}, Votes: 2, SeatsOpen: 1, Round: 1},
+		},
+		{
+			name:       "no ballots",
+			candidates: []string{"a", "b"},
+			winners:    []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := CountInstantRunoff(tt.candidates, tt.ballots, tt.resolved)
+			if !reflect.DeepEqual(result.Winners, tt.winners) {
+				t.Errorf("winners = %v, want %v", result.Winners, tt.winners)
+			}
+			if got := eliminated(result); !reflect.DeepEqual(got, tt.eliminated) {
+				t.Errorf("eliminated = %v, want %v", got, tt.eliminated)
+			}
+			if !reflect.DeepEqual(result.Tie, tt.tie) {
+				t.Errorf("tie = %+v, want %+v", result.Tie, tt.tie)
+			}
+			if n := len(result.Rounds); n > 0 && result.Rounds[n-1].Exhausted != tt.exhausted {
+				t.Errorf("exhausted = %v, want %v", result.Rounds[n-1].Exhausted, tt.exhausted)
+			}
+			if result.TotalBallots != len(tt.ballots) {
+				t.Errorf("total ballots = %d, want %d", result.TotalBallots, len(tt.ballots))
+			}
+		})
+	}
+}
+
+// The food election: 20 voters fill 3 seats with a Droop quota of 6.
+// Chocolate's surplus of 6 passes on at half value, Pear is eliminated to
+// elect Orange, and Strawberry takes the last seat once Sweets is out.
+func foodElection() []Ballot {
+	return concat(
+		ballots(4, "orange"),
+		ballots(2, "pear", "orange"),
+		ballots(8, "chocolate", "strawberry"),
+		ballots(4, "chocolate", "sweets"),
+		ballots(1, "strawberry"),
+		ballots(1, "sweets"),
+	)
+}
+
+func TestCountSingleTransferable(t *testing.T) {
+	food := []string{"orange", "pear", "chocolate", "strawberry", "sweets"}
+	tests := []struct {
+		name       string
+		candidates []string
+		ballots    []Ballot
+		seats      int
+		resolved   map[int]TieResolution
+		quota      float64
+		winners    []string
+		eliminated []string
+		tie        *ContestTie
+	}{
+		{
+			name:       "food election",
+			candidates: food,
+			ballots:    foodElection(),
+			seats:      3,
+			quota:      6,
+			winners:    []string{"chocolate", "orange", "strawberry"},
+			eliminated: []string{"pear", "sweets"},
+		},
+		{
+			// a's surplus of 3 passes to b at 3/7 of a vote per ballot
+			name:       "fractional surplus elects the next candidate",
+			candidates: []string{"a", "b", "c"},
+			ballots:    concat(ballots(7, "a", "b"), ballots(2, "b"), ballots(1, "c")),
+			seats:      2,
+			quota:      4,
+			winners:    []string{"a", "b"},
+		},
+		{
+			name:       "several candidates reach the quota at once",
+			candidates: []string{"a", "b", "c"},
+			ballots:    concat(ballots(6, "a"), ballots(5, "b"), ballots(1, "c")),
+			seats:      2,
+			quota:      5,
+			winners:    []string{"a", "b"},
+		},
+		{
+			name:       "no more candidates than seats",
+			candidates: []string{"a", "b"},
+			ballots:    concat(ballots(3, "a"), ballots(1, "b")),
+			seats:      2,
+			quota:      2,
+			winners:    []string{"a", "b"},
+		},
+		{
+			// Ballots without choices are not valid for the quota
+			name:       "empty ballots do not count towards the quota",
+			candidates: []string{"a", "b", "c"},
+			ballots:    concat(ballots(3, "a"), ballots(2, "b"), ballots(1, "c"), []Ballot{{}, {}}),
+			seats:      1,
+			quota:      4,
+			winners:    []string{"a"},
+			eliminated: []string{"c", "b"},
+		},
+		{
+			name:       "unbroken last place tie stops the count",
+			candidates: []string{"a", "b", "c", "d"},
+			ballots:    concat(ballots(4, "a"), ballots(3, "b"), ballots(2, "c", "b"), ballots(2, "d", "a")),
+			seats:      2,
+			quota:      4,
+			winners:    []string{"a"},
+			tie:        &ContestTie{Candidates: []string{"c", "d"}, Votes: 2, SeatsOpen: 1, Round: 2},
+		},
+		{
+			name:       "resolved last place tie",
+			candidates: []string{"a", "b", "c", "d"},
+			ballots:    concat(ballots(4, "a"), ballots(3, "b"), ballots(2, "c", "b"), ballots(2, "d", "a")),
+			seats:      2,
+			resolved: map[int]TieResolution{
+				2: {Policy: TieByManual, Tied: []string{"c", "d"}, Winners: []string{"d"}, Round: 2},
+			},
+			quota:      4,
+			winners:    []string{"a", "b"},
+			eliminated: []string{"c"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := CountSingleTransferable(tt.candidates, tt.ballots, tt.seats, tt.resolved)
+			if result.Quota != tt.quota {
+				t.Errorf("quota = %v, want %v", result.Quota, tt.quota)
+			}
+			if !reflect.DeepEqual(result.Winners, tt.winners) {
+				t.Errorf("winners = %v, want %v", result.Winners, tt.winners)
+			}
+			if got := eliminated(result); !reflect.DeepEqual(got, tt.eliminated) {
+				t.Errorf("eliminated = %v, want %v", got, tt.eliminated)
+			}
+			if !reflect.DeepEqual(result.Tie, tt.tie) {
+				t.Errorf("tie = %+v, want %+v", result.Tie, tt.tie)
+			}
+		})
+	}
+}
+
+func TestCountSingleTransferableRounds(t *testing.T) {
+	result := CountSingleTransferable([]string{"orange", "pear", "chocolate", "strawberry", "sweets"}, foodElection(), 3, nil)
+
+	want := []CountRound{
+		{
+			Round:   1,
+			Tallies: map[string]float64{"orange": 4, "pear": 2, "chocolate": 12, "strawberry": 1, "sweets": 1},
+			Elected: []string{"chocolate"},
+			Transfers: []Transfer{
+				{From: "chocolate", To: "strawberry", Votes: 4, Reason: "surplus"},
+				{From: "chocolate", To: "sweets", Votes: 2, Reason: "surplus"},
+			},
+		},
+		{
+			Round:      2,
+			Tallies:    map[string]float64{"orange": 4, "pear": 2, "strawberry": 5, "sweets": 3},
+			Eliminated: []string{"pear"},
+			Transfers:  []Transfer{{From: "pear", To: "orange", Votes: 2, Reason: "elimination"}},
+		},
+		{
+			Round:   3,
+			Tallies: map[string]float64{"orange": 6, "strawberry": 5, "sweets": 3},
+			Elected: []string{"orange"},
+		},
+		{
+			Round:      4,
+			Tallies:    map[string]float64{"strawberry": 5, "sweets": 3},
+			Eliminated: []string{"sweets"},
+			Transfers:  []Transfer{{From: "sweets", To: "", Votes: 3, Reason: "elimination"}},
+		},
+		{
+			Round:     5,
+			Tallies:   map[string]float64{"strawberry": 5},
+			Exhausted: 3,
+			Elected:   []string{"strawberry"},
+			Note:      "remaining candidates elected to fill open seats",
+		},
+	}
+	if len(result.Rounds) != len(want) {
+		t.Fatalf("got %d rounds, want %d: %+v", len(result.Rounds), len(want), result.Rounds)
+	}
+	for i, round := range result.Rounds {
+		if len(round.Transfers) == 0 {
+			round.Transfers = nil
+		}
+		if !reflect.DeepEqual(round, want[i]) {
+			t.Errorf("round %d = %+v, want %+v", i+1, round, want[i])
+		}
+	}
+}
+
+// Surplus values are kept to six decimal places however often they are
+// divided
+func TestCountSingleTransferableFractionalTallies(t *testing.T) {
+	result := CountSingleTransferable([]string{"a", "b", "c"},
+		concat(ballots(7, "a", "b"), ballots(2, "b"), ballots(1, "c")), 2, nil)
+	if len(result.Rounds) < 2 {
+		t.Fatalf("got %d rounds, want at least 2", len(result.Rounds))
+	}
+	transfers := result.Rounds[0].Transfers
+	if len(transfers) != 1 || transfers[0].To != "b" || transfers[0].Votes != 3 {
+		t.Errorf("round 1 transfers = %+v, want 3 votes from a to b", transfers)
+	}
+	if got := result.Rounds[1].Tallies["b"]; got != 5 {
+		t.Errorf("b has %v votes in round 2, want 5", got)
+	}
+}
@@ -0,0 +1,43 @@
+package contracts
+
+// Recount tallies the given ballots, such as ones rebuilt from the audit
+// trail, against the election's candidates, contests and ballot settings.
+// Ballots must be in the order they were cast. Ballots that fail validation,
+// and repeat ballots when vote revision is off, are left out and counted in
+// rejected. The election itself is left unchanged.
+func (e *Election) Recount(ballots []Ballot) (results []ContestResult, rejected int) {
+	e.initializeMaps()
+
+	shadow := *e
+	shadow.Candidates = make(map[string]Candidate, len(e.Candidates))
+	for id, c := range e.Candidates {
+		shadow.Candidates[id] = c
+	}
+
+	strategy := e.Strategy()
+	voted := make(map[string]bool, len(ballots))
+	shadow.Ballots = make([]Ballot, 0, len(ballots))
+	for _, b := range ballots {
+		normalizeSpecial(&b)
+		if b.votesInRace() {
+			if err := strategy.Validate(&shadow, b); err != nil {
+				rejected++
+				continue
+			}
+		}
+		if voted[b.VoterID] && !e.AllowRevision {
+			rejected++
+			continue
+		}
+
+		b.Sequence = len(shadow.Ballots) + 1
+		b.Supersedes, b.SupersededBy = 0, 0
+		if voted[b.VoterID] {
+			b.Supersedes = shadow.supersedePrevious(b.VoterID, b.Sequence)
+		}
+		shadow.Ballots = append(shadow.Ballots, b)
+		voted[b.VoterID] = true
+	}
+	shadow.rebuildRunningTotals()
+	return shadow.ContestResults(), rejected
+}
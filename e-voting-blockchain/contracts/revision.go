@@ -0,0 +1,68 @@
+package contracts
+
+import "errors"
+
+// Vote revision lets a voter cast again while polls are open, so a ballot
+// cast under pressure can later be replaced in private. Every ballot is kept
+// and logged; only the voter's last ballot is counted.
+//
+// Ordering rule: each ballot gets the next Sequence number when it is cast,
+// and ballots are logged to the chain in the same order. A voter's ballot
+// with the highest sequence supersedes all earlier ones. Ballots cannot be
+// cast once polls close, so the last one is always the last before close.
+
+// ConfigureRevision turns vote revision on or off. Like the rest of the
+// ballot it is fixed once voting opens.
+func (e *Election) ConfigureRevision(allow bool) error {
+	if !e.ballotEditable() {
+		return errors.New("cannot change vote revision once voting has opened")
+	}
+	e.AllowRevision = allow
+	return nil
+}
+
+// supersedePrevious marks the voter's current ballot as replaced by the
+// ballot with the given sequence number
+func (e *Election) supersedePrevious(voterID string, sequence int) (previous int) {
+	for i := range e.Ballots {
+		b := &e.Ballots[i]
+		if b.VoterID == voterID && b.SupersededBy == 0 {
+			b.SupersededBy = sequence
+			previous = b.Sequence
+		}
+	}
+	return previous
+}
+
+// countedBallots returns the ballots that count: all of them, less any
+// that a later ballot from the same voter has superseded. Adjudicated
+// write-ins are returned as choices of their candidate.
+func (e *Election) countedBallots() []Ballot {
+	ballots := make([]Ballot, 0, len(e.Ballots))
+	for _, b := range e.Ballots {
+		if b.SupersededBy == 0 {
+			ballots = append(ballots, e.withWriteIn(b))
+		}
+	}
+	return ballots
+}
+
+// SupersededBallots returns the number of ballots replaced by a revision
+func (e *Election) SupersededBallots() int {
+	return len(e.Ballots) - len(e.countedBallots())
+}
+
+// rebuildRunningTotals recomputes each candidate's running Votes total from
+// the counted ballots, after a revision has taken a ballot out of the count
+func (e *Election) rebuildRunningTotals() {
+	for id, c := range e.Candidates {
+		c.Votes = 0
+		e.Candidates[id] = c
+	}
+	strategy := e.Strategy()
+	for _, b := range e.countedBallots() {
+		if b.votesInRace() {
+			strategy.Record(e, b)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package contracts
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// A runoff is a second round held when the first round of the candidate race
+// produces no winner: either no candidate passed the runoff threshold or a
+// tie was resolved by runoff. The first round is archived to its own file and
+// a new election with the advancing candidates and the same electorate takes
+// its place. Each round links to the other.
+
+// RunoffRule triggers a runoff when no candidate passes the threshold
+type RunoffRule struct {
+	// Threshold is the share of the votes a winner needs, as a percentage.
+	// A candidate must get more than it, so 50 means 50% plus one vote.
+	Threshold float64 `json:"threshold"`
+	// Candidates is how many leading candidates go through, at least two.
+	// Candidates tied for the last place all go through.
+	Candidates int `json:"candidates"`
+}
+
+// RunoffTrigger is the first-round result that calls for a runoff
+type RunoffTrigger struct {
+	Round        int      `json:"round"`
+	Reason       string   `json:"reason"`
+	Candidates   []string `json:"candidates"`
+	Seats        int      `json:"seats"`
+	Threshold    float64  `json:"threshold,omitempty"`
+	LeaderShare  float64  `json:"leaderShare,omitempty"`
+	DocumentHash string   `json:"documentHash,omitempty"` // Certified result of the round
+}
+
+// RoundLink connects one round of an election to another
+type RoundLink struct {
+	Round                int       `json:"round"`
+	File                 string    `json:"file,omitempty"` // Where an archived round is kept
+	Description          string    `json:"description"`
+	Trigger              string    `json:"trigger"`
+	DocumentHash         string    `json:"documentHash,omitempty"`
+	TriggerTransactionID string    `json:"triggerTransactionId,omitempty"`
+	TransactionID        string    `json:"transactionId,omitempty"` // The RUNOFF_CREATED transaction
+	CreatedAt            time.Time `json:"createdAt"`
+}
+
+// RoundFile names the file an archived round of an election is kept in. The
+// first election's rounds keep the names they had before elections were
+// numbered.
+func RoundFile(number, round int) string {
+	if number <= 1 {
+		return fmt.Sprintf("election_round%d.json", round)
+	}
+	return fmt.Sprintf("election%d_round%d.json", number, round)
+}
+
+// GetRound returns the election's round number, 1 for a first round
+func (e *Election) GetRound() int {
+	if e.Round < 1 {
+		return 1
+	}
+	return e.Round
+}
+
+// ConfigureRunoff sets or clears the runoff rule. Like the rest of the ballot
+// it is fixed once voting opens, and it only applies to single-seat races.
+func (e *Election) ConfigureRunoff(rule *RunoffRule) error {
+	if !e.ballotEditable() {
+		return errors.New("cannot change the runoff rule once voting has opened")
+	}
+	if rule != nil {
+		if e.GetSeats() != 1 {
+			return errors.New("a runoff threshold only applies to single-seat elections")
+		}
+		if rule.Threshold <= 0 || rule.Threshold >= 100 {
+			return errors.New("runoff threshold must be between 0 and 100 percent")
+		}
+		if rule.Candidates == 0 {
+			rule.Candidates = 2
+		}
+		if rule.Candidates < 2 {
+			return errors.New("a runoff needs at least two candidates")
+		}
+	}
+	e.Runoff = rule
+	return nil
+}
+
+// applyRunoffRule sends the race to a runoff when no candidate passes the
+// runoff threshold, naming the candidates who advance. It reports whether it
+// did; a tie among the leaders is then settled by the runoff itself.
+func (e *Election) applyRunoffRule(race *ContestResult, tallies map[string]float64, seats int) bool {
+	if e.Runoff == nil || seats != 1 {
+		return false
+	}
+	total, top := 0.0, 0.0
+	ids := make([]string, 0, len(tallies))
+	for id, v := range tallies {
+		total += v
+		if v > 0 {
+			ids = append(ids, id)
+		}
+		if v > top {
+			top = v
+		}
+	}
+	if total == 0 || len(ids) < 2 || percentage(top, total) > e.Runoff.Threshold {
+		return false
+	}
+
+	sortByTally(ids, tallies)
+	cut := e.Runoff.Candidates
+	if cut > len(ids) {
+		cut = len(ids)
+	}
+	for cut < len(ids) && tallies[ids[cut]] == tallies[ids[cut-1]] {
+		cut++
+	}
+	race.Seats = seats
+	race.Winners = []string{}
+	race.Runoff = ids[:cut]
+	return true
+}
+
+// RunoffTrigger returns the certified first-round result that calls for a
+// runoff, or nil when the race was decided or a runoff already exists
+func (e *Election) RunoffTrigger() *RunoffTrigger {
+	if e.Certification == nil || e.NextRound != nil {
+		return nil
+	}
+	for _, c := range e.Certification.Document.Contests {
+		if c.ContestID != CandidateRaceID || len(c.Runoff) == 0 {
+			continue
+		}
+		trigger := &RunoffTrigger{
+			Round:        e.GetRound(),
+			Candidates:   c.Runoff,
+			Seats:        c.Seats - len(c.Winners),
+			DocumentHash: e.Certification.DocumentHash,
+		}
+		if c.Tie != nil {
+			trigger.Reason = fmt.Sprintf("tie between %s resolved by runoff", strings.Join(c.Runoff, ", "))
+		} else {
+			var leader float64
+			if len(c.Count.Rounds) > 0 {
+				tallies := c.Count.Rounds[len(c.Count.Rounds)-1].Tallies
+				total := 0.0
+				for _, v := range tallies {
+					total += v
+					if v > leader {
+						leader = v
+					}
+				}
+				leader = percentage(leader, total)
+			}
+			trigger.Threshold = e.Runoff.Threshold
+			trigger.LeaderShare = leader
+			trigger.Reason = fmt.Sprintf("no candidate passed %g%% of the vote; the leader had %g%%", e.Runoff.Threshold, leader)
+		}
+		return trigger
+	}
+	return nil
+}
+
+// NewRunoffRound builds the next round from a runoff trigger: the advancing
+// candidates with their votes cleared, the same parties, the same electorate
+// with nobody marked as having voted, and the same ballot settings. The new round starts as a draft so officials can
+// open it when ready. The election is left unchanged until LinkRunoff.
+func (e *Election) NewRunoffRound(trigger RunoffTrigger, at time.Time) (*Election, error) {
+	e.initializeMaps()
+
+	if len(trigger.Candidates) < 2 {
+		return nil, errors.New("a runoff needs at least two candidates")
+	}
+
+	next := NewElection()
+	next.storage = e.storage
+	next.Number = e.Number
+	next.PreviousElection = e.PreviousElection
+	next.Round = e.GetRound() + 1
+	next.Status.Description = "Runoff: " + strings.TrimPrefix(e.Status.Description, "Runoff: ")
+	for id, p := range e.Parties {
+		next.Parties[id] = p
+	}
+	next.copyElectorate(e)
+	for _, id := range trigger.Candidates {
+		c, exists := e.Candidates[id]
+		if !exists {
+			return nil, fmt.Errorf("candidate %s not found", id)
+		}
+		c.Votes = 0
+		c.Status = CandidateApproved
+		c.StatusReason = ""
+		c.StatusChangedAt = at
+		next.Candidates[id] = c
+	}
+
+	next.BallotType = e.GetBallotType()
+	next.Seats = trigger.Seats
+	if !e.Strategy().MultiSeat() || next.Seats < 1 {
+		next.Seats = 1
+	}
+	next.MaxScore = e.MaxScore
+	next.SpecialOptions = e.SpecialOptions
+	next.AllowRevision = e.AllowRevision
+	next.WithdrawnPolicy = e.WithdrawnPolicy
+	next.ResultVisibility = e.ResultVisibility
+	next.NominationRules = e.NominationRules
+	next.TiePolicy = e.TiePolicy
+
+	next.PreviousRound = &RoundLink{
+		Round:        e.GetRound(),
+		File:         RoundFile(e.GetNumber(), e.GetRound()),
+		Description:  e.Status.Description,
+		Trigger:      trigger.Reason,
+		DocumentHash: trigger.DocumentHash,
+		CreatedAt:    at,
+	}
+	return next, nil
+}
+
+// LinkRunoff records the runoff round on this election and the creation
+// transaction on both rounds' links
+func (e *Election) LinkRunoff(next *Election, triggerTxID, txID string) {
+	next.PreviousRound.TriggerTransactionID = triggerTxID
+	next.PreviousRound.TransactionID = txID
+	e.NextRound = &RoundLink{
+		Round:                next.GetRound(),
+		Description:          next.Status.Description,
+		Trigger:              next.PreviousRound.Trigger,
+		DocumentHash:         next.PreviousRound.DocumentHash,
+		TriggerTransactionID: triggerTxID,
+		TransactionID:        txID,
+		CreatedAt:            next.PreviousRound.CreatedAt,
+	}
+}
+
+// SaveRound archives the election to its round file
+func (e *Election) SaveRound() error {
+	data, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return err
+	}
+	return e.storage.WriteFile(RoundFile(e.GetNumber(), e.GetRound()), data, 0644)
+}
+
+// LoadRound reads an archived round of the given election
+func (s *Storage) LoadRound(number, round int) (*Election, error) {
+	data, err := s.ReadFile(RoundFile(number, round))
+	if err != nil {
+		return nil, err
+	}
+	var e Election
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, err
+	}
+	e.initializeMaps()
+	e.migrateState()
+	e.storage = s
+	return &e, nil
+}
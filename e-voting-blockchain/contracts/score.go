@@ -0,0 +1,92 @@
+package contracts
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// ScoreRow is a candidate's line in a score count
+type ScoreRow struct {
+	CandidateID  string      `json:"candidateId"`
+	Total        int         `json:"total"`
+	Average      float64     `json:"average"` // Mean over every ballot; unrated counts as 0
+	Ratings      int         `json:"ratings"` // Ballots that rated the candidate explicitly
+	Distribution map[int]int `json:"distribution"`
+}
+
+// scoreStrategy lets voters rate each candidate from 0 to the election's
+// maximum score. The candidates with the highest total score win.
+type scoreStrategy struct{}
+
+func (scoreStrategy) Type() BallotType { return BallotScore }
+func (scoreStrategy) MultiSeat() bool  { return true }
+
+func (scoreStrategy) Validate(e *Election, b Ballot) error {
+	if len(b.Scores) == 0 {
+		return errors.New("score ballot must rate at least one candidate")
+	}
+	if len(b.Choices) > 0 {
+		return errors.New("score ballot cannot carry choices")
+	}
+	max := e.GetMaxScore()
+	for id, score := range b.Scores {
+		if _, ok := e.Candidates[id]; !ok {
+			return errors.New("invalid candidate")
+		}
+		if score < 0 || score > max {
+			return fmt.Errorf("score for %s must be between 0 and %d", id, max)
+		}
+	}
+	return nil
+}
+
+func (scoreStrategy) Record(e *Election, b Ballot) {
+	for id, score := range b.Scores {
+		addVotes(e, id, score)
+	}
+}
+
+func (scoreStrategy) Count(e *Election) CountResult {
+	ballots := e.raceBallots()
+	ids := e.candidateIDs()
+	rows := make(map[string]*ScoreRow, len(ids))
+	for _, id := range ids {
+		rows[id] = &ScoreRow{CandidateID: id, Distribution: make(map[int]int)}
+	}
+	for _, b := range ballots {
+		for id, score := range b.Scores {
+			row, ok := rows[id]
+			if !ok {
+				continue
+			}
+			row.Total += score
+			row.Ratings++
+			row.Distribution[score]++
+		}
+	}
+
+	tallies := make(map[string]float64, len(rows))
+	for id, row := range rows {
+		tallies[id] = float64(row.Total)
+		if len(ballots) > 0 {
+			row.Average = math.Round(float64(row.Total)/float64(len(ballots))*100) / 100
+		}
+	}
+
+	sortByTally(ids, tallies)
+	breakdown := make([]ScoreRow, 0, len(ids))
+	for _, id := range ids {
+		breakdown = append(breakdown, *rows[id])
+	}
+
+	winners := topWinners(tallies, e.GetSeats())
+	return CountResult{
+		Method:       BallotScore,
+		Seats:        e.GetSeats(),
+		TotalBallots: len(ballots),
+		Winners:      winners,
+		Rounds:       []CountRound{{Round: 1, Tallies: tallies, Elected: winners}},
+		Breakdown:    breakdown,
+	}
+}
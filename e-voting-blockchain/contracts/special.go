@@ -0,0 +1,136 @@
+package contracts
+
+import (
+	"errors"
+	"fmt"
+)
+
+// SpecialOption is a system-defined choice in the candidate race that is not
+// a candidate. Special options are counted and reported on their own and
+// never take part in winner determination.
+type SpecialOption string
+
+const (
+	OptionNOTA    SpecialOption = "NOTA"    // None of the above
+	OptionAbstain SpecialOption = "ABSTAIN" // Explicit abstention
+)
+
+// specialOptionLabels are the ballot labels of the special options, in the
+// order they are reported
+var specialOptionLabels = []struct {
+	Option SpecialOption
+	Label  string
+}{
+	{OptionNOTA, "None of the above"},
+	{OptionAbstain, "Abstain"},
+}
+
+// SpecialOptionResult is the number of ballots that chose a special option
+type SpecialOptionResult struct {
+	Option SpecialOption `json:"option"`
+	Label  string        `json:"label"`
+	Votes  int           `json:"votes"`
+	Share  float64       `json:"share"` // Percentage of ballots cast in the race, special options included
+}
+
+// IsSpecialOption reports whether an ID names a special option
+func IsSpecialOption(id string) bool {
+	_, err := ParseSpecialOption(id)
+	return err == nil
+}
+
+// ParseSpecialOption validates a special option name
+func ParseSpecialOption(s string) (SpecialOption, error) {
+	for _, o := range specialOptionLabels {
+		if string(o.Option) == s {
+			return o.Option, nil
+		}
+	}
+	return "", fmt.Errorf("unknown ballot option %q", s)
+}
+
+// SpecialOptionEnabled reports whether the election offers a special option
+func (e *Election) SpecialOptionEnabled(option SpecialOption) bool {
+	for _, o := range e.SpecialOptions {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
+
+// ConfigureSpecialOptions sets which special options appear on the ballot.
+// Like the rest of the ballot, they are fixed once voting opens.
+func (e *Election) ConfigureSpecialOptions(options []SpecialOption) error {
+	if !e.ballotEditable() {
+		return errors.New("cannot change ballot options once voting has opened")
+	}
+	enabled := make([]SpecialOption, 0, len(options))
+	for _, o := range specialOptionLabels {
+		for _, requested := range options {
+			if requested == o.Option {
+				enabled = append(enabled, o.Option)
+				break
+			}
+		}
+	}
+	if len(enabled) == 0 {
+		enabled = nil
+	}
+	e.SpecialOptions = enabled
+	return nil
+}
+
+// normalizeSpecial moves a special option given as the only choice, as
+// single-choice clients send it, to the ballot's Special field
+func normalizeSpecial(b *Ballot) {
+	if b.Special == "" && len(b.Choices) == 1 && len(b.Scores) == 0 && IsSpecialOption(b.Choices[0]) {
+		b.Special = SpecialOption(b.Choices[0])
+		b.Choices = nil
+	}
+}
+
+// validateSpecial checks a special option choice: it must be offered and
+// replaces any candidate selection in the race
+func (e *Election) validateSpecial(b Ballot) error {
+	if b.Special == "" {
+		return nil
+	}
+	if !e.SpecialOptionEnabled(b.Special) {
+		return fmt.Errorf("ballot option %s is not offered in this election", b.Special)
+	}
+	if b.votesInRace() {
+		return fmt.Errorf("ballot option %s cannot be combined with candidate choices", b.Special)
+	}
+	return nil
+}
+
+// SpecialOptionResults counts the ballots choosing each enabled special
+// option, and any option chosen on ballots even if no longer enabled
+func (e *Election) SpecialOptionResults() []SpecialOptionResult {
+	votes := make(map[SpecialOption]int)
+	inRace := 0
+	for _, b := range e.countedBallots() {
+		switch {
+		case b.Special != "":
+			votes[b.Special]++
+			inRace++
+		case b.votesInRace():
+			inRace++
+		}
+	}
+
+	results := []SpecialOptionResult{}
+	for _, o := range specialOptionLabels {
+		if !e.SpecialOptionEnabled(o.Option) && votes[o.Option] == 0 {
+			continue
+		}
+		results = append(results, SpecialOptionResult{
+			Option: o.Option,
+			Label:  o.Label,
+			Votes:  votes[o.Option],
+			Share:  percentage(float64(votes[o.Option]), float64(inRace)),
+		})
+	}
+	return results
+}
@@ -0,0 +1,69 @@
+package contracts
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// errNoStorage is returned when something built in memory, not loaded from
+// a Storage, is saved
+var errNoStorage = errors.New("no data directory to save to")
+
+// Storage is the data directory every data file is kept in. The election,
+// accounts and other records loaded from it remember it and are saved back
+// to it.
+type Storage struct {
+	dir string
+}
+
+// NewStorage keeps data files in the given directory
+func NewStorage(dir string) *Storage {
+	return &Storage{dir: dir}
+}
+
+// Path is the path of a data file in the data directory
+func (s *Storage) Path(name string) string {
+	return filepath.Join(s.dir, name)
+}
+
+// ReadFile reads a data file
+func (s *Storage) ReadFile(name string) ([]byte, error) {
+	if s == nil {
+		return nil, errNoStorage
+	}
+	return os.ReadFile(s.Path(name))
+}
+
+// WriteFile replaces a data file. The data is written to a temporary file
+// that is synced and renamed over the old one, so a crash or shutdown part
+// way through leaves either the old file or the new one, never a partial
+// one.
+func (s *Storage) WriteFile(name string, data []byte, perm os.FileMode) error {
+	if s == nil {
+		return errNoStorage
+	}
+	path := s.Path(name)
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
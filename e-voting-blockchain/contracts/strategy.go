@@ -0,0 +1,182 @@
+package contracts
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// CountingStrategy validates, records and counts ballots for one ballot type.
+// New ballot types are added by implementing this interface and registering
+// the implementation with RegisterStrategy.
+type CountingStrategy interface {
+	// Type is the ballot type the strategy handles
+	Type() BallotType
+	// MultiSeat reports whether the strategy can fill more than one seat
+	MultiSeat() bool
+	// Validate checks that a ballot is well formed for this ballot type
+	Validate(e *Election, b Ballot) error
+	// Record adds a validated ballot to the candidates' running Votes totals
+	Record(e *Election, b Ballot)
+	// Count produces the result, including the strategy's own breakdown
+	Count(e *Election) CountResult
+}
+
+var strategies = make(map[BallotType]CountingStrategy)
+
+func init() {
+	RegisterStrategy(pluralityStrategy{})
+	RegisterStrategy(instantRunoffStrategy{})
+	RegisterStrategy(singleTransferableStrategy{})
+	RegisterStrategy(approvalStrategy{})
+	RegisterStrategy(scoreStrategy{})
+}
+
+// RegisterStrategy makes a counting strategy available to elections
+func RegisterStrategy(s CountingStrategy) {
+	strategies[s.Type()] = s
+}
+
+// StrategyFor looks up the counting strategy for a ballot type
+func StrategyFor(bt BallotType) (CountingStrategy, error) {
+	s, ok := strategies[bt]
+	if !ok {
+		return nil, fmt.Errorf("unknown ballot type %q", bt)
+	}
+	return s, nil
+}
+
+// PluralityRow is a candidate's share of a single-choice count
+type PluralityRow struct {
+	CandidateID string  `json:"candidateId"`
+	Votes       int     `json:"votes"`
+	Share       float64 `json:"share"` // Percentage of ballots cast
+}
+
+// pluralityStrategy is first-past-the-post: one candidate per ballot and the
+// candidate with the most votes wins
+type pluralityStrategy struct{}
+
+func (pluralityStrategy) Type() BallotType { return BallotSingleChoice }
+func (pluralityStrategy) MultiSeat() bool  { return false }
+
+func (pluralityStrategy) Validate(e *Election, b Ballot) error {
+	if len(b.Choices) != 1 {
+		return errors.New("single choice ballot must select exactly one candidate")
+	}
+	return e.validateCandidateIDs(b.Choices)
+}
+
+func (pluralityStrategy) Record(e *Election, b Ballot) {
+	addVotes(e, b.Choices[0], 1)
+}
+
+// Count uses the stored candidate totals, which also covers elections saved
+// before individual ballots were kept
+func (pluralityStrategy) Count(e *Election) CountResult {
+	ids := e.candidateIDs()
+	tallies := make(map[string]float64, len(ids))
+	total := 0
+	for _, id := range ids {
+		votes := e.Candidates[id].Votes
+		tallies[id] = float64(votes)
+		total += votes
+	}
+
+	sortByTally(ids, tallies)
+	rows := make([]PluralityRow, 0, len(ids))
+	for _, id := range ids {
+		rows = append(rows, PluralityRow{
+			CandidateID: id,
+			Votes:       e.Candidates[id].Votes,
+			Share:       percentage(tallies[id], float64(total)),
+		})
+	}
+
+	result := CountResult{
+		Method:       BallotSingleChoice,
+		Seats:        1,
+		TotalBallots: total,
+		Winners:      []string{},
+		Rounds:       []CountRound{{Round: 1, Tallies: tallies}},
+		Breakdown:    rows,
+	}
+	if winner, ok := leader(tallies); ok {
+		result.Winners = []string{winner}
+		result.Rounds[0].Elected = []string{winner}
+	}
+	return result
+}
+
+// rankedValidate checks a preference list: at least one candidate, no repeats
+func rankedValidate(e *Election, b Ballot) error {
+	if len(b.Choices) == 0 {
+		return errors.New("ballot has no choices")
+	}
+	return e.validateCandidateIDs(b.Choices)
+}
+
+// instantRunoffStrategy counts ranked ballots for a single winner
+type instantRunoffStrategy struct{}
+
+func (instantRunoffStrategy) Type() BallotType { return BallotInstantRunoff }
+func (instantRunoffStrategy) MultiSeat() bool  { return false }
+
+func (instantRunoffStrategy) Validate(e *Election, b Ballot) error { return rankedValidate(e, b) }
+
+// Record counts the first preference so the candidate list shows first-round totals
+func (instantRunoffStrategy) Record(e *Election, b Ballot) { addVotes(e, b.Choices[0], 1) }
+
+func (instantRunoffStrategy) Count(e *Election) CountResult {
+	return CountInstantRunoff(e.candidateIDs(), e.raceBallots(), e.countResolutions())
+}
+
+// singleTransferableStrategy counts ranked ballots for several seats
+type singleTransferableStrategy struct{}
+
+func (singleTransferableStrategy) Type() BallotType { return BallotSingleTransferable }
+func (singleTransferableStrategy) MultiSeat() bool  { return true }
+
+func (singleTransferableStrategy) Validate(e *Election, b Ballot) error { return rankedValidate(e, b) }
+
+// Record counts the first preference so the candidate list shows first-round totals
+func (singleTransferableStrategy) Record(e *Election, b Ballot) { addVotes(e, b.Choices[0], 1) }
+
+func (singleTransferableStrategy) Count(e *Election) CountResult {
+	return CountSingleTransferable(e.candidateIDs(), e.raceBallots(), e.GetSeats(), e.countResolutions())
+}
+
+// addVotes adjusts a candidate's running Votes total
+func addVotes(e *Election, candidateID string, n int) {
+	c := e.Candidates[candidateID]
+	c.Votes += n
+	e.Candidates[candidateID] = c
+}
+
+// topWinners returns the seats highest-scoring candidates, or fewer when a
+// tie straddles the last seat so that no arbitrary winner is declared
+func topWinners(tallies map[string]float64, seats int) []string {
+	ids := make([]string, 0, len(tallies))
+	for id, v := range tallies {
+		if v > 0 {
+			ids = append(ids, id)
+		}
+	}
+	sortByTally(ids, tallies)
+	if len(ids) <= seats {
+		return ids
+	}
+	cut := seats
+	for cut > 0 && tallies[ids[cut-1]] == tallies[ids[seats]] {
+		cut--
+	}
+	return ids[:cut]
+}
+
+// percentage returns part as a percentage of whole, rounded to two places
+func percentage(part, whole float64) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return math.Round(part/whole*10000) / 100
+}
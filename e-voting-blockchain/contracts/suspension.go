@@ -0,0 +1,137 @@
+package contracts
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrElectionPaused is returned when a ballot is cast while voting is paused
+var ErrElectionPaused = errors.New("voting is paused")
+
+// MaxTotalExtension caps how far the original end time can be pushed back by
+// manual extensions. Time given back after a pause does not count towards it.
+const MaxTotalExtension = 72 * time.Hour
+
+// Suspension records a period during which voting was paused. PausedBy and
+// ResumedBy are the admins who did so; the notes are whatever they wrote
+// about who else agreed, and are not verified.
+type Suspension struct {
+	PausedAt     time.Time `json:"pausedAt"`
+	Reason       string    `json:"reason"`
+	PausedBy     string    `json:"pausedBy"`
+	Note         string    `json:"note,omitempty"`
+	ResumedAt    time.Time `json:"resumedAt,omitempty"`
+	ResumeReason string    `json:"resumeReason,omitempty"`
+	ResumedBy    string    `json:"resumedBy,omitempty"`
+	ResumeNote   string    `json:"resumeNote,omitempty"`
+}
+
+// Extension records a manual change to the end of the voting window.
+// AuthorizedBy is the admin who made it; the note is not verified.
+type Extension struct {
+	At           time.Time `json:"at"`
+	PreviousEnd  time.Time `json:"previousEnd"`
+	NewEnd       time.Time `json:"newEnd"`
+	Reason       string    `json:"reason"`
+	AuthorizedBy string    `json:"authorizedBy"`
+	Note         string    `json:"note,omitempty"`
+}
+
+// ActiveSuspension returns the suspension in force while voting is paused
+func (e *Election) ActiveSuspension() (Suspension, bool) {
+	n := len(e.Status.Suspensions)
+	if e.State() != StatePaused || n == 0 {
+		return Suspension{}, false
+	}
+	return e.Status.Suspensions[n-1], true
+}
+
+// PauseVoting suspends voting. The reason and the admin pausing it are
+// required because both are recorded on the chain.
+func (e *Election) PauseVoting(reason, authorizedBy, note string, at time.Time) (StateTransition, error) {
+	if reason == "" || authorizedBy == "" {
+		return StateTransition{}, errors.New("a reason and the authorising admin are required to pause voting")
+	}
+	if e.State() != StateVoting {
+		return StateTransition{}, errors.New("voting is not in progress")
+	}
+	t, err := e.transition(StatePaused, reason, at)
+	if err != nil {
+		return StateTransition{}, err
+	}
+	e.Status.Suspensions = append(e.Status.Suspensions, Suspension{
+		PausedAt: at,
+		Reason:   reason,
+		PausedBy: authorizedBy,
+		Note:     note,
+	})
+	return t, nil
+}
+
+// ResumeVoting reopens paused voting. The time that was left in the voting
+// window when it was paused is restored from the moment voting resumes, so
+// voters do not lose polling time.
+func (e *Election) ResumeVoting(reason, authorizedBy, note string, at time.Time) (StateTransition, error) {
+	if reason == "" || authorizedBy == "" {
+		return StateTransition{}, errors.New("a reason and the authorising admin are required to resume voting")
+	}
+	suspension, paused := e.ActiveSuspension()
+	if !paused {
+		return StateTransition{}, errors.New("voting is not paused")
+	}
+	t, err := e.transition(StateVoting, reason, at)
+	if err != nil {
+		return StateTransition{}, err
+	}
+
+	// Voters get back whatever was left of the window when voting was paused
+	if remaining := e.Status.EndTime.Sub(suspension.PausedAt); remaining > 0 {
+		e.Status.EndTime = at.Add(remaining)
+	}
+
+	suspension.ResumedAt = at
+	suspension.ResumeReason = reason
+	suspension.ResumedBy = authorizedBy
+	suspension.ResumeNote = note
+	e.Status.Suspensions[len(e.Status.Suspensions)-1] = suspension
+	return t, nil
+}
+
+// ExtendVoting moves the end of the voting window to a later time
+func (e *Election) ExtendVoting(newEnd time.Time, reason, authorizedBy, note string, at time.Time) (Extension, error) {
+	if reason == "" || authorizedBy == "" {
+		return Extension{}, errors.New("a reason and the authorising admin are required to extend voting")
+	}
+	switch e.State() {
+	case StateScheduled, StateVoting, StatePaused:
+	default:
+		return Extension{}, fmt.Errorf("cannot extend an election that is %s", e.State())
+	}
+	if !newEnd.After(e.Status.EndTime) {
+		return Extension{}, errors.New("new end time must be later than the current end time")
+	}
+	if !newEnd.After(at) {
+		return Extension{}, errors.New("new end time must be in the future")
+	}
+
+	extended := newEnd.Sub(e.Status.EndTime)
+	for _, ext := range e.Status.Extensions {
+		extended += ext.NewEnd.Sub(ext.PreviousEnd)
+	}
+	if extended > MaxTotalExtension {
+		return Extension{}, fmt.Errorf("extensions cannot add more than %s in total", MaxTotalExtension)
+	}
+
+	ext := Extension{
+		At:           at,
+		PreviousEnd:  e.Status.EndTime,
+		NewEnd:       newEnd,
+		Reason:       reason,
+		AuthorizedBy: authorizedBy,
+		Note:         note,
+	}
+	e.Status.EndTime = newEnd
+	e.Status.Extensions = append(e.Status.Extensions, ext)
+	return ext, nil
+}
@@ -0,0 +1,307 @@
+package contracts
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TiePolicy decides how a tie for the last seats of a contest is resolved
+type TiePolicy string
+
+const (
+	// TieByLot draws lots from a random seed committed on the chain before
+	// voting opens and revealed when the lot is drawn
+	TieByLot TiePolicy = "lot"
+	// TieByRunoff sends the tied candidates to a runoff election
+	TieByRunoff TiePolicy = "runoff"
+	// TieByManual leaves the decision to an election officer, with a reason
+	TieByManual TiePolicy = "manual"
+)
+
+// ContestTie is a tie for the last seats of a contest: more candidates share
+// the highest remaining tally than there are seats left to fill. In a ranked
+// count it can also be a tie for last place that earlier rounds cannot break;
+// Round is then the count round and SeatsOpen how many of the tied
+// candidates stay in the count.
+type ContestTie struct {
+	Candidates []string       `json:"candidates"`
+	Votes      float64        `json:"votes"`     // Tally each tied candidate holds
+	SeatsOpen  int            `json:"seatsOpen"` // Seats the tie decides
+	Round      int            `json:"round,omitempty"`
+	Policy     TiePolicy      `json:"policy"`
+	Resolution *TieResolution `json:"resolution,omitempty"`
+}
+
+// TieResolution records how a tie was resolved. A lot reveals the seed and
+// the draw order so anyone can check it against the commitment on the chain.
+type TieResolution struct {
+	ContestID     string    `json:"contestId"`
+	Policy        TiePolicy `json:"policy"`
+	Tied          []string  `json:"tied"`
+	SeatsOpen     int       `json:"seatsOpen"`
+	Round         int       `json:"round,omitempty"`  // Count round of a last-place tie
+	Winners       []string  `json:"winners"`          // For a last-place tie, the candidates who stay in the count
+	Runoff        bool      `json:"runoff,omitempty"` // The tied candidates go to a runoff
+	Seed          string    `json:"seed,omitempty"`
+	Commitment    string    `json:"commitment,omitempty"`
+	DrawOrder     []string  `json:"drawOrder,omitempty"`
+	DecidedBy     string    `json:"decidedBy"`
+	Reason        string    `json:"reason,omitempty"`
+	TransactionID string    `json:"transactionId,omitempty"`
+	ResolvedAt    time.Time `json:"resolvedAt"`
+}
+
+// ParseTiePolicy validates a tie policy name
+func ParseTiePolicy(s string) (TiePolicy, error) {
+	switch p := TiePolicy(s); p {
+	case TieByLot, TieByRunoff, TieByManual:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown tie policy %q", s)
+}
+
+// GetTiePolicy returns the configured tie policy, manual unless set
+func (e *Election) GetTiePolicy() TiePolicy {
+	if e.TiePolicy == "" {
+		return TieByManual
+	}
+	return e.TiePolicy
+}
+
+// ConfigureTiePolicy sets the tie policy, which is fixed once voting opens.
+// Drawing lots needs a random seed; its SHA-256 commitment is returned so it
+// can be published before any ballot is cast.
+func (e *Election) ConfigureTiePolicy(p TiePolicy, seed string) (commitment string, err error) {
+	if !e.ballotEditable() {
+		return "", errors.New("cannot change the tie policy once voting has opened")
+	}
+	e.TiePolicy = p
+	e.TieSeed, e.TieSeedCommitment = "", ""
+	if p == TieByLot {
+		if seed == "" {
+			return "", errors.New("drawing lots needs a random seed")
+		}
+		e.TieSeed = seed
+		e.TieSeedCommitment = seedCommitment(seed)
+	}
+	return e.TieSeedCommitment, nil
+}
+
+// seedCommitment is the published hash of a lot seed
+func seedCommitment(seed string) string {
+	sum := sha256.Sum256([]byte(seed))
+	return hex.EncodeToString(sum[:])
+}
+
+// drawLots orders the tied candidates by the SHA-256 hash of
+// "seed:contestID:candidateID", lowest first. The first candidates in the
+// order take the open seats.
+func drawLots(seed, contestID string, tied []string) []string {
+	keys := make(map[string]string, len(tied))
+	for _, id := range tied {
+		sum := sha256.Sum256([]byte(seed + ":" + contestID + ":" + id))
+		keys[id] = hex.EncodeToString(sum[:])
+	}
+	order := append([]string(nil), tied...)
+	sort.Slice(order, func(i, j int) bool { return keys[order[i]] < keys[order[j]] })
+	return order
+}
+
+// decideWinners fills in a contest's winners from its final tallies and the
+// winners the count declared. When fewer winners than seats were declared
+// because of a tie, the tie is reported and any recorded resolution applied.
+func (e *Election) decideWinners(result *ContestResult, tallies map[string]float64, winners []string, seats int) {
+	result.Seats = seats
+	result.Winners = append([]string{}, winners...)
+
+	// A ranked count stopped at a last-place tie waits on its resolution,
+	// unless that sends every candidate still in the count to a runoff
+	if result.Count != nil && result.Count.Tie != nil {
+		tie := *result.Count.Tie
+		tie.Policy = e.GetTiePolicy()
+		result.Tie = &tie
+		if res, ok := e.TieResolutions[tieKey(result.ContestID, tie.Round)]; ok && res.Runoff && sameMembers(res.Tied, tie.Candidates) {
+			tie.Resolution = &res
+			result.Runoff = make([]string, 0, len(tallies))
+			for id := range tallies {
+				result.Runoff = append(result.Runoff, id)
+			}
+			sortByTally(result.Runoff, tallies)
+		}
+		return
+	}
+
+	open := seats - len(winners)
+	if open <= 0 {
+		return
+	}
+	elected := make(map[string]bool, len(winners))
+	for _, id := range winners {
+		elected[id] = true
+	}
+	rest := make([]string, 0, len(tallies))
+	for id, v := range tallies {
+		if v > 0 && !elected[id] {
+			rest = append(rest, id)
+		}
+	}
+	if len(rest) <= open {
+		return
+	}
+	sortByTally(rest, tallies)
+	top := tallies[rest[0]]
+	var tied []string
+	for _, id := range rest {
+		if tallies[id] == top {
+			tied = append(tied, id)
+		}
+	}
+	if len(tied) <= open {
+		return
+	}
+
+	result.Tie = &ContestTie{
+		Candidates: tied,
+		Votes:      top,
+		SeatsOpen:  open,
+		Policy:     e.GetTiePolicy(),
+	}
+	if res, ok := e.TieResolutions[result.ContestID]; ok && res.SeatsOpen == open && sameMembers(res.Tied, tied) {
+		result.Tie.Resolution = &res
+		result.Winners = append(result.Winners, res.Winners...)
+		if res.Runoff {
+			result.Runoff = tied
+		}
+	}
+}
+
+// countResolutions returns the recorded resolutions of the candidate race's
+// last-place ties, by count round
+func (e *Election) countResolutions() map[int]TieResolution {
+	resolved := make(map[int]TieResolution)
+	for _, res := range e.TieResolutions {
+		if res.ContestID == CandidateRaceID && res.Round > 0 {
+			resolved[res.Round] = res
+		}
+	}
+	return resolved
+}
+
+// tieKey is where a contest's tie resolution is kept. A ranked count can
+// meet a last-place tie in several rounds, so those are kept per round.
+func tieKey(contestID string, round int) string {
+	if round == 0 {
+		return contestID
+	}
+	return fmt.Sprintf("%s/round%d", contestID, round)
+}
+
+// sameMembers reports whether two ID lists hold the same IDs
+func sameMembers(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	x := append([]string(nil), a...)
+	y := append([]string(nil), b...)
+	sort.Strings(x)
+	sort.Strings(y)
+	return strings.Join(x, "\x00") == strings.Join(y, "\x00")
+}
+
+// UnresolvedTies returns the contests whose winners wait on a tie resolution
+func (e *Election) UnresolvedTies() []ContestResult {
+	var ties []ContestResult
+	for _, c := range e.ContestResults() {
+		if c.Tie != nil && c.Tie.Resolution == nil {
+			ties = append(ties, c)
+		}
+	}
+	return ties
+}
+
+// ResolveTie works out the resolution of a contest's tie under the election's
+// tie policy once polls have closed. A lot is drawn from the committed seed,
+// a runoff sends the tied candidates on, and a manual decision names the
+// winners among the tied candidates with a reason. The election is left
+// unchanged until RecordTieResolution.
+func (e *Election) ResolveTie(contestID string, winners []string, officer, reason string, at time.Time) (TieResolution, error) {
+	switch e.State() {
+	case StateClosed, StateTallied:
+	default:
+		return TieResolution{}, errors.New("ties can only be resolved after polls close and before results are certified")
+	}
+
+	var tie *ContestTie
+	for _, c := range e.UnresolvedTies() {
+		if c.ContestID == contestID {
+			tie = c.Tie
+		}
+	}
+	if tie == nil {
+		return TieResolution{}, fmt.Errorf("contest %s has no unresolved tie", contestID)
+	}
+
+	res := TieResolution{
+		ContestID:  contestID,
+		Policy:     tie.Policy,
+		Tied:       tie.Candidates,
+		SeatsOpen:  tie.SeatsOpen,
+		Round:      tie.Round,
+		Winners:    []string{},
+		DecidedBy:  officer,
+		Reason:     reason,
+		ResolvedAt: at,
+	}
+	switch tie.Policy {
+	case TieByLot:
+		if e.TieSeed == "" {
+			return TieResolution{}, errors.New("no lot seed was committed for this election")
+		}
+		res.Seed = e.TieSeed
+		res.Commitment = e.TieSeedCommitment
+		res.DrawOrder = drawLots(e.TieSeed, contestID, tie.Candidates)
+		res.Winners = res.DrawOrder[:tie.SeatsOpen]
+	case TieByRunoff:
+		res.Runoff = true
+	case TieByManual:
+		if reason == "" {
+			return TieResolution{}, errors.New("a reason is required to decide a tie")
+		}
+		if len(winners) != tie.SeatsOpen {
+			if tie.Round > 0 {
+				return TieResolution{}, fmt.Errorf("name exactly %d of the tied candidates to stay in the count", tie.SeatsOpen)
+			}
+			return TieResolution{}, fmt.Errorf("name exactly %d winner(s) among the tied candidates", tie.SeatsOpen)
+		}
+		for i, id := range winners {
+			if !contains(tie.Candidates, id) || contains(winners[:i], id) {
+				return TieResolution{}, fmt.Errorf("%s is not one of the tied candidates", id)
+			}
+		}
+		res.Winners = append(res.Winners, winners...)
+	}
+	return res, nil
+}
+
+// RecordTieResolution stores a tie resolution so it applies to the results
+func (e *Election) RecordTieResolution(res TieResolution) {
+	if e.TieResolutions == nil {
+		e.TieResolutions = make(map[string]TieResolution)
+	}
+	e.TieResolutions[tieKey(res.ContestID, res.Round)] = res
+}
+
+// contains reports whether a list holds an ID
+func contains(list []string, id string) bool {
+	for _, v := range list {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
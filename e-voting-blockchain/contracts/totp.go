@@ -0,0 +1,175 @@
+package contracts
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Time-based one-time passwords as in RFC 6238: an HMAC-SHA1 over the number
+// of 30 second steps since the Unix epoch, truncated to six digits as in
+// RFC 4226. Every function takes the time to check against, so codes can be
+// checked with a fake clock.
+const (
+	totpStep       = 30 * time.Second
+	totpDigits     = 6
+	totpSkew       = 1 // Steps either side of now accepted, for clock drift
+	totpSecretSize = 20
+
+	recoveryCodeCount = 10
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// TwoFactor is an account's TOTP enrolment. It is unconfirmed until the
+// first code from the authenticator app is checked.
+type TwoFactor struct {
+	Secret        string    `json:"secret"` // Base32, unpadded
+	Confirmed     bool      `json:"confirmed"`
+	EnrolledAt    time.Time `json:"enrolledAt"`
+	LastStep      int64     `json:"lastStep,omitempty"`      // Of the last accepted code, which cannot be used again
+	RecoveryCodes []string  `json:"recoveryCodes,omitempty"` // SHA-256 of each unused recovery code
+}
+
+// NewTwoFactor starts an enrolment with a new random secret
+func NewTwoFactor(at time.Time) (*TwoFactor, error) {
+	secret := make([]byte, totpSecretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	return &TwoFactor{Secret: totpEncoding.EncodeToString(secret), EnrolledAt: at}, nil
+}
+
+// Enabled reports whether the enrolment is confirmed, so logins need a code
+func (t *TwoFactor) Enabled() bool {
+	return t != nil && t.Confirmed
+}
+
+// TOTPURI is the otpauth URI an authenticator app reads, usually from a QR
+// code
+func TOTPURI(issuer, account, secret string) string {
+	q := url.Values{}
+	q.Set("secret", secret)
+	q.Set("issuer", issuer)
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", fmt.Sprint(totpDigits))
+	q.Set("period", fmt.Sprint(int(totpStep.Seconds())))
+	return "otpauth://totp/" + url.PathEscape(issuer+":"+account) + "?" + q.Encode()
+}
+
+// TOTPCode returns the code for a secret at the given time
+func TOTPCode(secret string, at time.Time) (string, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", errors.New("invalid TOTP secret")
+	}
+	return hotp(key, uint64(at.Unix()/int64(totpStep.Seconds()))), nil
+}
+
+// hotp is the RFC 4226 code for a counter
+func hotp(key []byte, counter uint64) string {
+	mac := hmac.New(sha1.New, key)
+	binary.Write(mac, binary.BigEndian, counter)
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < totpDigits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", totpDigits, value%mod)
+}
+
+// VerifyCode checks a code against the steps around the given time. A code
+// is accepted once; the step it was accepted for and those before it are
+// refused afterwards.
+func (t *TwoFactor) VerifyCode(code string, at time.Time) bool {
+	if t == nil {
+		return false
+	}
+	key, err := totpEncoding.DecodeString(strings.ToUpper(t.Secret))
+	if err != nil {
+		return false
+	}
+	code = strings.TrimSpace(code)
+	now := at.Unix() / int64(totpStep.Seconds())
+	for step := now - totpSkew; step <= now+totpSkew; step++ {
+		if step <= t.LastStep {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(hotp(key, uint64(step))), []byte(code)) == 1 {
+			t.LastStep = step
+			return true
+		}
+	}
+	return false
+}
+
+// Confirm completes an enrolment with the first code from the app, and
+// returns new recovery codes. They are only kept hashed, so this is the one
+// time they can be shown.
+func (t *TwoFactor) Confirm(code string, at time.Time) ([]string, error) {
+	if t == nil {
+		return nil, errors.New("two-factor enrolment has not been started")
+	}
+	if t.Confirmed {
+		return nil, errors.New("two-factor authentication is already enabled")
+	}
+	if !t.VerifyCode(code, at) {
+		return nil, errors.New("invalid two-factor code")
+	}
+	codes, err := t.newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	t.Confirmed = true
+	return codes, nil
+}
+
+// UseRecoveryCode accepts an unused recovery code in place of a TOTP code,
+// and removes it so it cannot be used again
+func (t *TwoFactor) UseRecoveryCode(code string) bool {
+	if t == nil {
+		return false
+	}
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(code))))
+	hash := hex.EncodeToString(sum[:])
+	for i, stored := range t.RecoveryCodes {
+		if subtle.ConstantTimeCompare([]byte(stored), []byte(hash)) == 1 {
+			t.RecoveryCodes = append(t.RecoveryCodes[:i], t.RecoveryCodes[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// newRecoveryCodes replaces the recovery codes. Each is 50 random bits, shown
+// as two groups of five base32 characters; being random they need no slow
+// hash.
+func (t *TwoFactor) newRecoveryCodes() ([]string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		raw := make([]byte, 7)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, err
+		}
+		s := strings.ToLower(totpEncoding.EncodeToString(raw))[:10]
+		code := s[:5] + "-" + s[5:]
+		sum := sha256.Sum256([]byte(code))
+		codes = append(codes, code)
+		hashes = append(hashes, hex.EncodeToString(sum[:]))
+	}
+	t.RecoveryCodes = hashes
+	return codes, nil
+}
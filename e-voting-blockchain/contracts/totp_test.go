@@ -0,0 +1,232 @@
+package contracts
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 key of the RFC 6238 test vectors, the ASCII string
+// "12345678901234567890", in base32
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// fakeClock is a fixed time that tests move by hand
+type fakeClock struct{ now time.Time }
+
+func (c *fakeClock) advance(d time.Duration) time.Time {
+	c.now = c.now.Add(d)
+	return c.now
+}
+
+// newTestTwoFactor returns a confirmed enrolment with the RFC secret, the
+// clock it was confirmed at and its recovery codes
+func newTestTwoFactor(t *testing.T) (*TwoFactor, *fakeClock, []string) {
+	t.Helper()
+	clock := &fakeClock{now: time.Unix(1111111109, 0)}
+	tf := &TwoFactor{Secret: rfcSecret, EnrolledAt: clock.now}
+	code := mustCode(t, clock.now)
+	codes, err := tf.Confirm(code, clock.now)
+	if err != nil {
+		t.Fatalf("Confirm: %v", err)
+	}
+	return tf, clock, codes
+}
+
+func mustCode(t *testing.T, at time.Time) string {
+	t.Helper()
+	code, err := TOTPCode(rfcSecret, at)
+	if err != nil {
+		t.Fatalf("TOTPCode: %v", err)
+	}
+	return code
+}
+
+// wrongCode returns a code that is not the one for the given time
+func wrongCode(t *testing.T, at time.Time) string {
+	t.Helper()
+	if mustCode(t, at) == "000000" {
+		return "111111"
+	}
+	return "000000"
+}
+
+// The RFC 6238 appendix B vectors are eight digits; six-digit codes are
+// their last six digits
+func TestTOTPCodeRFC6238(t *testing.T) {
+	vectors := []struct {
+		unix int64
+		code string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+	for _, v := range vectors {
+		got, err := TOTPCode(rfcSecret, time.Unix(v.unix, 0))
+		if err != nil {
+			t.Fatalf("TOTPCode(%d): %v", v.unix, err)
+		}
+		if want := v.code[len(v.code)-totpDigits:]; got != want {
+			t.Errorf("TOTPCode(%d) = %s, want %s", v.unix, got, want)
+		}
+	}
+}
+
+func TestTOTPCodeLowercaseSecret(t *testing.T) {
+	at := time.Unix(59, 0)
+	got, err := TOTPCode(strings.ToLower(rfcSecret), at)
+	if err != nil {
+		t.Fatalf("TOTPCode: %v", err)
+	}
+	if want := mustCode(t, at); got != want {
+		t.Errorf("lowercase secret gave %s, want %s", got, want)
+	}
+}
+
+func TestTOTPCodeInvalidSecret(t *testing.T) {
+	if _, err := TOTPCode("not base32!", time.Unix(59, 0)); err == nil {
+		t.Error("invalid secret accepted")
+	}
+}
+
+func TestVerifyCodeSkew(t *testing.T) {
+	tests := []struct {
+		name   string
+		offset time.Duration
+		ok     bool
+	}{
+		{"current step", 0, true},
+		{"previous step", -totpStep, true},
+		{"next step", totpStep, true},
+		{"two steps behind", -2 * totpStep, false},
+		{"two steps ahead", 2 * totpStep, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := &fakeClock{now: time.Unix(1234567890, 0)}
+			tf := &TwoFactor{Secret: rfcSecret}
+			code := mustCode(t, clock.now.Add(tt.offset))
+			if got := tf.VerifyCode(code, clock.now); got != tt.ok {
+				t.Errorf("VerifyCode = %v, want %v", got, tt.ok)
+			}
+		})
+	}
+}
+
+func TestVerifyCodeReplay(t *testing.T) {
+	tf, clock, _ := newTestTwoFactor(t)
+
+	// The code used to confirm cannot be used again within its step or the
+	// skew window after it
+	confirmed := mustCode(t, clock.now)
+	if tf.VerifyCode(confirmed, clock.now) {
+		t.Error("code accepted twice in the same step")
+	}
+	if tf.VerifyCode(confirmed, clock.advance(totpStep)) {
+		t.Error("code accepted again from the next step")
+	}
+
+	// A fresh code is accepted once
+	code := mustCode(t, clock.now)
+	if !tf.VerifyCode(code, clock.now) {
+		t.Fatal("fresh code refused")
+	}
+	if tf.VerifyCode(code, clock.now) {
+		t.Error("fresh code accepted twice")
+	}
+
+	// Once a later step's code is accepted, an earlier step's is refused
+	// even though it is still within the skew window
+	earlier := mustCode(t, clock.now)
+	if !tf.VerifyCode(mustCode(t, clock.now.Add(totpStep)), clock.now) {
+		t.Fatal("next step's code refused")
+	}
+	if tf.VerifyCode(earlier, clock.now) {
+		t.Error("earlier step's code accepted after a later one")
+	}
+}
+
+func TestVerifyCodeWrongCode(t *testing.T) {
+	tf := &TwoFactor{Secret: rfcSecret}
+	at := time.Unix(59, 0)
+	if tf.VerifyCode(wrongCode(t, at), at) {
+		t.Error("wrong code accepted")
+	}
+	if tf.LastStep != 0 {
+		t.Error("wrong code recorded as used")
+	}
+	if !tf.VerifyCode(" "+mustCode(t, at)+" ", at) {
+		t.Error("code with surrounding spaces refused")
+	}
+}
+
+func TestConfirm(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(2000000000, 0)}
+	tf := &TwoFactor{Secret: rfcSecret}
+	if tf.Enabled() {
+		t.Fatal("unconfirmed enrolment enabled")
+	}
+	if _, err := tf.Confirm(wrongCode(t, clock.now), clock.now); err == nil {
+		t.Fatal("confirmed with a wrong code")
+	}
+	codes, err := tf.Confirm(mustCode(t, clock.now), clock.now)
+	if err != nil {
+		t.Fatalf("Confirm: %v", err)
+	}
+	if !tf.Enabled() {
+		t.Error("confirmed enrolment not enabled")
+	}
+	if len(codes) != recoveryCodeCount || len(tf.RecoveryCodes) != recoveryCodeCount {
+		t.Errorf("got %d recovery codes, %d stored; want %d", len(codes), len(tf.RecoveryCodes), recoveryCodeCount)
+	}
+	for _, stored := range tf.RecoveryCodes {
+		for _, code := range codes {
+			if stored == code {
+				t.Fatal("recovery code stored unhashed")
+			}
+		}
+	}
+	if _, err := tf.Confirm(mustCode(t, clock.advance(totpStep)), clock.now); err == nil {
+		t.Error("confirmed twice")
+	}
+}
+
+func TestUseRecoveryCode(t *testing.T) {
+	tf, _, codes := newTestTwoFactor(t)
+
+	if !tf.UseRecoveryCode(codes[0]) {
+		t.Fatal("recovery code refused")
+	}
+	if tf.UseRecoveryCode(codes[0]) {
+		t.Error("recovery code accepted twice")
+	}
+	if len(tf.RecoveryCodes) != recoveryCodeCount-1 {
+		t.Errorf("%d recovery codes left, want %d", len(tf.RecoveryCodes), recoveryCodeCount-1)
+	}
+
+	// Codes are accepted however they are typed
+	if !tf.UseRecoveryCode("  " + strings.ToUpper(codes[1]) + "\n") {
+		t.Error("recovery code in capitals with spaces refused")
+	}
+
+	// Using one code leaves the others valid
+	for _, code := range codes[2:] {
+		if !tf.UseRecoveryCode(code) {
+			t.Errorf("recovery code %s refused", code)
+		}
+	}
+	if len(tf.RecoveryCodes) != 0 {
+		t.Errorf("%d recovery codes left, want none", len(tf.RecoveryCodes))
+	}
+	if tf.UseRecoveryCode("abcde-fghij") {
+		t.Error("unknown recovery code accepted")
+	}
+
+	var none *TwoFactor
+	if none.UseRecoveryCode(codes[0]) || none.VerifyCode("123456", time.Unix(59, 0)) {
+		t.Error("code accepted without an enrolment")
+	}
+}
@@ -0,0 +1,93 @@
+package contracts
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ResultVisibility controls what the public can see while polls are open
+type ResultVisibility string
+
+const (
+	VisibilityHidden      ResultVisibility = "hidden"       // Nothing until polls close
+	VisibilityTurnoutOnly ResultVisibility = "turnout_only" // Ballots cast, but no counts
+	VisibilityLive        ResultVisibility = "live"         // Running counts
+)
+
+// ParseResultVisibility validates a visibility rule name
+func ParseResultVisibility(s string) (ResultVisibility, error) {
+	switch v := ResultVisibility(s); v {
+	case VisibilityHidden, VisibilityTurnoutOnly, VisibilityLive:
+		return v, nil
+	}
+	return "", fmt.Errorf("unknown result visibility %q", s)
+}
+
+// GetResultVisibility returns the configured rule. Elections without one are
+// embargoed, since publishing counts during voting is illegal in many places.
+func (e *Election) GetResultVisibility() ResultVisibility {
+	if e.ResultVisibility == "" {
+		return VisibilityHidden
+	}
+	return e.ResultVisibility
+}
+
+// SetResultVisibility sets the visibility rule. The rule is part of the
+// election's published terms, so it is fixed once voting opens.
+func (e *Election) SetResultVisibility(v ResultVisibility) error {
+	if !e.ballotEditable() {
+		return errors.New("cannot change result visibility once voting has opened")
+	}
+	e.ResultVisibility = v
+	return nil
+}
+
+// PollsClosed reports whether voting has finished for good
+func (e *Election) PollsClosed() bool {
+	switch e.State() {
+	case StateClosed, StateTallied, StateCertified, StateArchived:
+		return true
+	}
+	return false
+}
+
+// EffectiveVisibility is the rule in force now: the configured rule while
+// polls are open and live results once they have closed
+func (e *Election) EffectiveVisibility() ResultVisibility {
+	if e.PollsClosed() {
+		return VisibilityLive
+	}
+	return e.GetResultVisibility()
+}
+
+// CountsVisible reports whether vote counts may be shown
+func (e *Election) CountsVisible() bool {
+	return e.EffectiveVisibility() == VisibilityLive
+}
+
+// TurnoutVisible reports whether the number of ballots cast may be shown
+func (e *Election) TurnoutVisible() bool {
+	return e.EffectiveVisibility() != VisibilityHidden
+}
+
+// VisibleCandidate hides a candidate's running total while counts are embargoed
+func (e *Election) VisibleCandidate(c Candidate) Candidate {
+	if !e.CountsVisible() {
+		c.Votes = 0
+	}
+	return c
+}
+
+// VisibleStatistics returns the statistics allowed by the visibility rule in
+// force. Turnout figures are dropped while the election is fully embargoed.
+func (e *Election) VisibleStatistics() map[string]interface{} {
+	stats := e.GetStatistics()
+	if !e.TurnoutVisible() {
+		for _, key := range []string{"totalVoters", "totalVotes", "totalBallots", "superseded", "provisional", "votedUsers", "pendingVoters"} {
+			delete(stats, key)
+		}
+	}
+	stats["resultVisibility"] = e.GetResultVisibility()
+	stats["countsVisible"] = e.CountsVisible()
+	return stats
+}
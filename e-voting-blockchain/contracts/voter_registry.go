@@ -3,10 +3,13 @@ package contracts
 import (
 	"crypto/rand"
 	"encoding/json"
+	"errors"
 	"math/big"
-	"os"
+	"time"
 )
 
+// VoterRecord is an entry in the government voter registry. Password is an
+// argon2id hash.
 type VoterRecord struct {
 	VoterID  string `json:"VoterID"`
 	Name     string `json:"name"`
@@ -16,22 +19,34 @@ type VoterRecord struct {
 	Password string `json:"password"`
 }
 
-// Struct for users who have successfully registered
+// Struct for users who have successfully registered. Password is an argon2id
+// hash; TwoFactor is set if the voter has enrolled in TOTP, Verification
+// until they confirm their email address, and PasswordReset while a reset
+// link they asked for is outstanding. Language is the one emails to the
+// voter are written in.
 type RegisteredUser struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
-	VoterID  string `json:"voterId"`
-	Email    string `json:"email"`
+	Username     string             `json:"username"`
+	Password     string             `json:"password"`
+	VoterID      string             `json:"voterId"`
+	Email        string             `json:"email"`
+	Language     string             `json:"language,omitempty"`
+	TwoFactor    *TwoFactor         `json:"twoFactor,omitempty"`
+	Verification *EmailVerification `json:"verification,omitempty"`
+
+	PasswordReset     *PasswordReset `json:"passwordReset,omitempty"`
+	PasswordChangedAt time.Time      `json:"passwordChangedAt"`
 }
 
 // VoterDatabase holds all valid voter records
 type VoterDatabase struct {
 	Records map[string]VoterRecord // Keyed by Voter ID
+
+	storage *Storage
 }
 
 // LoadVoterDatabase loads voter data from a file
-func LoadVoterDatabase() (*VoterDatabase, error) {
-	data, err := os.ReadFile("voters.json")
+func (s *Storage) LoadVoterDatabase() (*VoterDatabase, error) {
+	data, err := s.ReadFile("voters.json")
 	if err != nil {
 		return nil, err
 	}
@@ -41,7 +56,7 @@ func LoadVoterDatabase() (*VoterDatabase, error) {
 		return nil, err
 	}
 
-	db := &VoterDatabase{Records: make(map[string]VoterRecord)}
+	db := &VoterDatabase{Records: make(map[string]VoterRecord), storage: s}
 	for _, record := range list {
 		db.Records[record.VoterID] = record
 	}
@@ -57,12 +72,26 @@ func (db *VoterDatabase) IsValid(id, name, dob string) bool {
 	return voter.Name == name && voter.DOB == dob
 }
 
+// CheckEligibility confirms a registered user is still a voter in the
+// registry under the details they registered with
+func (db *VoterDatabase) CheckEligibility(user RegisteredUser) error {
+	voter, exists := db.Records[user.VoterID]
+	if !exists {
+		return errors.New("voter is not in the voter registry")
+	}
+	if voter.Email != user.Email {
+		return errors.New("registration details no longer match the voter registry")
+	}
+	return nil
+}
+
 func (db *VoterDatabase) ValidateCredentials(voterID, email, password string) bool {
 	voter, exists := db.Records[voterID]
 	if !exists {
 		return false
 	}
-	return voter.Email == email && voter.Password == password
+	passMatch := VerifyPassword(voter.Password, password)
+	return voter.Email == email && passMatch
 }
 
 // Save saves the VoterDatabase to voters.json
@@ -75,7 +104,7 @@ func (db *VoterDatabase) Save() error {
 	if err != nil {
 		return err
 	}
-	return os.WriteFile("voters.json", data, 0644)
+	return db.storage.WriteFile("voters.json", data, 0644)
 }
 
 // GenerateSecurePassword returns a random alphanumeric password
@@ -94,8 +123,8 @@ func GenerateSecurePassword(length int) (string, error) {
 	return string(password), nil
 }
 
-func LoadRegisteredUsers() ([]RegisteredUser, error) {
-	data, err := os.ReadFile("registered_voters.json")
+func (s *Storage) LoadRegisteredUsers() ([]RegisteredUser, error) {
+	data, err := s.ReadFile("registered_voters.json")
 	if err != nil {
 		return nil, err
 	}
@@ -106,10 +135,10 @@ func LoadRegisteredUsers() ([]RegisteredUser, error) {
 	return users, nil
 }
 
-func SaveRegisteredUsers(users []RegisteredUser) error {
+func (s *Storage) SaveRegisteredUsers(users []RegisteredUser) error {
 	data, err := json.MarshalIndent(users, "", "  ")
 	if err != nil {
 		return err
 	}
-	return os.WriteFile("registered_voters.json", data, 0644)
+	return s.WriteFile("registered_voters.json", data, 0600)
 }
@@ -0,0 +1,240 @@
+package contracts
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// A write-in is a free-text name given in place of a listed candidate on a
+// single choice ballot. Write-ins are stored normalised so that spellings
+// differing only in case, spacing or punctuation are adjudicated together.
+// An election officer maps each spelling onto a registered candidate or
+// marks it invalid; mapped write-ins count for the candidate, while invalid
+// and pending ones are reported beside the count.
+
+// MaxWriteInLength is the longest write-in kept, in characters
+const MaxWriteInLength = 100
+
+// WriteInStatus is where a write-in spelling is in adjudication
+type WriteInStatus string
+
+const (
+	WriteInPending WriteInStatus = "pending"
+	WriteInMapped  WriteInStatus = "mapped"
+	WriteInInvalid WriteInStatus = "invalid"
+)
+
+// WriteInAdjudication is an officer's decision on one write-in spelling
+type WriteInAdjudication struct {
+	Text          string        `json:"text"`
+	Status        WriteInStatus `json:"status"`
+	CandidateID   string        `json:"candidateId,omitempty"`
+	Reason        string        `json:"reason,omitempty"`
+	DecidedBy     string        `json:"decidedBy"`
+	DecidedAt     time.Time     `json:"decidedAt"`
+	TransactionID string        `json:"transactionId,omitempty"`
+}
+
+// WriteInEntry is a write-in spelling in the adjudication queue
+type WriteInEntry struct {
+	Text     string               `json:"text"`
+	Ballots  int                  `json:"ballots"`
+	Status   WriteInStatus        `json:"status"`
+	Decision *WriteInAdjudication `json:"decision,omitempty"`
+}
+
+// WriteInTally summarises the candidate race's write-in ballots
+type WriteInTally struct {
+	Ballots int `json:"ballots"`
+	Counted int `json:"counted"` // Mapped onto a candidate and counted for them
+	Invalid int `json:"invalid"`
+	Pending int `json:"pending"`
+}
+
+// NormalizeWriteIn reduces a write-in to its canonical form: lower case,
+// letters and digits only with single spaces between words, and hyphens and
+// apostrophes kept inside names
+func NormalizeWriteIn(s string) (string, error) {
+	var b strings.Builder
+	space := false
+	for _, r := range strings.ToLower(s) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '\'':
+			if space && b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			space = false
+			b.WriteRune(r)
+		case unicode.IsSpace(r) || unicode.IsPunct(r):
+			space = true
+		}
+	}
+	text := strings.Trim(b.String(), "-'")
+	if text == "" {
+		return "", errors.New("write-in is empty")
+	}
+	if runes := []rune(text); len(runes) > MaxWriteInLength {
+		text = strings.TrimSpace(string(runes[:MaxWriteInLength]))
+	}
+	return text, nil
+}
+
+// ConfigureWriteIns turns write-ins on or off. They are only offered on
+// single choice ballots and, like the rest of the ballot, are fixed once
+// voting opens.
+func (e *Election) ConfigureWriteIns(allow bool) error {
+	if !e.ballotEditable() {
+		return errors.New("cannot change write-ins once voting has opened")
+	}
+	if allow && e.GetBallotType() != BallotSingleChoice {
+		return errors.New("write-ins are only offered on single choice ballots")
+	}
+	e.AllowWriteIns = allow
+	return nil
+}
+
+// validateWriteIn normalises a ballot's write-in and checks it may be cast
+func (e *Election) validateWriteIn(b *Ballot) error {
+	if b.WriteIn == "" {
+		return nil
+	}
+	if !e.AllowWriteIns || e.GetBallotType() != BallotSingleChoice {
+		return errors.New("write-ins are not accepted in this election")
+	}
+	if b.votesInRace() || b.Special != "" {
+		return errors.New("a write-in cannot be combined with other choices in the race")
+	}
+	text, err := NormalizeWriteIn(b.WriteIn)
+	if err != nil {
+		return err
+	}
+	b.WriteIn = text
+	return nil
+}
+
+// withWriteIn returns the ballot as it is counted: a write-in mapped onto a
+// candidate becomes a choice of that candidate
+func (e *Election) withWriteIn(b Ballot) Ballot {
+	if b.WriteIn == "" {
+		return b
+	}
+	if d, ok := e.WriteIns[b.WriteIn]; ok && d.Status == WriteInMapped {
+		b.Choices = []string{d.CandidateID}
+	}
+	return b
+}
+
+// AdjudicateWriteIn maps a write-in spelling onto a registered candidate, or
+// marks it invalid when candidateID is empty, which needs a reason. A
+// decision can be changed until the results are certified. The election is
+// left unchanged until RecordWriteIn.
+func (e *Election) AdjudicateWriteIn(text, candidateID, reason, officer string, at time.Time) (WriteInAdjudication, error) {
+	e.initializeMaps()
+
+	switch e.State() {
+	case StateVoting, StatePaused, StateClosed:
+	default:
+		return WriteInAdjudication{}, errors.New("write-ins can only be adjudicated between opening the polls and certifying the results")
+	}
+	normalized, err := NormalizeWriteIn(text)
+	if err != nil {
+		return WriteInAdjudication{}, err
+	}
+	if e.writeInBallots()[normalized] == 0 {
+		return WriteInAdjudication{}, fmt.Errorf("no ballot has the write-in %q", normalized)
+	}
+
+	d := WriteInAdjudication{
+		Text:      normalized,
+		Status:    WriteInInvalid,
+		Reason:    reason,
+		DecidedBy: officer,
+		DecidedAt: at,
+	}
+	if candidateID != "" {
+		c, exists := e.Candidates[candidateID]
+		if !exists || c.GetStatus() == CandidateNominated {
+			return WriteInAdjudication{}, fmt.Errorf("%s is not a registered candidate", candidateID)
+		}
+		d.Status = WriteInMapped
+		d.CandidateID = candidateID
+	} else if reason == "" {
+		return WriteInAdjudication{}, errors.New("a reason is required to mark a write-in invalid")
+	}
+	return d, nil
+}
+
+// RecordWriteIn stores a write-in decision and recounts the running totals
+func (e *Election) RecordWriteIn(d WriteInAdjudication) {
+	if e.WriteIns == nil {
+		e.WriteIns = make(map[string]WriteInAdjudication)
+	}
+	e.WriteIns[d.Text] = d
+	e.rebuildRunningTotals()
+}
+
+// writeInBallots counts the counted ballots carrying each write-in spelling
+func (e *Election) writeInBallots() map[string]int {
+	counts := make(map[string]int)
+	for _, b := range e.countedBallots() {
+		if b.WriteIn != "" {
+			counts[b.WriteIn]++
+		}
+	}
+	return counts
+}
+
+// WriteInQueue lists each write-in spelling with its ballots and decision,
+// optionally only those with the given status, most ballots first
+func (e *Election) WriteInQueue(status WriteInStatus) []WriteInEntry {
+	counts := e.writeInBallots()
+	queue := make([]WriteInEntry, 0, len(counts))
+	for text, n := range counts {
+		entry := WriteInEntry{Text: text, Ballots: n, Status: WriteInPending}
+		if d, ok := e.WriteIns[text]; ok {
+			entry.Status = d.Status
+			entry.Decision = &d
+		}
+		if status == "" || entry.Status == status {
+			queue = append(queue, entry)
+		}
+	}
+	sort.Slice(queue, func(i, j int) bool {
+		if queue[i].Ballots != queue[j].Ballots {
+			return queue[i].Ballots > queue[j].Ballots
+		}
+		return queue[i].Text < queue[j].Text
+	})
+	return queue
+}
+
+// PendingWriteIns returns the number of write-in spellings not yet
+// adjudicated
+func (e *Election) PendingWriteIns() int {
+	return len(e.WriteInQueue(WriteInPending))
+}
+
+// WriteInResults summarises the race's write-in ballots by decision
+func (e *Election) WriteInResults() *WriteInTally {
+	counts := e.writeInBallots()
+	if len(counts) == 0 {
+		return nil
+	}
+	tally := &WriteInTally{}
+	for text, n := range counts {
+		tally.Ballots += n
+		switch e.WriteIns[text].Status {
+		case WriteInMapped:
+			tally.Counted += n
+		case WriteInInvalid:
+			tally.Invalid += n
+		default:
+			tally.Pending += n
+		}
+	}
+	return tally
+}
@@ -0,0 +1,396 @@
+// Package config holds the server's settings. They start from built-in
+// defaults and are then read, each source overriding the ones before it,
+// from a YAML file, a .env file, the environment and command-line flags.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is every setting of the server
+type Config struct {
+	Server   ServerConfig   `yaml:"server"`
+	Auth     AuthConfig     `yaml:"auth"`
+	Storage  StorageConfig  `yaml:"storage"`
+	Election ElectionConfig `yaml:"election"`
+	Mail     MailConfig     `yaml:"mail"`
+}
+
+// ServerConfig is where and how the server listens, and who may call it
+type ServerConfig struct {
+	Addr string `yaml:"addr"`
+
+	// Timeouts for reading a request's headers and all of it, writing the
+	// response, and keeping an idle connection open
+	ReadHeaderTimeout time.Duration `yaml:"readHeaderTimeout"`
+	ReadTimeout       time.Duration `yaml:"readTimeout"`
+	WriteTimeout      time.Duration `yaml:"writeTimeout"`
+	IdleTimeout       time.Duration `yaml:"idleTimeout"`
+
+	// ShutdownTimeout is how long requests in progress are given to finish
+	// when the server is stopped
+	ShutdownTimeout time.Duration `yaml:"shutdownTimeout"`
+
+	TLS TLSConfig `yaml:"tls"`
+
+	// PublicURL is the address the server is reached at, used in links in
+	// emails
+	PublicURL string `yaml:"publicURL"`
+
+	// CORSOrigins are the browser origins allowed to call the API; "*"
+	// allows any
+	CORSOrigins []string `yaml:"corsOrigins"`
+
+	// TrustedProxies are the IPs or CIDR ranges of reverse proxies in front
+	// of the server. X-Forwarded-For and X-Real-IP are only believed on
+	// requests that come from one of them.
+	TrustedProxies []string `yaml:"trustedProxies"`
+}
+
+// TLSConfig turns on HTTPS when a certificate is given
+type TLSConfig struct {
+	CertFile string `yaml:"certFile"`
+	KeyFile  string `yaml:"keyFile"`
+
+	// ClientCAFile turns on mutual TLS: every client must present a
+	// certificate signed by one of the CAs in this PEM file
+	ClientCAFile string `yaml:"clientCAFile"`
+}
+
+// Proxies parses the trusted proxies, each an IP or a CIDR range
+func (s ServerConfig) Proxies() ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(s.TrustedProxies))
+	for _, p := range s.TrustedProxies {
+		if addr, err := netip.ParseAddr(p); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(p)
+		if err != nil {
+			return nil, fmt.Errorf("trusted proxy %q must be an IP address or CIDR range", p)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// Enabled reports whether the server serves HTTPS
+func (t TLSConfig) Enabled() bool {
+	return t.CertFile != ""
+}
+
+// AuthConfig is how admins and voters are authenticated
+type AuthConfig struct {
+	// SigningKeys are comma separated kid:hexsecret pairs, the first of which
+	// signs new tokens. When empty the keys are generated and kept in the
+	// data directory.
+	SigningKeys string `yaml:"signingKeys"`
+
+	// AdminUsername and AdminPassword are the super-admin created on the
+	// first start, when there is no admin.json. The built-in default password
+	// is refused then.
+	AdminUsername string `yaml:"adminUsername"`
+	AdminPassword string `yaml:"adminPassword"`
+}
+
+// StorageConfig is where data is kept
+type StorageConfig struct {
+	// DataDir holds the election, registrations, accounts, keys and the
+	// chain database
+	DataDir string `yaml:"dataDir"`
+}
+
+// ElectionConfig is the defaults for elections
+type ElectionConfig struct {
+	// DefaultDuration is how long voting stays open when an election is
+	// started without a duration
+	DefaultDuration time.Duration `yaml:"defaultDuration"`
+}
+
+// MailConfig is how emails to voters are sent
+type MailConfig struct {
+	// Transport is smtp, file or memory
+	Transport   string `yaml:"transport"`
+	From        string `yaml:"from"`
+	Dir         string `yaml:"dir"`         // For the file transport
+	TemplateDir string `yaml:"templateDir"` // Overrides and translations of the built-in templates
+
+	SMTPHost     string `yaml:"smtpHost"`
+	SMTPPort     int    `yaml:"smtpPort"`
+	SMTPUsername string `yaml:"smtpUsername"`
+	SMTPPassword string `yaml:"smtpPassword"`
+
+	// PasswordResetURL is the page of the voter site that takes the token
+	// from a password reset link
+	PasswordResetURL string `yaml:"passwordResetURL"`
+}
+
+// Default returns the settings used when nothing overrides them
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Addr:              ":8080",
+			ReadHeaderTimeout: 5 * time.Second,
+			ReadTimeout:       15 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       2 * time.Minute,
+			ShutdownTimeout:   30 * time.Second,
+			PublicURL:         "http://localhost:8080",
+			CORSOrigins:       []string{"http://localhost:5173", "http://localhost:3000"},
+		},
+		Auth: AuthConfig{
+			AdminUsername: "admin@devote.com",
+			AdminPassword: "admin123",
+		},
+		Storage: StorageConfig{
+			DataDir: ".",
+		},
+		Election: ElectionConfig{
+			DefaultDuration: 24 * time.Hour,
+		},
+		Mail: MailConfig{
+			Transport:        "file",
+			From:             "no-reply@devote.local",
+			Dir:              "outbox",
+			SMTPPort:         587,
+			PasswordResetURL: "http://localhost:5173/reset-password",
+		},
+	}
+}
+
+// setting is one setting that can be given in .env, the environment or a
+// flag
+type setting struct {
+	flag  string
+	env   string
+	usage string
+	set   func(c *Config, value string) error
+}
+
+var settings = []setting{
+	{"addr", "LISTEN_ADDR", "address to listen on", func(c *Config, v string) error { c.Server.Addr = v; return nil }},
+	{"read-header-timeout", "HTTP_READ_HEADER_TIMEOUT", "time allowed to read request headers", duration(func(c *Config) *time.Duration { return &c.Server.ReadHeaderTimeout })},
+	{"read-timeout", "HTTP_READ_TIMEOUT", "time allowed to read a request", duration(func(c *Config) *time.Duration { return &c.Server.ReadTimeout })},
+	{"write-timeout", "HTTP_WRITE_TIMEOUT", "time allowed to write a response", duration(func(c *Config) *time.Duration { return &c.Server.WriteTimeout })},
+	{"idle-timeout", "HTTP_IDLE_TIMEOUT", "time an idle connection is kept open", duration(func(c *Config) *time.Duration { return &c.Server.IdleTimeout })},
+	{"shutdown-timeout", "SHUTDOWN_TIMEOUT", "time requests are given to finish on shutdown", duration(func(c *Config) *time.Duration { return &c.Server.ShutdownTimeout })},
+	{"tls-cert", "TLS_CERT_FILE", "PEM certificate, to serve HTTPS", func(c *Config, v string) error { c.Server.TLS.CertFile = v; return nil }},
+	{"tls-key", "TLS_KEY_FILE", "PEM private key of the certificate", func(c *Config, v string) error { c.Server.TLS.KeyFile = v; return nil }},
+	{"tls-client-ca", "TLS_CLIENT_CA_FILE", "PEM CAs client certificates must be signed by, for mutual TLS", func(c *Config, v string) error { c.Server.TLS.ClientCAFile = v; return nil }},
+	{"public-url", "PUBLIC_BASE_URL", "address the server is reached at, for links in emails", func(c *Config, v string) error { c.Server.PublicURL = v; return nil }},
+	{"cors-origins", "CORS_ORIGINS", "comma separated origins allowed to call the API", func(c *Config, v string) error { c.Server.CORSOrigins = splitList(v); return nil }},
+	{"trusted-proxies", "TRUSTED_PROXIES", "comma separated IPs or CIDR ranges of proxies whose forwarding headers are believed", func(c *Config, v string) error { c.Server.TrustedProxies = splitList(v); return nil }},
+	{"jwt-signing-keys", "JWT_SIGNING_KEYS", "comma separated kid:hexsecret token signing keys", func(c *Config, v string) error { c.Auth.SigningKeys = v; return nil }},
+	{"admin-username", "ADMIN_USERNAME", "username of the initial super-admin", func(c *Config, v string) error { c.Auth.AdminUsername = v; return nil }},
+	{"admin-password", "ADMIN_PASSWORD", "password of the initial super-admin", func(c *Config, v string) error { c.Auth.AdminPassword = v; return nil }},
+	{"data-dir", "DATA_DIR", "directory data is kept in", func(c *Config, v string) error { c.Storage.DataDir = v; return nil }},
+	{"election-duration", "ELECTION_DURATION", "how long voting stays open by default, e.g. 24h", duration(func(c *Config) *time.Duration { return &c.Election.DefaultDuration })},
+	{"mail-transport", "MAIL_TRANSPORT", "how emails are sent: smtp, file or memory", func(c *Config, v string) error { c.Mail.Transport = v; return nil }},
+	{"mail-from", "MAIL_FROM", "sender of emails", func(c *Config, v string) error { c.Mail.From = v; return nil }},
+	{"mail-dir", "MAIL_DIR", "directory the file transport writes emails to", func(c *Config, v string) error { c.Mail.Dir = v; return nil }},
+	{"mail-template-dir", "MAIL_TEMPLATE_DIR", "directory of email templates overriding the built-in ones", func(c *Config, v string) error { c.Mail.TemplateDir = v; return nil }},
+	{"smtp-host", "SMTP_HOST", "SMTP server", func(c *Config, v string) error { c.Mail.SMTPHost = v; return nil }},
+	{"smtp-port", "SMTP_PORT", "SMTP port", func(c *Config, v string) error {
+		port, err := strconv.Atoi(v)
+		c.Mail.SMTPPort = port
+		return err
+	}},
+	{"smtp-username", "SMTP_USERNAME", "SMTP username", func(c *Config, v string) error { c.Mail.SMTPUsername = v; return nil }},
+	{"smtp-password", "SMTP_PASSWORD", "SMTP password", func(c *Config, v string) error { c.Mail.SMTPPassword = v; return nil }},
+	{"password-reset-url", "PASSWORD_RESET_URL", "voter site page that takes password reset tokens", func(c *Config, v string) error { c.Mail.PasswordResetURL = v; return nil }},
+}
+
+// Load reads the settings for a command line. The YAML file is named by
+// -config or CONFIG_FILE, and is optional unless named; the .env file is
+// named by -env-file and is optional.
+func Load(name string, args []string) (*Config, error) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "YAML config file")
+	envFile := fs.String("env-file", ".env", "file of environment settings")
+	for _, s := range settings {
+		fs.String(s.flag, "", s.usage+" ($"+s.env+")")
+	}
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			fs.SetOutput(os.Stderr)
+			fs.PrintDefaults()
+		}
+		return nil, err
+	}
+
+	cfg := Default()
+	if *configFile != "" {
+		if err := cfg.readFile(*configFile); err != nil {
+			return nil, err
+		}
+	}
+
+	dotenv, err := godotenv.Read(*envFile)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("reading %s: %v", *envFile, err)
+	}
+	for _, s := range settings {
+		if v, ok := dotenv[s.env]; ok {
+			if err := s.set(cfg, v); err != nil {
+				return nil, fmt.Errorf("%s in %s: %v", s.env, *envFile, err)
+			}
+		}
+	}
+	for _, s := range settings {
+		if v, ok := os.LookupEnv(s.env); ok {
+			if err := s.set(cfg, v); err != nil {
+				return nil, fmt.Errorf("%s: %v", s.env, err)
+			}
+		}
+	}
+
+	var flagErr error
+	fs.Visit(func(f *flag.Flag) {
+		for _, s := range settings {
+			if s.flag == f.Name && flagErr == nil {
+				if err := s.set(cfg, f.Value.String()); err != nil {
+					flagErr = fmt.Errorf("-%s: %v", s.flag, err)
+				}
+			}
+		}
+	})
+	if flagErr != nil {
+		return nil, flagErr
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// readFile overrides settings with those in a YAML file
+func (c *Config) readFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil && err != io.EOF {
+		return fmt.Errorf("invalid config file %s: %v", path, err)
+	}
+	return nil
+}
+
+// Validate checks the settings, reporting every problem found
+func (c *Config) Validate() error {
+	var problems []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	_, _, err := net.SplitHostPort(c.Server.Addr)
+	check(err == nil, "server address %q must be host:port", c.Server.Addr)
+	timeouts := []struct {
+		name string
+		d    time.Duration
+	}{
+		{"read header", c.Server.ReadHeaderTimeout},
+		{"read", c.Server.ReadTimeout},
+		{"write", c.Server.WriteTimeout},
+		{"idle", c.Server.IdleTimeout},
+		{"shutdown", c.Server.ShutdownTimeout},
+	}
+	for _, t := range timeouts {
+		check(t.d > 0, "%s timeout must be positive", t.name)
+	}
+	tls := c.Server.TLS
+	check((tls.CertFile == "") == (tls.KeyFile == ""), "TLS needs both a certificate and a key file")
+	check(tls.ClientCAFile == "" || tls.Enabled(), "mutual TLS needs a server certificate")
+	for _, file := range []string{tls.CertFile, tls.KeyFile, tls.ClientCAFile} {
+		if file != "" {
+			_, err := os.Stat(file)
+			check(err == nil, "TLS file %s: %v", file, err)
+		}
+	}
+	check(validURL(c.Server.PublicURL), "public URL %q must be an absolute http(s) URL", c.Server.PublicURL)
+	for _, origin := range c.Server.CORSOrigins {
+		check(origin == "*" || validURL(origin), "CORS origin %q must be * or an absolute http(s) URL", origin)
+	}
+	if _, err := c.Server.Proxies(); err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	check(c.Auth.AdminUsername != "", "admin username is required")
+	check(c.Auth.AdminPassword != "", "admin password is required")
+
+	if info, err := os.Stat(c.Storage.DataDir); err == nil {
+		check(info.IsDir(), "data directory %s is not a directory", c.Storage.DataDir)
+	} else {
+		check(os.IsNotExist(err), "data directory %s: %v", c.Storage.DataDir, err)
+	}
+
+	check(c.Election.DefaultDuration > 0, "default election duration must be positive")
+
+	switch c.Mail.Transport {
+	case "smtp":
+		check(c.Mail.SMTPHost != "", "SMTP host is required for the smtp mail transport")
+	case "file":
+		check(c.Mail.Dir != "", "mail directory is required for the file mail transport")
+	case "memory":
+	default:
+		problems = append(problems, fmt.Sprintf("mail transport %q must be smtp, file or memory", c.Mail.Transport))
+	}
+	check(c.Mail.From != "", "mail sender is required")
+	check(c.Mail.SMTPPort > 0 && c.Mail.SMTPPort < 65536, "SMTP port %d is out of range", c.Mail.SMTPPort)
+	check(validURL(c.Mail.PasswordResetURL), "password reset URL %q must be an absolute http(s) URL", c.Mail.PasswordResetURL)
+
+	if len(problems) > 0 {
+		return errors.New("invalid configuration: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// DefaultAdminPassword reports whether the initial super-admin still has the
+// built-in password
+func (c *Config) DefaultAdminPassword() bool {
+	return c.Auth.AdminPassword == Default().Auth.AdminPassword
+}
+
+// duration makes a setter for a duration setting
+func duration(field func(c *Config) *time.Duration) func(c *Config, value string) error {
+	return func(c *Config, v string) error {
+		d, err := time.ParseDuration(v)
+		*field(c) = d
+		return err
+	}
+}
+
+func validURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
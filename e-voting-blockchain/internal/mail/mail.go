@@ -0,0 +1,130 @@
+// Package mail sends the emails the server writes to voters: login details,
+// address verification links and password resets. Messages are rendered
+// from per-language templates and handed to a Mailer, which delivers them
+// over SMTP or, for development and tests, writes them to files or keeps
+// them in memory.
+package mail
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message is a rendered plain text email
+type Message struct {
+	To      string    `json:"to"`
+	Subject string    `json:"subject"`
+	Body    string    `json:"body"`
+	SentAt  time.Time `json:"sentAt"`
+}
+
+// Mailer delivers messages
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// SMTPMailer sends through an SMTP server, authenticating with PLAIN when a
+// username is set. The connection is upgraded with STARTTLS when the server
+// offers it; PLAIN auth is refused over an unencrypted connection to
+// anything but localhost.
+type SMTPMailer struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+// Send delivers a message
+func (m *SMTPMailer) Send(msg Message) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+	addr := fmt.Sprintf("%s:%d", m.Host, m.Port)
+	return smtp.SendMail(addr, auth, m.From, []string{msg.To}, format(m.From, msg))
+}
+
+// FileMailer writes each message to its own .eml file in Dir, for
+// development without a mail server
+type FileMailer struct {
+	Dir  string
+	From string
+}
+
+// Send writes a message to a new file
+func (m *FileMailer) Send(msg Message) error {
+	if err := os.MkdirAll(m.Dir, 0700); err != nil {
+		return err
+	}
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405"), hex.EncodeToString(suffix))
+	return os.WriteFile(filepath.Join(m.Dir, name), format(m.From, msg), 0600)
+}
+
+// MemoryMailer keeps the messages it is given, for tests
+type MemoryMailer struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+// Send keeps a message
+func (m *MemoryMailer) Send(msg Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if msg.SentAt.IsZero() {
+		msg.SentAt = time.Now()
+	}
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// Sent returns the messages sent so far, oldest first
+func (m *MemoryMailer) Sent() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Message(nil), m.messages...)
+}
+
+// New returns the mailer for a transport: smtp, file (into dir) or memory
+func New(transport string, server SMTPMailer, dir string) (Mailer, error) {
+	switch transport {
+	case "smtp":
+		if server.Host == "" || server.From == "" {
+			return nil, errors.New("the smtp mail transport needs a host and a from address")
+		}
+		return &server, nil
+	case "file", "":
+		return &FileMailer{Dir: dir, From: server.From}, nil
+	case "memory":
+		return &MemoryMailer{}, nil
+	default:
+		return nil, fmt.Errorf("unknown mail transport %q; expected smtp, file or memory", transport)
+	}
+}
+
+// format writes a message in RFC 5322 form. Header values have line breaks
+// removed so a recipient or subject cannot inject headers.
+func format(from string, msg Message) []byte {
+	clean := strings.NewReplacer("\r", "", "\n", "").Replace
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", clean(from))
+	fmt.Fprintf(&b, "To: %s\r\n", clean(msg.To))
+	fmt.Fprintf(&b, "Subject: %s\r\n", clean(msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
@@ -0,0 +1,99 @@
+package mail
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// DefaultLanguage is used for templates with no translation in the
+// requested language
+const DefaultLanguage = "en"
+
+// Templates are stored as templates/<language>/<name>.txt. The first line of
+// each is "Subject: ..." and the rest is the body; both are text/template.
+//
+//go:embed templates
+var builtinTemplates embed.FS
+
+// Templates renders messages from the built-in templates, or from a
+// directory laid out the same way whose files take precedence, so that
+// translations can be added without rebuilding
+type Templates struct {
+	dir string
+}
+
+// NewTemplates returns templates overridden from dir; an empty dir uses only
+// the built-in ones
+func NewTemplates(dir string) *Templates {
+	return &Templates{dir: dir}
+}
+
+// Render fills in a template for a recipient in their language, falling back
+// to DefaultLanguage
+func (t *Templates) Render(lang, name, to string, data interface{}) (Message, error) {
+	src, err := t.source(lang, name)
+	if err != nil {
+		return Message{}, err
+	}
+	subject, body, ok := strings.Cut(src, "\n")
+	subject, found := strings.CutPrefix(subject, "Subject:")
+	if !ok || !found {
+		return Message{}, fmt.Errorf("mail template %s must start with a Subject: line", name)
+	}
+
+	render := func(text string) (string, error) {
+		tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+		if err != nil {
+			return "", err
+		}
+		var b bytes.Buffer
+		if err := tmpl.Execute(&b, data); err != nil {
+			return "", err
+		}
+		return b.String(), nil
+	}
+	msg := Message{To: to}
+	if msg.Subject, err = render(strings.TrimSpace(subject)); err != nil {
+		return Message{}, err
+	}
+	if msg.Body, err = render(strings.TrimLeft(body, "\n")); err != nil {
+		return Message{}, err
+	}
+	return msg, nil
+}
+
+// source finds a template in the language, then in DefaultLanguage
+func (t *Templates) source(lang, name string) (string, error) {
+	for _, l := range []string{lang, DefaultLanguage} {
+		if l == "" || strings.ContainsAny(l, `/\.`) {
+			continue
+		}
+		file := l + "/" + name + ".txt"
+		if t.dir != "" {
+			if data, err := os.ReadFile(filepath.Join(t.dir, filepath.FromSlash(file))); err == nil {
+				return string(data), nil
+			}
+		}
+		if data, err := builtinTemplates.ReadFile("templates/" + file); err == nil {
+			return string(data), nil
+		}
+	}
+	return "", fmt.Errorf("no mail template %s", name)
+}
+
+// Language picks the preferred language from an Accept-Language header,
+// reduced to its primary subtag such as "fr" for "fr-CA"
+func Language(acceptLanguage string) string {
+	first, _, _ := strings.Cut(acceptLanguage, ",")
+	first, _, _ = strings.Cut(first, ";")
+	primary, _, _ := strings.Cut(strings.TrimSpace(first), "-")
+	if primary == "" || primary == "*" {
+		return DefaultLanguage
+	}
+	return strings.ToLower(primary)
+}
@@ -0,0 +1,408 @@
+package server
+
+import (
+	"bytes"
+	"e-voting-blockchain/contracts"
+	"e-voting-blockchain/internal/config"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// minAdminPasswordLength is the shortest password accepted for an admin
+const minAdminPasswordLength = 12
+
+// adminStore holds the admin accounts, loaded at startup
+var (
+	adminMu    sync.Mutex
+	adminStore *contracts.AdminStore
+)
+
+// loadAdminStore returns the admin accounts. It never creates an account:
+// the first super-admin is only made by bootstrapAdmins at startup. Callers
+// hold adminMu.
+func loadAdminStore() (*contracts.AdminStore, error) {
+	if adminStore != nil {
+		return adminStore, nil
+	}
+	store, err := storage.LoadAdminStore()
+	if err != nil {
+		return nil, err
+	}
+	adminStore = store
+	return adminStore, nil
+}
+
+// bootstrapAdmins loads the admin accounts at startup, hashing plaintext
+// passwords left by an earlier version. Only on a first start, when there
+// has never been an admin.json, is the super-admin created from the
+// configured credentials, and never with the built-in default password.
+func bootstrapAdmins(cfg *config.Config) error {
+	adminMu.Lock()
+	defer adminMu.Unlock()
+
+	store, err := storage.LoadAdminStore()
+	changed := false
+	switch {
+	case os.IsNotExist(err):
+		username, password := cfg.Auth.AdminUsername, cfg.Auth.AdminPassword
+		if cfg.DefaultAdminPassword() {
+			return fmt.Errorf("there are no admin accounts and ADMIN_PASSWORD is the built-in default; set it to create super-admin %s", username)
+		}
+		if len(password) < minAdminPasswordLength {
+			return fmt.Errorf("ADMIN_PASSWORD must be at least %d characters to create super-admin %s", minAdminPasswordLength, username)
+		}
+		store = storage.NewAdminStore()
+		if _, err := store.AddAdmin(username, password, contracts.RoleSuperAdmin, "system", time.Now()); err != nil {
+			return err
+		}
+		log.Printf("Creating super-admin account %s", username)
+		changed = true
+	case err != nil:
+		return err
+	}
+	for name, account := range store.Accounts {
+		if contracts.IsPasswordHash(account.Password) {
+			continue
+		}
+		hash, err := contracts.HashPassword(account.Password)
+		if err != nil {
+			return err
+		}
+		account.Password = hash
+		store.Accounts[name] = account
+		changed = true
+	}
+	if changed {
+		if err := store.Save(); err != nil {
+			return err
+		}
+	}
+	adminStore = store
+	return nil
+}
+
+// activeAdmin returns the current account of an admin who is allowed to log
+// in, so a changed role or a disabled account takes effect on the next
+// request
+func activeAdmin(username string) (contracts.AdminAccount, error) {
+	adminMu.Lock()
+	defer adminMu.Unlock()
+
+	store, err := loadAdminStore()
+	if err != nil {
+		return contracts.AdminAccount{}, err
+	}
+	account, exists := store.GetAdmin(username)
+	if !exists || account.Disabled {
+		return contracts.AdminAccount{}, errors.New("admin account not found or disabled")
+	}
+	return account, nil
+}
+
+// requestClaims returns the claims of the token the request was
+// authenticated with, or nil on a public route
+func requestClaims(r *http.Request) *Claims {
+	claims, _ := r.Context().Value(userKey).(*Claims)
+	return claims
+}
+
+// actor returns the admin making a request, as recorded on the chain
+func actor(r *http.Request) string {
+	if claims := requestClaims(r); claims != nil {
+		return claims.Subject
+	}
+	return "system"
+}
+
+// allow wraps an admin handler so that it only runs for admins whose role
+// grants the permission
+func allow(perm contracts.Permission, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "OPTIONS" {
+			next(w, r)
+			return
+		}
+		claims := requestClaims(r)
+		if claims == nil || !contracts.AdminRole(claims.Role).Can(perm) {
+			log.Printf("Permission %s denied to %s", perm, actor(r))
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Permission denied", "required": string(perm)})
+			return
+		}
+		next(w, r)
+	}
+}
+
+// criticalAccount is critical for the admin account routes. A password in
+// the request is hashed before it is held for approval, so a proposal never
+// stores it in plaintext.
+func criticalAccount(action string, next http.HandlerFunc) http.HandlerFunc {
+	return allow(contracts.PermManageAdmins, hashRequestPassword(critical(contracts.PermManageAdmins, action, next)))
+}
+
+// hashRequestPassword replaces the password field of a JSON request with its
+// passwordHash. A passwordHash sent by the client is dropped, so every hash
+// the handlers store was made here from a password long enough to accept.
+func hashRequestPassword(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "OPTIONS" || r.Context().Value(approvedKey) != nil {
+			next(w, r)
+			return
+		}
+		var fields map[string]json.RawMessage
+		if err := json.NewDecoder(r.Body).Decode(&fields); err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid JSON format"})
+			return
+		}
+		delete(fields, "passwordHash")
+		if raw, ok := fields["password"]; ok {
+			var password string
+			if err := json.Unmarshal(raw, &password); err != nil {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{"error": "Invalid JSON format"})
+				return
+			}
+			delete(fields, "password")
+			if password != "" {
+				if len(password) < minAdminPasswordLength {
+					w.Header().Set("Content-Type", "application/json")
+					w.WriteHeader(http.StatusBadRequest)
+					json.NewEncoder(w).Encode(map[string]string{"error": "Password must be at least 12 characters"})
+					return
+				}
+				hash, err := contracts.HashPassword(password)
+				if err != nil {
+					w.Header().Set("Content-Type", "application/json")
+					w.WriteHeader(http.StatusInternalServerError)
+					json.NewEncoder(w).Encode(map[string]string{"error": "Failed to hash password"})
+					return
+				}
+				fields["passwordHash"], _ = json.Marshal(hash)
+			}
+		}
+		body, _ := json.Marshal(fields)
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		next(w, r)
+	}
+}
+
+// adminView is an admin account as returned by the API, without its password
+func adminView(account contracts.AdminAccount) map[string]interface{} {
+	return map[string]interface{}{
+		"username":    account.Username,
+		"role":        account.Role,
+		"permissions": account.Role.Permissions(),
+		"disabled":    account.Disabled,
+		"twoFactor":   account.TwoFactor.Enabled(),
+		"createdBy":   account.CreatedBy,
+		"createdAt":   account.CreatedAt,
+		"updatedAt":   account.UpdatedAt,
+	}
+}
+
+// HandleGetCurrentAdmin returns the logged in admin's account and
+// permissions
+func HandleGetCurrentAdmin(w http.ResponseWriter, r *http.Request) {
+	log.Println("HandleGetCurrentAdmin called")
+	w.Header().Set("Content-Type", "application/json")
+
+	account, err := activeAdmin(actor(r))
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	json.NewEncoder(w).Encode(adminView(account))
+}
+
+// HandleListAdmins returns the admin accounts
+func HandleListAdmins(w http.ResponseWriter, r *http.Request) {
+	log.Println("HandleListAdmins called")
+	w.Header().Set("Content-Type", "application/json")
+
+	adminMu.Lock()
+	defer adminMu.Unlock()
+
+	store, err := loadAdminStore()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to load admin accounts"})
+		return
+	}
+	list := make([]map[string]interface{}, 0, len(store.Accounts))
+	for _, account := range store.ListAdmins() {
+		list = append(list, adminView(account))
+	}
+	json.NewEncoder(w).Encode(list)
+}
+
+// HandleAddAdmin creates an admin account. The password arrives already
+// hashed by hashRequestPassword.
+func HandleAddAdmin(w http.ResponseWriter, r *http.Request) {
+	log.Println("HandleAddAdmin called")
+	w.Header().Set("Content-Type", "application/json")
+
+	type Req struct {
+		Username     string `json:"username"`
+		PasswordHash string `json:"passwordHash"`
+		Role         string `json:"role"`
+	}
+
+	var req Req
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid JSON format"})
+		return
+	}
+	role, err := contracts.ParseAdminRole(req.Role)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	if !contracts.IsPasswordHash(req.PasswordHash) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Password must be at least 12 characters"})
+		return
+	}
+
+	adminMu.Lock()
+	defer adminMu.Unlock()
+
+	store, err := loadAdminStore()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to load admin accounts"})
+		return
+	}
+	account, err := store.AddAdmin(req.Username, req.PasswordHash, role, actor(r), time.Now())
+	if err != nil {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	if err := store.Save(); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to save admin accounts"})
+		return
+	}
+
+	// Log to blockchain
+	blockchainLogger.LogAdminAccountAction("add", actor(r), account.Username, map[string]interface{}{"role": account.Role}, r)
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(adminView(account))
+}
+
+// HandleUpdateAdmin changes an admin account's role, disables or enables it,
+// or sets a new password, which arrives already hashed by
+// hashRequestPassword
+func HandleUpdateAdmin(w http.ResponseWriter, r *http.Request) {
+	log.Println("HandleUpdateAdmin called")
+	w.Header().Set("Content-Type", "application/json")
+
+	type Req struct {
+		Role         string `json:"role,omitempty"`
+		Disabled     *bool  `json:"disabled,omitempty"`
+		PasswordHash string `json:"passwordHash,omitempty"`
+	}
+
+	var req Req
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid JSON format"})
+		return
+	}
+	var role contracts.AdminRole
+	if req.Role != "" {
+		var err error
+		if role, err = contracts.ParseAdminRole(req.Role); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+	}
+	hash := req.PasswordHash
+	if hash != "" && !contracts.IsPasswordHash(hash) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid password hash"})
+		return
+	}
+
+	adminMu.Lock()
+	defer adminMu.Unlock()
+
+	store, err := loadAdminStore()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to load admin accounts"})
+		return
+	}
+	username := mux.Vars(r)["username"]
+	account, err := store.UpdateAdmin(username, role, req.Disabled, hash, time.Now())
+	if err != nil {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	if err := store.Save(); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to save admin accounts"})
+		return
+	}
+
+	// Log to blockchain
+	details := map[string]interface{}{"role": account.Role, "disabled": account.Disabled}
+	if hash != "" {
+		details["passwordChanged"] = true
+	}
+	blockchainLogger.LogAdminAccountAction("update", actor(r), username, details, r)
+
+	json.NewEncoder(w).Encode(adminView(account))
+}
+
+// HandleDeleteAdmin removes an admin account
+func HandleDeleteAdmin(w http.ResponseWriter, r *http.Request) {
+	log.Println("HandleDeleteAdmin called")
+	w.Header().Set("Content-Type", "application/json")
+
+	adminMu.Lock()
+	defer adminMu.Unlock()
+
+	store, err := loadAdminStore()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to load admin accounts"})
+		return
+	}
+	username := mux.Vars(r)["username"]
+	account, _ := store.GetAdmin(username)
+	if err := store.DeleteAdmin(username); err != nil {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	if err := store.Save(); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to save admin accounts"})
+		return
+	}
+
+	// Log to blockchain
+	blockchainLogger.LogAdminAccountAction("delete", actor(r), username, map[string]interface{}{"role": account.Role}, r)
+
+	json.NewEncoder(w).Encode(map[string]string{"status": "admin deleted", "username": username})
+}
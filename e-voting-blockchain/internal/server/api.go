@@ -3,10 +3,13 @@ package server
 import (
 	"e-voting-blockchain/blockchain"
 	"e-voting-blockchain/contracts"
+	"e-voting-blockchain/internal/config"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"os"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -17,21 +20,36 @@ var election *contracts.Election
 var chain *blockchain.Blockchain
 var blockchainLogger *BlockchainLogger
 
-func init() {
+// storage is the data directory the election, accounts and keys are kept in
+var storage *contracts.Storage
+
+// initialize loads the election and blockchain from the data directory and
+// sets up authentication and mail from the configuration
+func initialize(cfg *config.Config) error {
 	log.Println("Initializing election and blockchain...")
 
-	// Initialize blockchain
-	chain = blockchain.NewBlockchain()
-	blockchainLogger = NewBlockchainLogger(chain)
+	// Every data file is kept in the data directory, and nomination
+	// policies in a directory of their own within it
+	storage = contracts.NewStorage(cfg.Storage.DataDir)
+	if err := os.MkdirAll(storage.Path(contracts.PolicyDir), 0700); err != nil {
+		return fmt.Errorf("creating data directory: %v", err)
+	}
+
+	// Client addresses are only taken from forwarding headers set by these
+	proxies, err := cfg.Server.Proxies()
+	if err != nil {
+		return err
+	}
 
-	// Start WebSocket hub for real-time notifications
-	StartWebSocketHub()
+	// Initialize blockchain
+	chain = blockchain.NewBlockchain(storage.Path(blockchain.DBFile))
+	blockchainLogger = NewBlockchainLogger(chain, proxies)
 
 	// Load election
-	loaded, err := contracts.LoadElection()
+	loaded, err := storage.LoadElection()
 	if err != nil {
 		log.Printf("Failed to load election, creating new one: %v", err)
-		election = contracts.NewElection()
+		election = storage.NewElection()
 		if saveErr := election.SaveElection(); saveErr != nil {
 			log.Printf("Failed to save new election: %v", saveErr)
 		}
@@ -39,41 +57,27 @@ func init() {
 		election = loaded
 		log.Println("Election loaded successfully")
 	}
-}
 
-// Updated function to get voter details from the correct registered users file
-func getVoterDetailsFromRegistered(voterID string) (string, string, error) {
-	log.Printf("Looking for voter details for voterID: %s", voterID)
-	// Load registered users from the correct path
-	registeredUsers, err := contracts.LoadRegisteredUsers()
-	if err != nil {
-		log.Printf("Failed to load registered users: %v", err)
-		return "", "", err
+	// Load the admin accounts, creating the super-admin on a first start,
+	// and hash any passwords still stored in plaintext
+	if err := bootstrapAdmins(cfg); err != nil {
+		return fmt.Errorf("loading admin accounts: %v", err)
 	}
+	migratePasswords()
 
-	log.Printf("Loaded %d registered users", len(registeredUsers))
-	// Find the user by voterID
-	for _, user := range registeredUsers {
-		log.Printf("Checking user: VoterID=%s, Username=%s", user.VoterID, user.Username)
-		if user.VoterID == voterID {
-			log.Printf("Found registered user for voterID %s", voterID)
-			// Load the voter database to get name and DOB
-			db, err := contracts.LoadVoterDatabase()
-			if err != nil {
-				log.Printf("Failed to load voter database: %v", err)
-				return "", "", err
-			}
-
-			if voter, exists := db.Records[voterID]; exists {
-				log.Printf("Found voter details: Name=%s, DOB=%s", voter.Name, voter.DOB)
-				return voter.Name, voter.DOB, nil
-			}
-			return "", "", errors.New("voter details not found in government database")
-		}
+	// Set up the mailer for emails to voters
+	if mailer, err = newVoterMailer(cfg.Mail, cfg.Server.PublicURL); err != nil {
+		return fmt.Errorf("setting up mail: %v", err)
 	}
 
-	log.Printf("Voter with ID %s not found in registered users", voterID)
-	return "", "", errors.New("voter not registered")
+	// Load the token signing keys and the revoked tokens
+	if err := loadSigningKeys(cfg.Auth.SigningKeys); err != nil {
+		return fmt.Errorf("loading token signing keys: %v", err)
+	}
+	if err := loadRevokedTokens(); err != nil {
+		log.Printf("Failed to load revoked tokens: %v", err)
+	}
+	return nil
 }
 
 // HandleVote receives a POST request to cast a vote with blockchain logging
@@ -82,10 +86,28 @@ func HandleVote(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	type VoteRequest struct {
-		VoterID     string `json:"voterID"`
+		// VoterID is optional; the voter is the subject of the session token
+		// and a different ID is refused
+		VoterID string `json:"voterID"`
+		// Name and DOB are the details a voter gives for the review of a
+		// provisional ballot
 		Name        string `json:"name"`
 		DOB         string `json:"dob"`
 		CandidateID string `json:"candidateID"`
+		// Choices is the ranked preference list for IRV/STV ballots or the
+		// approved candidates for approval ballots
+		Choices []string `json:"choices,omitempty"`
+		// Scores rates each candidate for score ballots
+		Scores map[string]int `json:"scores,omitempty"`
+		// Answers maps question contests to the chosen option
+		Answers map[string]string `json:"answers,omitempty"`
+		// Special is a system option such as NOTA chosen instead of a candidate
+		Special string `json:"special,omitempty"`
+		// WriteIn is a free-text name given instead of a listed candidate
+		WriteIn string `json:"writeIn,omitempty"`
+		// Provisional asks for a provisional ballot if the voter's details
+		// cannot be confirmed
+		Provisional bool `json:"provisional,omitempty"`
 	}
 
 	var req VoteRequest
@@ -96,63 +118,99 @@ func HandleVote(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	log.Printf("Received vote request: VoterID=%s, CandidateID=%s, Name=%s, DOB=%s",
-		req.VoterID, req.CandidateID, req.Name, req.DOB)
-
-	// Check if election is active
-	if !election.IsElectionActive() {
-		log.Println("Election is not active")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Election is not active"})
+	// The voter is whoever the session token was issued to
+	claims := r.Context().Value(userKey).(*Claims)
+	if req.VoterID != "" && req.VoterID != claims.Subject {
+		log.Printf("Vote for %s refused: session belongs to %s", req.VoterID, claims.Subject)
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Voter ID does not match the logged in voter"})
 		return
 	}
+	req.VoterID = claims.Subject
 
-	// If name and DOB are empty, try to get them from registered users
-	if req.Name == "" || req.DOB == "" {
-		log.Println("Name or DOB empty, fetching from registered users...")
-		name, dob, err := getVoterDetailsFromRegistered(req.VoterID)
-		if err != nil {
-			log.Printf("Failed to get voter details: %v", err)
-			w.WriteHeader(http.StatusUnauthorized)
-			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
-			return
-		}
-		req.Name = name
-		req.DOB = dob
-		log.Printf("Retrieved voter details: Name=%s, DOB=%s", req.Name, req.DOB)
+	// The ballot's contents are never logged next to the voter
+	log.Printf("Received vote request: VoterID=%s", req.VoterID)
+
+	// Single-choice clients send candidateID only, which may also name a
+	// special option such as NOTA
+	if contracts.IsSpecialOption(req.CandidateID) && req.Special == "" {
+		req.Special = req.CandidateID
+	} else if len(req.Choices) == 0 && req.CandidateID != "" {
+		req.Choices = []string{req.CandidateID}
 	}
 
-	// Load valid voter database for government validation
-	db, err := contracts.LoadVoterDatabase()
-	if err != nil {
-		log.Printf("Failed to load voter registry: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to load voter registry"})
+	// The election is locked from the checks through casting the ballot, so
+	// the polls cannot close or the election be replaced in between
+	electionMu.Lock()
+	defer electionMu.Unlock()
+
+	// Check if election is active; a pause gets its own error so clients can
+	// tell voters polling will resume
+	if suspension, paused := election.ActiveSuspension(); paused {
+		log.Printf("Voting is paused: %s", suspension.Reason)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":    "Voting is paused",
+			"code":     "election_paused",
+			"reason":   suspension.Reason,
+			"pausedAt": suspension.PausedAt,
+		})
+		return
+	}
+	if !election.IsElectionActive() {
+		log.Println("Election is not active")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Election is not active"})
 		return
 	}
 
-	// Validate voter against government database
-	log.Printf("Validating voter: ID=%s, Name=%s, DOB=%s", req.VoterID, req.Name, req.DOB)
-	if !db.IsValid(req.VoterID, req.Name, req.DOB) {
-		log.Printf("Invalid voter details for: ID=%s, Name=%s, DOB=%s", req.VoterID, req.Name, req.DOB)
+	// Eligibility was checked when the voter logged in. A voter who failed it
+	// is turned away unless they ask for a provisional ballot, which is held
+	// for an officer to review.
+	challenge := claims.Challenge
+	if challenge != "" && !req.Provisional {
 		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid voter details in government database"})
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":                challenge,
+			"provisionalAvailable": true,
+		})
 		return
 	}
-	log.Println("Voter validation successful")
 
-	// Validate that the candidate exists
-	_, err = election.GetCandidate(req.CandidateID)
-	if err != nil {
-		log.Printf("Invalid candidate ID: %s", req.CandidateID)
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid candidate selected"})
-		return
+	// Validate that the candidates exist
+	selected := append([]string(nil), req.Choices...)
+	for id := range req.Scores {
+		selected = append(selected, id)
+	}
+	for _, id := range selected {
+		if _, err := election.GetCandidate(id); err != nil {
+			log.Println("Invalid candidate ID in vote request")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid candidate selected"})
+			return
+		}
 	}
 
 	// Check if already voted and cast vote
 	log.Printf("Checking if voter %s has already voted", req.VoterID)
-	err = election.Vote(req.VoterID, req.CandidateID)
+	ballot := contracts.Ballot{
+		VoterID: req.VoterID,
+		Choices: req.Choices,
+		Scores:  req.Scores,
+		Special: contracts.SpecialOption(req.Special),
+		WriteIn: req.WriteIn,
+		Answers: req.Answers,
+	}
+	if challenge != "" {
+		castProvisional(w, r, ballot, req.Name, req.DOB, challenge)
+		return
+	}
+	ballot, err := election.CastBallot(ballot)
+	if errors.Is(err, contracts.ErrElectionPaused) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Voting is paused", "code": "election_paused"})
+		return
+	}
 	if err != nil {
 		log.Printf("Failed to vote: %v", err)
 		w.WriteHeader(http.StatusBadRequest)
@@ -166,7 +224,7 @@ func HandleVote(w http.ResponseWriter, r *http.Request) {
 	// chain.AddBlock([]blockchain.Transaction{tx})
 
 	// Log to blockchain
-	blockchainLogger.LogVote(req.VoterID, req.CandidateID, r)
+	blockchainLogger.LogBallot(election, ballot, r)
 
 	// Save updated election state
 	if saveErr := election.SaveElection(); saveErr != nil {
@@ -190,6 +248,9 @@ func HandleTally(w http.ResponseWriter, r *http.Request) {
 	log.Println("HandleTally called")
 	w.Header().Set("Content-Type", "application/json")
 
+	electionMu.RLock()
+	defer electionMu.RUnlock()
+
 	if election == nil {
 		log.Println("Election is nil!")
 		w.WriteHeader(http.StatusInternalServerError)
@@ -197,6 +258,11 @@ func HandleTally(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !election.CountsVisible() {
+		writeResultsEmbargoed(w)
+		return
+	}
+
 	tally := election.Tally()
 	log.Printf("Tally: %v", tally)
 
@@ -214,6 +280,9 @@ func HandleListCandidates(w http.ResponseWriter, r *http.Request) {
 	log.Println("HandleListCandidates called")
 	w.Header().Set("Content-Type", "application/json")
 
+	electionMu.RLock()
+	defer electionMu.RUnlock()
+
 	if election == nil {
 		log.Println("Election is nil!")
 		w.WriteHeader(http.StatusInternalServerError)
@@ -227,6 +296,9 @@ func HandleListCandidates(w http.ResponseWriter, r *http.Request) {
 	if candidates == nil {
 		candidates = []contracts.Candidate{}
 	}
+	for i := range candidates {
+		candidates[i] = election.VisibleCandidate(candidates[i])
+	}
 
 	if err := json.NewEncoder(w).Encode(candidates); err != nil {
 		log.Printf("Failed to encode candidates: %v", err)
@@ -242,6 +314,10 @@ func HandleGetCandidate(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	id := mux.Vars(r)["id"]
+
+	electionMu.RLock()
+	defer electionMu.RUnlock()
+
 	candidate, err := election.GetCandidate(id)
 	if err != nil {
 		log.Printf("Failed to get candidate: %v", err)
@@ -249,6 +325,7 @@ func HandleGetCandidate(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
 		return
 	}
+	candidate = election.VisibleCandidate(candidate)
 
 	if err := json.NewEncoder(w).Encode(candidate); err != nil {
 		log.Printf("Failed to encode candidate: %v", err)
@@ -296,10 +373,13 @@ func HandleAddCandidate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	electionMu.Lock()
+	defer electionMu.Unlock()
+
 	err := election.AddCandidate(req.ID, req.Name, req.Bio, req.PartyID, req.Age, req.ImageURL)
 	if err != nil {
 		log.Printf("Failed to add candidate: %v", err)
-		w.WriteHeader(http.StatusBadRequest)
+		w.WriteHeader(candidateErrorStatus(err, http.StatusBadRequest))
 		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
 		return
 	}
@@ -312,7 +392,7 @@ func HandleAddCandidate(w http.ResponseWriter, r *http.Request) {
 		"age":      req.Age,
 		"imageURL": req.ImageURL,
 	}
-	blockchainLogger.LogCandidateAction("add", "admin", req.ID, req.Name, details, r)
+	blockchainLogger.LogCandidateAction("add", actor(r), req.ID, req.Name, details, r)
 
 	if saveErr := election.SaveElection(); saveErr != nil {
 		log.Printf("Failed to save election: %v", saveErr)
@@ -351,10 +431,13 @@ func HandleUpdateCandidate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	electionMu.Lock()
+	defer electionMu.Unlock()
+
 	err := election.UpdateCandidate(id, req.Name, req.Bio, req.PartyID, req.Age, req.ImageURL)
 	if err != nil {
 		log.Printf("Failed to update candidate: %v", err)
-		w.WriteHeader(http.StatusNotFound)
+		w.WriteHeader(candidateErrorStatus(err, http.StatusNotFound))
 		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
 		return
 	}
@@ -367,7 +450,7 @@ func HandleUpdateCandidate(w http.ResponseWriter, r *http.Request) {
 		"age":      req.Age,
 		"imageURL": req.ImageURL,
 	}
-	blockchainLogger.LogCandidateAction("update", "admin", id, req.Name, details, r)
+	blockchainLogger.LogCandidateAction("update", actor(r), id, req.Name, details, r)
 
 	if saveErr := election.SaveElection(); saveErr != nil {
 		log.Printf("Failed to save election: %v", saveErr)
@@ -387,6 +470,9 @@ func HandleDeleteCandidate(w http.ResponseWriter, r *http.Request) {
 
 	id := mux.Vars(r)["id"]
 
+	electionMu.Lock()
+	defer electionMu.Unlock()
+
 	// Get candidate info before deletion
 	candidate, err := election.GetCandidate(id)
 	if err != nil {
@@ -398,7 +484,7 @@ func HandleDeleteCandidate(w http.ResponseWriter, r *http.Request) {
 
 	err = election.RemoveCandidate(id)
 	if err != nil {
-		w.WriteHeader(http.StatusNotFound)
+		w.WriteHeader(candidateErrorStatus(err, http.StatusNotFound))
 		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
 		return
 	}
@@ -408,7 +494,7 @@ func HandleDeleteCandidate(w http.ResponseWriter, r *http.Request) {
 		"name":    candidate.Name,
 		"partyID": candidate.PartyID,
 	}
-	blockchainLogger.LogCandidateAction("delete", "admin", id, candidate.Name, details, r)
+	blockchainLogger.LogCandidateAction("delete", actor(r), id, candidate.Name, details, r)
 
 	if saveErr := election.SaveElection(); saveErr != nil {
 		w.WriteHeader(http.StatusInternalServerError)
@@ -419,11 +505,87 @@ func HandleDeleteCandidate(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"status": "candidate removed"})
 }
 
+// HandleSetCandidateStatus approves, withdraws or disqualifies a candidate.
+// Unlike deletion this keeps the candidate and any votes already cast.
+func HandleSetCandidateStatus(w http.ResponseWriter, r *http.Request) {
+	log.Println("HandleSetCandidateStatus called")
+	w.Header().Set("Content-Type", "application/json")
+
+	id := mux.Vars(r)["id"]
+	type Req struct {
+		Status string `json:"status"`
+		Reason string `json:"reason"`
+	}
+
+	var req Req
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid JSON format"})
+		return
+	}
+
+	status, err := contracts.ParseCandidateStatus(req.Status)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	electionMu.Lock()
+	defer electionMu.Unlock()
+
+	previous, err := election.GetCandidate(id)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	candidate, err := election.SetCandidateStatus(id, status, req.Reason, time.Now())
+	if err != nil {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	// Log to blockchain
+	details := map[string]interface{}{
+		"from":            previous.GetStatus(),
+		"to":              candidate.Status,
+		"reason":          req.Reason,
+		"withdrawnPolicy": election.GetWithdrawnPolicy(),
+	}
+	blockchainLogger.LogCandidateAction("status", actor(r), id, candidate.Name, details, r)
+
+	if saveErr := election.SaveElection(); saveErr != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to save election data"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":    "candidate status changed",
+		"candidate": election.VisibleCandidate(candidate),
+	})
+}
+
+// candidateErrorStatus maps a candidate change error to a status code,
+// reporting the voting lock as a conflict
+func candidateErrorStatus(err error, fallback int) int {
+	if errors.Is(err, contracts.ErrCandidatesLocked) {
+		return http.StatusConflict
+	}
+	return fallback
+}
+
 // Party Management Handlers
 func HandleListParties(w http.ResponseWriter, r *http.Request) {
 	log.Println("HandleListParties called")
 	w.Header().Set("Content-Type", "application/json")
 
+	electionMu.RLock()
+	defer electionMu.RUnlock()
+
 	if election == nil {
 		log.Println("Election is nil!")
 		w.WriteHeader(http.StatusInternalServerError)
@@ -453,6 +615,9 @@ func HandleAddParty(w http.ResponseWriter, r *http.Request) {
 	log.Println("HandleAddParty called")
 	w.Header().Set("Content-Type", "application/json")
 
+	electionMu.Lock()
+	defer electionMu.Unlock()
+
 	// Check if election is initialized
 	if election == nil {
 		log.Println("Election is nil in HandleAddParty!")
@@ -494,7 +659,7 @@ func HandleAddParty(w http.ResponseWriter, r *http.Request) {
 		"description": req.Description,
 		"color":       req.Color,
 	}
-	blockchainLogger.LogPartyAction("add", "admin", req.ID, req.Name, details, r)
+	blockchainLogger.LogPartyAction("add", actor(r), req.ID, req.Name, details, r)
 
 	if saveErr := election.SaveElection(); saveErr != nil {
 		w.WriteHeader(http.StatusInternalServerError)
@@ -527,6 +692,9 @@ func HandleUpdateParty(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	electionMu.Lock()
+	defer electionMu.Unlock()
+
 	err := election.UpdateParty(id, req.Name, req.Description, req.Color)
 	if err != nil {
 		w.WriteHeader(http.StatusNotFound)
@@ -540,7 +708,7 @@ func HandleUpdateParty(w http.ResponseWriter, r *http.Request) {
 		"description": req.Description,
 		"color":       req.Color,
 	}
-	blockchainLogger.LogPartyAction("update", "admin", id, req.Name, details, r)
+	blockchainLogger.LogPartyAction("update", actor(r), id, req.Name, details, r)
 
 	if saveErr := election.SaveElection(); saveErr != nil {
 		w.WriteHeader(http.StatusInternalServerError)
@@ -557,6 +725,9 @@ func HandleDeleteParty(w http.ResponseWriter, r *http.Request) {
 
 	id := mux.Vars(r)["id"]
 
+	electionMu.Lock()
+	defer electionMu.Unlock()
+
 	// Get party info before deletion
 	parties := election.ListParties()
 	var partyName string
@@ -578,7 +749,7 @@ func HandleDeleteParty(w http.ResponseWriter, r *http.Request) {
 	details := map[string]interface{}{
 		"name": partyName,
 	}
-	blockchainLogger.LogPartyAction("delete", "admin", id, partyName, details, r)
+	blockchainLogger.LogPartyAction("delete", actor(r), id, partyName, details, r)
 
 	if saveErr := election.SaveElection(); saveErr != nil {
 		w.WriteHeader(http.StatusInternalServerError)
@@ -590,13 +761,365 @@ func HandleDeleteParty(w http.ResponseWriter, r *http.Request) {
 }
 
 // Election Management Handlers
-func HandleStartElection(w http.ResponseWriter, r *http.Request) {
-	log.Println("HandleStartElection called")
+
+// setupRequest holds the ballot settings a start request can give; omitted
+// ones are left as configured
+type setupRequest struct {
+	BallotType string `json:"ballotType,omitempty"`
+	Seats      int    `json:"seats,omitempty"`
+	MaxScore   int    `json:"maxScore,omitempty"`
+	// ResultVisibility is hidden, turnout_only or live
+	ResultVisibility string `json:"resultVisibility,omitempty"`
+	// SpecialOptions enables NOTA and/or ABSTAIN on the ballot
+	SpecialOptions []string `json:"specialOptions,omitempty"`
+	// AllowRevision lets voters re-cast until close; the last ballot counts
+	AllowRevision *bool `json:"allowRevision,omitempty"`
+	// AllowWriteIns accepts a free-text write-in on single choice ballots
+	AllowWriteIns *bool `json:"allowWriteIns,omitempty"`
+	// WithdrawnPolicy is separate or count
+	WithdrawnPolicy string `json:"withdrawnPolicy,omitempty"`
+	// TiePolicy is lot, runoff or manual
+	TiePolicy string `json:"tiePolicy,omitempty"`
+	// RunoffThreshold is the vote share in percent a winner must exceed,
+	// e.g. 50; below it the top RunoffCandidates (default 2) go to a runoff
+	RunoffThreshold  float64 `json:"runoffThreshold,omitempty"`
+	RunoffCandidates int     `json:"runoffCandidates,omitempty"`
+}
+
+// options parses the settings, drawing a fresh seed when lots are to be
+// drawn. Nothing is applied to the election here.
+func (req setupRequest) options() (contracts.SetupOptions, error) {
+	opts := contracts.SetupOptions{AllowRevision: req.AllowRevision, AllowWriteIns: req.AllowWriteIns}
+	if req.BallotType != "" {
+		ballotType, err := contracts.ParseBallotType(req.BallotType)
+		if err != nil {
+			return opts, err
+		}
+		opts.Ballot = &contracts.BallotConfig{Type: ballotType, Seats: req.Seats, MaxScore: req.MaxScore}
+	}
+	if req.ResultVisibility != "" {
+		visibility, err := contracts.ParseResultVisibility(req.ResultVisibility)
+		if err != nil {
+			return opts, err
+		}
+		opts.Visibility = visibility
+	}
+	if req.SpecialOptions != nil {
+		opts.SpecialOptions = make([]contracts.SpecialOption, 0, len(req.SpecialOptions))
+		for _, name := range req.SpecialOptions {
+			option, err := contracts.ParseSpecialOption(name)
+			if err != nil {
+				return opts, err
+			}
+			opts.SpecialOptions = append(opts.SpecialOptions, option)
+		}
+	}
+	if req.WithdrawnPolicy != "" {
+		policy, err := contracts.ParseWithdrawnVotePolicy(req.WithdrawnPolicy)
+		if err != nil {
+			return opts, err
+		}
+		opts.WithdrawnPolicy = policy
+	}
+	if req.TiePolicy != "" {
+		policy, err := contracts.ParseTiePolicy(req.TiePolicy)
+		if err != nil {
+			return opts, err
+		}
+		opts.TiePolicy = policy
+		if policy == contracts.TieByLot {
+			if opts.TieSeed, err = newTieSeed(); err != nil {
+				return opts, err
+			}
+		}
+	}
+	if req.RunoffThreshold != 0 {
+		opts.Runoff = &contracts.RunoffRule{Threshold: req.RunoffThreshold, Candidates: req.RunoffCandidates}
+	}
+	return opts, nil
+}
+
+// HandleStartElection returns the handler that opens voting. An election
+// started without a duration stays open for defaultDuration. The settings in
+// the request take effect only if the election starts.
+func HandleStartElection(defaultDuration time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log.Println("HandleStartElection called")
+		w.Header().Set("Content-Type", "application/json")
+
+		type Req struct {
+			Description   string `json:"description"`
+			DurationHours int    `json:"durationHours"`
+			setupRequest
+		}
+
+		var req Req
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid JSON format"})
+			return
+		}
+		opts, err := req.options()
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+
+		electionMu.Lock()
+		defer electionMu.Unlock()
+
+		// The options only take effect if the election starts
+		duration := time.Duration(req.DurationHours) * time.Hour
+		if req.DurationHours <= 0 {
+			duration = defaultDuration
+		}
+		commitment, err := election.StartWith(req.Description, duration, opts)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+
+		// Log to blockchain
+		if opts.TiePolicy != "" {
+			blockchainLogger.LogTiePolicy(actor(r), opts.TiePolicy, commitment, r)
+		}
+		details := map[string]interface{}{
+			"description":   req.Description,
+			"durationHours": duration.Hours(),
+			"endTime":       time.Now().Add(duration),
+			"ballot":        election.GetBallotConfig(),
+			"visibility":    election.GetResultVisibility(),
+			"withdrawn":     election.GetWithdrawnPolicy(),
+			"runoff":        election.Runoff,
+			"round":         election.GetRound(),
+		}
+		blockchainLogger.LogElectionAction("start", actor(r), req.Description, details, r)
+
+		if saveErr := election.SaveElection(); saveErr != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to save election data"})
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]string{"status": "election started"})
+	}
+}
+
+// HandleStopElection with blockchain logging
+func HandleStopElection(w http.ResponseWriter, r *http.Request) {
+	log.Println("HandleStopElection called")
+	w.Header().Set("Content-Type", "application/json")
+
+	electionMu.Lock()
+	defer electionMu.Unlock()
+
+	err := election.StopElection()
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	// Log to blockchain
+	details := map[string]interface{}{
+		"stoppedAt": time.Now(),
+	}
+	blockchainLogger.LogElectionAction("stop", actor(r), "Election manually stopped", details, r)
+
+	// Publish the final result as soon as polls close. A failure leaves the
+	// election closed; the certify endpoint can retry.
+	response := map[string]interface{}{"status": "election stopped"}
+	if cert, err := certifyResults(actor(r), r); err != nil {
+		log.Printf("Failed to certify results: %v", err)
+		response["certificationError"] = err.Error()
+	} else {
+		response["certification"] = cert
+		if trigger, err := generateRunoff(actor(r), r); err != nil {
+			log.Printf("Failed to create runoff: %v", err)
+			response["runoffError"] = err.Error()
+		} else if trigger != nil {
+			response["runoff"] = trigger
+		}
+	}
+
+	if saveErr := election.SaveElection(); saveErr != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to save election data"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// electionControlRequest is the body of the pause, resume and extend
+// endpoints. The acting admin is always recorded as the one who authorised
+// the action; a note naming anyone else is kept as given, unverified.
+type electionControlRequest struct {
+	Reason       string    `json:"reason"`
+	Note         string    `json:"note"`
+	AuthorizedBy string    `json:"authorizedBy"` // Older name for the note
+	EndTime      time.Time `json:"endTime"`      // Extend only
+}
+
+// decodeElectionControl reads a pause/resume/extend request
+func decodeElectionControl(w http.ResponseWriter, r *http.Request) (electionControlRequest, bool) {
+	var req electionControlRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid JSON format"})
+		return req, false
+	}
+	if req.Note == "" {
+		req.Note = req.AuthorizedBy
+	}
+	return req, true
+}
+
+// electionControlDetails are the chain details shared by the pause, resume
+// and extend transactions
+func electionControlDetails(req electionControlRequest, authorizedBy string) map[string]interface{} {
+	details := map[string]interface{}{
+		"reason":       req.Reason,
+		"authorizedBy": authorizedBy,
+	}
+	if req.Note != "" {
+		details["note"] = req.Note
+	}
+	return details
+}
+
+// HandlePauseElection suspends voting with a recorded reason
+func HandlePauseElection(w http.ResponseWriter, r *http.Request) {
+	log.Println("HandlePauseElection called")
+	w.Header().Set("Content-Type", "application/json")
+
+	req, ok := decodeElectionControl(w, r)
+	if !ok {
+		return
+	}
+
+	electionMu.Lock()
+	defer electionMu.Unlock()
+
+	transition, err := election.PauseVoting(req.Reason, actor(r), req.Note, time.Now())
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	// Log to blockchain
+	details := electionControlDetails(req, actor(r))
+	details["pausedAt"] = transition.At
+	details["endTime"] = election.Status.EndTime
+	blockchainLogger.LogElectionAction("pause", actor(r), req.Reason, details, r)
+
+	if saveErr := election.SaveElection(); saveErr != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to save election data"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":         "election paused",
+		"electionStatus": election.Status,
+	})
+}
+
+// HandleResumeElection reopens paused voting and restores the lost time
+func HandleResumeElection(w http.ResponseWriter, r *http.Request) {
+	log.Println("HandleResumeElection called")
+	w.Header().Set("Content-Type", "application/json")
+
+	req, ok := decodeElectionControl(w, r)
+	if !ok {
+		return
+	}
+
+	electionMu.Lock()
+	defer electionMu.Unlock()
+
+	previousEnd := election.Status.EndTime
+	transition, err := election.ResumeVoting(req.Reason, actor(r), req.Note, time.Now())
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	// Log to blockchain
+	details := electionControlDetails(req, actor(r))
+	details["resumedAt"] = transition.At
+	details["previousEnd"] = previousEnd
+	details["endTime"] = election.Status.EndTime
+	blockchainLogger.LogElectionAction("resume", actor(r), req.Reason, details, r)
+
+	if saveErr := election.SaveElection(); saveErr != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to save election data"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":         "election resumed",
+		"electionStatus": election.Status,
+	})
+}
+
+// HandleExtendElection moves the end of voting to a later time
+func HandleExtendElection(w http.ResponseWriter, r *http.Request) {
+	log.Println("HandleExtendElection called")
+	w.Header().Set("Content-Type", "application/json")
+
+	req, ok := decodeElectionControl(w, r)
+	if !ok {
+		return
+	}
+
+	electionMu.Lock()
+	defer electionMu.Unlock()
+
+	extension, err := election.ExtendVoting(req.EndTime, req.Reason, actor(r), req.Note, time.Now())
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	// Log to blockchain
+	details := electionControlDetails(req, extension.AuthorizedBy)
+	details["previousEnd"] = extension.PreviousEnd
+	details["endTime"] = extension.NewEnd
+	blockchainLogger.LogElectionAction("extend", actor(r), req.Reason, details, r)
+
+	if saveErr := election.SaveElection(); saveErr != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to save election data"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":         "election extended",
+		"electionStatus": election.Status,
+	})
+}
+
+// HandleScheduleElection sets a future voting window. The scheduler opens
+// and closes the election at these times and records both on the chain.
+func HandleScheduleElection(w http.ResponseWriter, r *http.Request) {
+	log.Println("HandleScheduleElection called")
 	w.Header().Set("Content-Type", "application/json")
 
 	type Req struct {
-		Description   string `json:"description"`
-		DurationHours int    `json:"durationHours"`
+		Description string    `json:"description"`
+		StartTime   time.Time `json:"startTime"`
+		EndTime     time.Time `json:"endTime"`
+		// ResultVisibility is hidden, turnout_only or live
+		ResultVisibility string `json:"resultVisibility,omitempty"`
+		// TiePolicy is lot, runoff or manual
+		TiePolicy string `json:"tiePolicy,omitempty"`
 	}
 
 	var req Req
@@ -605,13 +1128,18 @@ func HandleStartElection(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid JSON format"})
 		return
 	}
-
-	if req.DurationHours <= 0 {
-		req.DurationHours = 24
+	opts, err := setupRequest{ResultVisibility: req.ResultVisibility, TiePolicy: req.TiePolicy}.options()
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
 	}
 
-	duration := time.Duration(req.DurationHours) * time.Hour
-	err := election.StartElection(req.Description, duration)
+	electionMu.Lock()
+	defer electionMu.Unlock()
+
+	from := election.State()
+	commitment, err := election.ScheduleWith(req.Description, req.StartTime, req.EndTime, opts)
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
@@ -619,12 +1147,15 @@ func HandleStartElection(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Log to blockchain
-	details := map[string]interface{}{
-		"description":   req.Description,
-		"durationHours": req.DurationHours,
-		"endTime":       time.Now().Add(duration),
+	if opts.TiePolicy != "" {
+		blockchainLogger.LogTiePolicy(actor(r), opts.TiePolicy, commitment, r)
 	}
-	blockchainLogger.LogElectionAction("start", "admin", req.Description, details, r)
+	blockchainLogger.LogElectionTransition(actor(r), contracts.StateTransition{
+		From:   from,
+		To:     election.State(),
+		At:     time.Now(),
+		Reason: fmt.Sprintf("voting window %s to %s", req.StartTime.Format(time.RFC3339), req.EndTime.Format(time.RFC3339)),
+	}, r)
 
 	if saveErr := election.SaveElection(); saveErr != nil {
 		w.WriteHeader(http.StatusInternalServerError)
@@ -632,26 +1163,100 @@ func HandleStartElection(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	json.NewEncoder(w).Encode(map[string]string{"status": "election started"})
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":         "election scheduled",
+		"electionStatus": election.Status,
+	})
 }
 
-// HandleStopElection with blockchain logging
-func HandleStopElection(w http.ResponseWriter, r *http.Request) {
-	log.Println("HandleStopElection called")
+// HandleElectionTransition moves the election to another lifecycle state,
+// e.g. opening registration or archiving a certified election
+func HandleElectionTransition(w http.ResponseWriter, r *http.Request) {
+	log.Println("HandleElectionTransition called")
 	w.Header().Set("Content-Type", "application/json")
 
-	err := election.StopElection()
+	type Req struct {
+		State  string `json:"state"`
+		Reason string `json:"reason"`
+	}
+
+	var req Req
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid JSON format"})
+		return
+	}
+
+	state, err := contracts.ParseElectionState(req.State)
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
 		return
 	}
 
+	electionMu.Lock()
+	defer electionMu.Unlock()
+
+	transition, err := election.Transition(state, req.Reason)
+	if err != nil {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
 	// Log to blockchain
-	details := map[string]interface{}{
-		"stoppedAt": time.Now(),
+	blockchainLogger.LogElectionTransition(actor(r), transition, r)
+
+	if saveErr := election.SaveElection(); saveErr != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to save election data"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":     "election state changed",
+		"transition": transition,
+	})
+}
+
+// HandleNewElection follows an archived election with a new draft one. The
+// archived election is saved to its round file and stays readable through
+// the new election's link to it.
+func HandleNewElection(w http.ResponseWriter, r *http.Request) {
+	log.Println("HandleNewElection called")
+	w.Header().Set("Content-Type", "application/json")
+
+	type Req struct {
+		Description string `json:"description"`
+	}
+
+	var req Req
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid JSON format"})
+		return
+	}
+
+	electionMu.Lock()
+	defer electionMu.Unlock()
+
+	next, transition, err := election.NextElection(req.Description, time.Now())
+	if err != nil {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	if err := election.SaveRound(); err != nil {
+		log.Printf("Failed to archive election %d: %v", election.GetNumber(), err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to archive the election"})
+		return
 	}
-	blockchainLogger.LogElectionAction("stop", "admin", "Election manually stopped", details, r)
+
+	// Log to blockchain
+	tx := blockchainLogger.LogElectionTransition(actor(r), transition, r)
+	next.PreviousElection.TransactionID = tx.ID
+	election = next
 
 	if saveErr := election.SaveElection(); saveErr != nil {
 		w.WriteHeader(http.StatusInternalServerError)
@@ -659,13 +1264,21 @@ func HandleStopElection(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	json.NewEncoder(w).Encode(map[string]string{"status": "election stopped"})
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":           "election created",
+		"election":         election.GetNumber(),
+		"transition":       transition,
+		"previousElection": election.PreviousElection,
+	})
 }
 
 func HandleElectionStatus(w http.ResponseWriter, r *http.Request) {
 	log.Println("HandleElectionStatus called")
 	w.Header().Set("Content-Type", "application/json")
 
+	electionMu.RLock()
+	defer electionMu.RUnlock()
+
 	if election == nil {
 		log.Println("Election is nil!")
 		w.WriteHeader(http.StatusInternalServerError)
@@ -678,6 +1291,7 @@ func HandleElectionStatus(w http.ResponseWriter, r *http.Request) {
 
 	status := map[string]interface{}{
 		"isActive": election.IsElectionActive(),
+		"state":    election.State(),
 		"status":   election.Status,
 	}
 	log.Printf("Election status: %v", status)
@@ -694,6 +1308,9 @@ func HandleElectionResults(w http.ResponseWriter, r *http.Request) {
 	log.Println("HandleElectionResults called")
 	w.Header().Set("Content-Type", "application/json")
 
+	electionMu.RLock()
+	defer electionMu.RUnlock()
+
 	if election == nil {
 		log.Println("Election is nil!")
 		w.WriteHeader(http.StatusInternalServerError)
@@ -701,6 +1318,22 @@ func HandleElectionResults(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !election.CountsVisible() {
+		writeResultsEmbargoed(w)
+		return
+	}
+
+	// Winners come from the server's count, including resolved ties
+	contests := election.ContestResults()
+	elected := make(map[string]bool)
+	for _, c := range contests {
+		if c.ContestID == contracts.CandidateRaceID {
+			for _, id := range c.Winners {
+				elected[id] = true
+			}
+		}
+	}
+
 	candidates := election.ListCandidates()
 	results := make([]map[string]interface{}, 0, len(candidates))
 
@@ -710,14 +1343,28 @@ func HandleElectionResults(w http.ResponseWriter, r *http.Request) {
 			"name":        candidate.Name,
 			"party":       candidate.PartyName,
 			"votes":       candidate.Votes,
+			"status":      candidate.GetStatus(),
+			"elected":     elected[candidate.CandidateID],
 			"imageUrl":    candidate.ImageURL,
 		})
 	}
 
 	response := map[string]interface{}{
 		"results":        results,
+		"ballot":         election.GetBallotConfig(),
+		"count":          election.Count(),
+		"specialOptions": election.SpecialOptionResults(),
+		"contests":       contests,
+		"provisional":    election.ProvisionalCounts(),
 		"electionStatus": election.Status,
-		"statistics":     election.GetStatistics(),
+		"statistics":     election.VisibleStatistics(),
+	}
+	if election.Certification != nil {
+		response["certification"] = map[string]interface{}{
+			"documentHash":  election.Certification.DocumentHash,
+			"transactionId": election.Certification.TransactionID,
+			"url":           "/election/results/certified",
+		}
 	}
 	log.Printf("Election results: %v", response)
 
@@ -733,6 +1380,9 @@ func HandleElectionStatistics(w http.ResponseWriter, r *http.Request) {
 	log.Println("HandleElectionStatistics called")
 	w.Header().Set("Content-Type", "application/json")
 
+	electionMu.RLock()
+	defer electionMu.RUnlock()
+
 	if election == nil {
 		log.Println("Election is nil!")
 		w.WriteHeader(http.StatusInternalServerError)
@@ -742,7 +1392,7 @@ func HandleElectionStatistics(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	stats := election.GetStatistics()
+	stats := election.VisibleStatistics()
 	log.Printf("Election statistics: %v", stats)
 
 	if err := json.NewEncoder(w).Encode(stats); err != nil {
@@ -784,6 +1434,9 @@ func HandleAddUser(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Received user data: UserID=%s, Name=%s, Email=%s, Phone=%s, Address=%s",
 		req.UserID, req.Name, req.Email, req.Phone, req.Address)
 
+	electionMu.Lock()
+	defer electionMu.Unlock()
+
 	err := election.AddUser(req.UserID, req.Name, req.Email, req.Phone, req.Address)
 	if err != nil {
 		log.Printf("Failed to add user: %v", err)
@@ -813,6 +1466,9 @@ func HandleListUsers(w http.ResponseWriter, r *http.Request) {
 	log.Println("HandleListUsers called")
 	w.Header().Set("Content-Type", "application/json")
 
+	electionMu.RLock()
+	defer electionMu.RUnlock()
+
 	if election == nil {
 		log.Println("Election is nil!")
 		w.WriteHeader(http.StatusInternalServerError)
@@ -841,6 +1497,10 @@ func HandleGetUser(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	id := mux.Vars(r)["id"]
+
+	electionMu.RLock()
+	defer electionMu.RUnlock()
+
 	user, err := election.GetUser(id)
 	if err != nil {
 		log.Printf("Failed to get user: %v", err)
@@ -881,6 +1541,9 @@ func HandleUpdateUser(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Received user update data: ID=%s, Name=%s, Email=%s, Phone=%s, Address=%s",
 		id, req.Name, req.Email, req.Phone, req.Address)
 
+	electionMu.Lock()
+	defer electionMu.Unlock()
+
 	err := election.UpdateUser(id, req.Name, req.Email, req.Phone, req.Address)
 	if err != nil {
 		log.Printf("Failed to update user: %v", err)
@@ -906,6 +1569,10 @@ func HandleDeleteUser(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	id := mux.Vars(r)["id"]
+
+	electionMu.Lock()
+	defer electionMu.Unlock()
+
 	err := election.RemoveUser(id)
 	if err != nil {
 		log.Printf("Failed to delete user: %v", err)
@@ -931,7 +1598,7 @@ func HandleGetRegisteredVoters(w http.ResponseWriter, r *http.Request) {
 	log.Println("HandleGetRegisteredVoters called")
 	w.Header().Set("Content-Type", "application/json")
 
-	registeredUsers, err := contracts.LoadRegisteredUsers()
+	registeredUsers, err := storage.LoadRegisteredUsers()
 	if err != nil {
 		log.Printf("Failed to load registered users: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
@@ -943,30 +1610,34 @@ func HandleGetRegisteredVoters(w http.ResponseWriter, r *http.Request) {
 	votersWithDetails := make([]map[string]interface{}, 0, len(registeredUsers))
 
 	// Load voter database to get additional details
-	db, err := contracts.LoadVoterDatabase()
+	db, err := storage.LoadVoterDatabase()
 	if err != nil {
 		log.Printf("Failed to load voter database: %v", err)
 		// Still return registered users without additional details
 		for _, user := range registeredUsers {
 			votersWithDetails = append(votersWithDetails, map[string]interface{}{
-				"voterID":  user.VoterID,
-				"username": user.Username,
-				"email":    user.Email,
-				"name":     "N/A",
-				"dob":      "N/A",
-				"location": "N/A",
+				"voterID":       user.VoterID,
+				"username":      user.Username,
+				"email":         user.Email,
+				"name":          "N/A",
+				"dob":           "N/A",
+				"location":      "N/A",
+				"twoFactor":     user.TwoFactor.Enabled(),
+				"emailVerified": user.EmailVerified(),
 			})
 		}
 	} else {
 		// Include details from voter database
 		for _, user := range registeredUsers {
 			voterDetail := map[string]interface{}{
-				"voterID":  user.VoterID,
-				"username": user.Username,
-				"email":    user.Email,
-				"name":     "N/A",
-				"dob":      "N/A",
-				"location": "N/A",
+				"voterID":       user.VoterID,
+				"username":      user.Username,
+				"email":         user.Email,
+				"name":          "N/A",
+				"dob":           "N/A",
+				"location":      "N/A",
+				"twoFactor":     user.TwoFactor.Enabled(),
+				"emailVerified": user.EmailVerified(),
 			}
 
 			if voter, exists := db.Records[user.VoterID]; exists {
@@ -996,36 +1667,13 @@ func HandleDeleteRegisteredVoter(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	voterID := mux.Vars(r)["voterID"]
-	// Load current registered users
-	registeredUsers, err := contracts.LoadRegisteredUsers()
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to load registered users"})
-		return
-	}
-
-	// Find voter info before deletion
-	var voterEmail string
-	found := false
-	updatedUsers := make([]contracts.RegisteredUser, 0, len(registeredUsers))
-
-	for _, user := range registeredUsers {
-		if user.VoterID != voterID {
-			updatedUsers = append(updatedUsers, user)
-		} else {
-			found = true
-			voterEmail = user.Email
-		}
-	}
-
-	if !found {
+	removed, err := removeRegistration(voterID)
+	if errors.Is(err, errVoterNotFound) {
 		w.WriteHeader(http.StatusNotFound)
 		json.NewEncoder(w).Encode(map[string]string{"error": "Voter not found"})
 		return
 	}
-
-	// Save updated list
-	if err := contracts.SaveRegisteredUsers(updatedUsers); err != nil {
+	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to save updated registered users"})
 		return
@@ -1033,9 +1681,9 @@ func HandleDeleteRegisteredVoter(w http.ResponseWriter, r *http.Request) {
 
 	// Log to blockchain
 	details := map[string]interface{}{
-		"email": voterEmail,
+		"email": removed.Email,
 	}
-	blockchainLogger.LogUserAction("delete_voter", "admin", voterID, details, r)
+	blockchainLogger.LogUserAction("delete_voter", actor(r), voterID, details, r)
 
 	json.NewEncoder(w).Encode(map[string]string{
 		"status":  "voter deleted",
@@ -3,36 +3,28 @@ package server
 import (
 	"e-voting-blockchain/contracts"
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
+	"net/url"
 	"time"
-
-	"github.com/golang-jwt/jwt/v5"
 )
 
-var jwtKey = []byte("super-secret-admin-key") // for simplicity, change this later
-var currentValidToken string                  // in-memory storage for now
-
-// Admin credentials
-const adminUsername = "admin@devote.com" // Change this to match frontend
-const adminPassword = "admin123"
-
 type AdminLoginRequest struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
 }
 
-type Claims struct {
-	Username string `json:"username"`
-	jwt.RegisteredClaims
-}
-
 // Admin login route
 func HandleAdminLogin(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
+	// Code is the TOTP code; RecoveryCode can be given instead
 	type LoginRequest struct {
-		Username string `json:"username"`
-		Password string `json:"password"`
+		Username     string `json:"username"`
+		Password     string `json:"password"`
+		Code         string `json:"code,omitempty"`
+		RecoveryCode string `json:"recoveryCode,omitempty"`
 	}
 
 	var req LoginRequest
@@ -41,32 +33,96 @@ func HandleAdminLogin(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid JSON format"})
 		return
 	}
+	login := throttleLogin(w, r, "admin", req.Username, "error")
+	if login == nil {
+		return
+	}
+	defer login.release()
 
-	// Simple admin validation - replace with your actual logic
-	if req.Username == "admin@devote.com" && req.Password == "admin123" {
-		// Generate a simple token (in production, use JWT)
-		token := "admin_token_" + time.Now().Format("20060102150405")
-		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"token": token,
-			"user": map[string]string{
-				"username": req.Username,
-				"role":     "admin",
-			},
-			"message": "Login successful",
-		})
+	adminMu.Lock()
+	store, err := loadAdminStore()
+	var account contracts.AdminAccount
+	found := false
+	if err == nil {
+		account, found = store.GetAdmin(req.Username)
+	}
+	adminMu.Unlock()
+	if err != nil {
+		log.Printf("Failed to load admin accounts: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to load admin account"})
+		return
+	}
+
+	// An unknown or disabled account costs as much as a wrong password, so
+	// the response time does not reveal which failed
+	valid := false
+	if found {
+		valid = contracts.VerifyPassword(account.Password, req.Password) && !account.Disabled
 	} else {
+		contracts.VerifyNoPassword(req.Password)
+	}
+	if !valid {
+		login.failed("invalid credentials")
 		w.WriteHeader(http.StatusUnauthorized)
 		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid credentials"})
+		return
+	}
+
+	// Two-factor authentication is mandatory for admins; one who has not
+	// enrolled gets a token that only allows enrolling
+	if !account.TwoFactor.Enabled() {
+		claims := &Claims{Username: account.Username, Role: string(account.Role), TokenType: tokenEnrol}
+		claims.Subject = account.Username
+		token, err := issueToken(claims, enrolTokenTTL)
+		if err != nil {
+			log.Printf("Failed to issue enrolment token: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to issue token"})
+			return
+		}
+		login.succeeded()
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"twoFactorEnrolmentRequired": true,
+			"enrolmentToken":             token,
+			"message":                    "Enrol in two-factor authentication to continue",
+		})
+		return
 	}
+	if err := adminSecondFactor(account.Username, req.Code, req.RecoveryCode, r); err != nil {
+		login.secondFactorFailed(err)
+		writeTwoFactorError(w, err)
+		return
+	}
+
+	response, err := issueAdminTokens(account)
+	if err != nil {
+		log.Printf("Failed to issue admin tokens: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to issue token"})
+		return
+	}
+	response["user"] = map[string]interface{}{
+		"username":    account.Username,
+		"role":        account.Role,
+		"permissions": account.Role.Permissions(),
+	}
+	response["message"] = "Login successful"
+	login.succeeded()
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
 }
 
 func HandleUserLogin(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
+	// Code is the TOTP code of a voter who has enrolled; RecoveryCode can be
+	// given instead
 	type UserLoginRequest struct {
-		Username string `json:"username"`
-		Password string `json:"password"`
+		Username     string `json:"username"`
+		Password     string `json:"password"`
+		Code         string `json:"code,omitempty"`
+		RecoveryCode string `json:"recoveryCode,omitempty"`
 	}
 
 	var creds UserLoginRequest
@@ -75,53 +131,216 @@ func HandleUserLogin(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(map[string]string{"message": "Invalid input"})
 		return
 	}
+	login := throttleLogin(w, r, "voter", creds.Username, "message")
+	if login == nil {
+		return
+	}
+	defer login.release()
 
 	// Load registered users
-	registeredUsers, err := contracts.LoadRegisteredUsers()
+	registeredUsers, err := storage.LoadRegisteredUsers()
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]string{"message": "Failed to load registered users"})
 		return
 	}
 
-	// Validate credentials strictly with username/password only
-	valid := false
+	// Validate credentials strictly with username/password only; an unknown
+	// username costs as much as a wrong password
+	valid, found := false, false
+	var account contracts.RegisteredUser
 	for _, user := range registeredUsers {
-		if user.Username == creds.Username && user.Password == creds.Password {
-			valid = true
+		if user.Username == creds.Username {
+			found = true
+			valid = contracts.VerifyPassword(user.Password, creds.Password)
+			account = user
 			break
 		}
 	}
+	if !found {
+		contracts.VerifyNoPassword(creds.Password)
+	}
 
 	if !valid {
+		login.failed("invalid credentials")
 		w.WriteHeader(http.StatusUnauthorized)
 		json.NewEncoder(w).Encode(map[string]string{"message": "Invalid credentials"})
 		return
 	}
+	if !account.EmailVerified() {
+		blockchainLogger.LogLogin("voter", creds.Username, false, "email not verified", r)
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message":                   "Confirm your email address before logging in",
+			"emailVerificationRequired": true,
+		})
+		return
+	}
+	if account.TwoFactor.Enabled() {
+		err := updateRegisteredUser(account.VoterID, func(user *contracts.RegisteredUser) error {
+			return checkSecondFactor(user.TwoFactor, user.VoterID, creds.Code, creds.RecoveryCode, r)
+		})
+		if err != nil {
+			login.secondFactorFailed(err)
+			writeTwoFactorError(w, err)
+			return
+		}
+	}
+
+	// Eligibility is checked once per session, here; a voter who fails it
+	// gets a token that only allows a provisional ballot
+	claims := &Claims{Username: account.Username, Role: roleVoter, TokenType: tokenAccess}
+	claims.Subject = account.VoterID
+	db, err := storage.LoadVoterDatabase()
+	if err != nil {
+		log.Printf("Failed to load voter registry: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"message": "Failed to load voter registry"})
+		return
+	}
+	if err := db.CheckEligibility(account); err != nil {
+		log.Printf("Eligibility check failed for %s: %v", account.VoterID, err)
+		claims.Challenge = err.Error()
+	}
 
 	// create jwt token
-	expirationTime := time.Now().Add(1 * time.Hour)
-	claims := &Claims{
-		Username: creds.Username,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
-		},
-	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, _ := token.SignedString(jwtKey)
-	json.NewEncoder(w).Encode(map[string]string{"token": tokenString})
+	tokenString, err := issueToken(claims, voterTokenTTL)
+	if err != nil {
+		log.Printf("Failed to issue voter token: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"message": "Failed to issue token"})
+		return
+	}
+	login.succeeded()
+	response := map[string]interface{}{"token": tokenString, "voterId": account.VoterID, "eligible": claims.Challenge == ""}
+	if claims.Challenge != "" {
+		response["challenge"] = claims.Challenge
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// HandleRefreshToken exchanges an admin refresh token for a new access and
+// refresh token pair. The refresh token used is revoked, so each can be used
+// only once.
+func HandleRefreshToken(w http.ResponseWriter, r *http.Request) {
+	log.Println("HandleRefreshToken called")
+	w.Header().Set("Content-Type", "application/json")
+
+	type Req struct {
+		RefreshToken string `json:"refreshToken"`
+	}
+
+	var req Req
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid JSON format"})
+		return
+	}
+
+	claims, err := parseToken(req.RefreshToken, tokenRefresh)
+	var account contracts.AdminAccount
+	if err == nil {
+		account, err = activeAdmin(claims.Subject)
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid or expired refresh token"})
+		return
+	}
+	err = consumeToken(claims)
+	if errors.Is(err, errTokenRevoked) {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid or expired refresh token"})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to revoke refresh token: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to refresh token"})
+		return
+	}
+
+	response, err := issueAdminTokens(account)
+	if err != nil {
+		log.Printf("Failed to issue admin tokens: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to issue token"})
+		return
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// HandleAdminLogout revokes the access token the request was made with and,
+// if given, the session's refresh token
+func HandleAdminLogout(w http.ResponseWriter, r *http.Request) {
+	log.Println("HandleAdminLogout called")
+	w.Header().Set("Content-Type", "application/json")
+
+	type Req struct {
+		RefreshToken string `json:"refreshToken"`
+	}
+
+	var req Req
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid JSON format"})
+			return
+		}
+	}
+
+	revoke := []*Claims{requestClaims(r)}
+	if req.RefreshToken != "" {
+		if claims, err := parseToken(req.RefreshToken, tokenRefresh); err == nil {
+			revoke = append(revoke, claims)
+		}
+	}
+	for _, claims := range revoke {
+		if err := revokeToken(claims); err != nil {
+			log.Printf("Failed to revoke token: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to log out"})
+			return
+		}
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"status": "logged out"})
+}
+
+// HandleRotateSigningKey makes a new key the token signing key. Tokens
+// signed with the previous keys stay valid until they expire.
+func HandleRotateSigningKey(w http.ResponseWriter, r *http.Request) {
+	log.Println("HandleRotateSigningKey called")
+	w.Header().Set("Content-Type", "application/json")
+
+	key, err := rotateSigningKey()
+	if err != nil {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	log.Printf("Token signing key rotated to %s", key.ID)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":    "signing key rotated",
+		"kid":       key.ID,
+		"createdAt": key.CreatedAt,
+	})
 }
 
 // HandleUserRegister registers a new voter
 func HandleUserRegister(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
+	// Language is the one emails are written in; the browser's is used if
+	// it is not given
 	type RegisterRequest struct {
 		VoterID  string `json:"voterId"`
 		Name     string `json:"name"`
 		DOB      string `json:"dob"`
 		Location string `json:"location"`
 		Email    string `json:"email"`
+		Language string `json:"language,omitempty"`
 	}
 
 	var req RegisterRequest
@@ -132,7 +351,7 @@ func HandleUserRegister(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Validate against government database
-	db, err := contracts.LoadVoterDatabase()
+	db, err := storage.LoadVoterDatabase()
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]string{"error": "Could not load voter database"})
@@ -146,55 +365,93 @@ func HandleUserRegister(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check if already registered
-	registered, err := contracts.LoadRegisteredUsers()
+	// Generate credentials
+	username := "voter_" + req.VoterID
+	password, err := contracts.GenerateSecurePassword(8)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Could not load registered users"})
+		json.NewEncoder(w).Encode(map[string]string{"error": "Password generation failed"})
 		return
 	}
 
-	for _, u := range registered {
-		if u.VoterID == req.VoterID {
-			w.WriteHeader(http.StatusConflict)
-			json.NewEncoder(w).Encode(map[string]string{"error": "VoterID is already registered"})
-			return
-		}
-		if u.Email == req.Email {
-			w.WriteHeader(http.StatusConflict)
-			json.NewEncoder(w).Encode(map[string]string{"error": "Voter Email is already registered"})
-			return
-		}
+	hash, err := contracts.HashPassword(password)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Password generation failed"})
+		return
 	}
 
-	// Generate credentials
-	username := "voter_" + req.VoterID
-	password, err := contracts.GenerateSecurePassword(8)
+	token, verification, err := contracts.NewEmailVerification(time.Now(), verificationValid)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Password generation failed"})
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to create verification link"})
 		return
 	}
 
+	// The account cannot be used until the voter confirms their address
+	// from the link sent with their login details
 	newUser := contracts.RegisteredUser{
-		Username: username,
-		Password: password,
-		VoterID:  req.VoterID,
-		Email:    req.Email,
+		Username:     username,
+		Password:     hash,
+		VoterID:      req.VoterID,
+		Email:        req.Email,
+		Language:     requestLanguage(r, req.Language),
+		Verification: verification,
 	}
-	registered = append(registered, newUser)
-
-	if err := contracts.SaveRegisteredUsers(registered); err != nil {
+	// Save the voter unless they or their email are already registered
+	err = addRegistration(newUser)
+	switch {
+	case errors.Is(err, errVoterRegistered):
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": "VoterID is already registered"})
+		return
+	case errors.Is(err, errEmailRegistered):
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Voter Email is already registered"})
+		return
+	case err != nil:
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to save credentials"})
 		return
 	}
 
+	err = mailer.send(newUser.Language, "registration", newUser.Email, map[string]interface{}{
+		"VoterID":    newUser.VoterID,
+		"Username":   username,
+		"Password":   password,
+		"Link":       mailer.publicLink("/verify-email", url.Values{"token": {token}}),
+		"ValidHours": int(verificationValid.Hours()),
+	})
+	if err != nil {
+		// Without the email the voter has no way to log in, so the
+		// registration is undone and they can try again. Only the new
+		// record is removed; others may have registered in the meantime.
+		log.Printf("Failed to send registration email to voter %s: %v", req.VoterID, err)
+		if _, err := removeRegistration(newUser.VoterID); err != nil {
+			log.Printf("Failed to undo registration of voter %s: %v", req.VoterID, err)
+		}
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to send registration email; please try again"})
+		return
+	}
+
 	// Log to blockchain
 	blockchainLogger.LogVoterRegistration(req.VoterID, req.Email, r)
 
 	json.NewEncoder(w).Encode(map[string]string{
-		"username": username,
-		"password": password,
+		"status":  "registered",
+		"message": "Your login details and a link to confirm your email address have been sent to " + req.Email,
 	})
 }
+
+// migratePasswords hashes voter passwords stored in plaintext by earlier
+// versions
+func migratePasswords() {
+	n, err := storage.MigratePasswords()
+	if err != nil {
+		log.Printf("Failed to hash stored passwords: %v", err)
+	}
+	if n > 0 {
+		log.Printf("Hashed %d plaintext passwords", n)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strconv"
 	"time"
 
@@ -27,6 +28,7 @@ type ConnectionManager struct {
 	broadcast   chan blockchain.Transaction
 	register    chan *websocket.Conn
 	unregister  chan *websocket.Conn
+	shutdown    chan chan struct{}
 }
 
 var connManager = &ConnectionManager{
@@ -34,6 +36,7 @@ var connManager = &ConnectionManager{
 	broadcast:   make(chan blockchain.Transaction),
 	register:    make(chan *websocket.Conn),
 	unregister:  make(chan *websocket.Conn),
+	shutdown:    make(chan chan struct{}),
 }
 
 // StartWebSocketHub starts the WebSocket connection manager
@@ -41,9 +44,28 @@ func StartWebSocketHub() {
 	go connManager.run()
 }
 
+// CloseWebSockets tells every WebSocket client the server is going away,
+// closes the connections and stops the hub
+func CloseWebSockets() {
+	done := make(chan struct{})
+	connManager.shutdown <- done
+	<-done
+}
+
 func (cm *ConnectionManager) run() {
 	for {
 		select {
+		case done := <-cm.shutdown:
+			msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+			for conn := range cm.connections {
+				conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+				conn.Close()
+				delete(cm.connections, conn)
+			}
+			log.Println("WebSocket clients disconnected for shutdown")
+			close(done)
+			return
+
 		case conn := <-cm.register:
 			cm.connections[conn] = true
 			log.Printf("WebSocket client connected. Total: %d", len(cm.connections))
@@ -96,7 +118,10 @@ func HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 				if !ok {
 					return
 				}
-				connManager.broadcast <- tx
+				// Ballots go out only as far as the results embargo allows
+				if tx, ok := currentEmbargo().transaction(tx); ok {
+					connManager.broadcast <- tx
+				}
 			}
 		}
 	}()
@@ -110,12 +135,14 @@ func HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// HandleGetBlockchain returns the entire blockchain
+// HandleGetBlockchain returns the entire blockchain, as far as the results
+// embargo allows
 func HandleGetBlockchain(w http.ResponseWriter, r *http.Request) {
 	log.Println("HandleGetBlockchain called")
 	w.Header().Set("Content-Type", "application/json")
 
-	if err := json.NewEncoder(w).Encode(chain.Chain); err != nil {
+	blocks := currentEmbargo().blocks(chain.Blocks())
+	if err := json.NewEncoder(w).Encode(blocks); err != nil {
 		log.Printf("Failed to encode blockchain: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to encode blockchain"})
@@ -139,15 +166,17 @@ func HandleGetTransactions(w http.ResponseWriter, r *http.Request) {
 		transactions = chain.GetTransactionsByType(blockchain.TransactionType(txType))
 	} else if actor != "" {
 		transactions = chain.GetTransactionsByActor(actor)
-	} else if limitStr != "" {
-		if limit, err := strconv.Atoi(limitStr); err == nil {
-			transactions = chain.GetRecentTransactions(limit)
-		} else {
-			transactions = chain.GetAllTransactions()
-		}
 	} else {
 		transactions = chain.GetAllTransactions()
 	}
+	transactions = currentEmbargo().transactions(transactions)
+
+	// The limit applies to what may be published
+	if txType == "" && actor == "" && limitStr != "" {
+		if limit, err := strconv.Atoi(limitStr); err == nil && limit >= 0 && limit < len(transactions) {
+			transactions = transactions[len(transactions)-limit:]
+		}
+	}
 
 	// Reverse order to show newest first
 	for i, j := 0, len(transactions)-1; i < j; i, j = i+1, j-1 {
@@ -168,6 +197,31 @@ func HandleGetBlockchainStats(w http.ResponseWriter, r *http.Request) {
 
 	stats := chain.GetStats()
 
+	// The number of vote transactions is the turnout, which a fully
+	// embargoed election does not publish, and the blocks after the first
+	// ballot would give it away
+	if embargo := currentEmbargo(); embargo.turnout {
+		withheld := embargo.withheldVotes(chain.GetAllTransactions())
+		stats.TotalTransactions -= withheld
+		stats.TransactionTypes[blockchain.TxTypeVote] -= withheld
+		if stats.TransactionTypes[blockchain.TxTypeVote] <= 0 {
+			delete(stats.TransactionTypes, blockchain.TxTypeVote)
+		}
+
+		published := embargo.blocks(chain.Blocks())
+		stats.TotalBlocks = len(published)
+		if len(published) > 0 {
+			stats.LastBlockTime = published[len(published)-1].GetTimestamp()
+		}
+		var invalid []int
+		for _, index := range stats.InvalidBlocks {
+			if index < len(published) {
+				invalid = append(invalid, index)
+			}
+		}
+		stats.InvalidBlocks = invalid
+	}
+
 	if err := json.NewEncoder(w).Encode(stats); err != nil {
 		log.Printf("Failed to encode blockchain stats: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
@@ -180,7 +234,8 @@ func HandleGetIntegrityReport(w http.ResponseWriter, r *http.Request) {
 	log.Println("HandleGetIntegrityReport called")
 	w.Header().Set("Content-Type", "application/json")
 
-	report := chain.GetIntegrityReport()
+	embargo := currentEmbargo()
+	report := embargo.integrityReport(chain.GetIntegrityReport(), len(embargo.blocks(chain.Blocks())))
 
 	if err := json.NewEncoder(w).Encode(report); err != nil {
 		log.Printf("Failed to encode integrity report: %v", err)
@@ -204,7 +259,13 @@ func HandleGetTransaction(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if transaction == nil {
+	// A ballot withheld by the results embargo is not found until it is
+	// published
+	ok := transaction != nil
+	if ok {
+		*transaction, ok = currentEmbargo().transaction(*transaction)
+	}
+	if !ok {
 		w.WriteHeader(http.StatusNotFound)
 		json.NewEncoder(w).Encode(map[string]string{"error": "Transaction not found"})
 		return
@@ -232,20 +293,16 @@ func HandleGetBlock(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	block, err := chain.GetBlockByIndex(index)
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to get block"})
-		return
-	}
-
-	if block == nil {
+	// Blocks withheld by the results embargo are not found until they are
+	// published
+	blocks := currentEmbargo().blocks(chain.Blocks())
+	if index < 0 || index >= len(blocks) {
 		w.WriteHeader(http.StatusNotFound)
 		json.NewEncoder(w).Encode(map[string]string{"error": "Block not found"})
 		return
 	}
 
-	if err := json.NewEncoder(w).Encode(block); err != nil {
+	if err := json.NewEncoder(w).Encode(blocks[index]); err != nil {
 		log.Printf("Failed to encode block: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to encode block"})
@@ -257,7 +314,8 @@ func HandleVerifyBlockchain(w http.ResponseWriter, r *http.Request) {
 	log.Println("HandleVerifyBlockchain called")
 	w.Header().Set("Content-Type", "application/json")
 
-	report := chain.GetIntegrityReport()
+	embargo := currentEmbargo()
+	report := embargo.integrityReport(chain.GetIntegrityReport(), len(embargo.blocks(chain.Blocks())))
 
 	response := map[string]interface{}{
 		"valid":             report.IsValid,
@@ -265,7 +323,7 @@ func HandleVerifyBlockchain(w http.ResponseWriter, r *http.Request) {
 		"validBlocks":       report.ValidBlocks,
 		"invalidBlocks":     report.InvalidBlocks,
 		"verifiedAt":        report.CheckedAt,
-		"totalTransactions": len(chain.GetAllTransactions()),
+		"totalTransactions": len(embargo.transactions(chain.GetAllTransactions())),
 	}
 
 	if err := json.NewEncoder(w).Encode(response); err != nil {
@@ -275,14 +333,12 @@ func HandleVerifyBlockchain(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// HandleSystemReset handles complete system reset (admin only)
+// HandleSystemReset handles complete system reset. It runs only once a
+// reset proposal has been approved.
 func HandleSystemReset(w http.ResponseWriter, r *http.Request) {
 	log.Println("HandleSystemReset called")
 	w.Header().Set("Content-Type", "application/json")
 
-	// This should be protected by admin authentication in production
-	// For now, we'll implement a basic reset
-
 	resetResult := performSystemReset()
 
 	if err := json.NewEncoder(w).Encode(resetResult); err != nil {
@@ -322,8 +378,13 @@ func performSystemReset() SystemResetResult {
 		"voters.json",
 		"users.json",
 		"admin_sessions.json",
-		"admin.json",
 	}
+	for i, file := range filesToDelete {
+		filesToDelete[i] = storage.Path(file)
+	}
+	// Archived rounds of runoff elections and of earlier elections
+	rounds, _ := filepath.Glob(storage.Path("election*_round*.json"))
+	filesToDelete = append(filesToDelete, rounds...)
 
 	for _, file := range filesToDelete {
 		if err := os.Remove(file); err != nil {
@@ -1,40 +1,95 @@
 package server
 
 import (
+	"crypto/sha256"
 	"e-voting-blockchain/blockchain"
+	"e-voting-blockchain/contracts"
+	"encoding/hex"
+	"fmt"
+	"net"
 	"net/http"
+	"net/netip"
+	"sort"
+	"strconv"
 	"strings"
 )
 
 // BlockchainLogger handles logging all actions to blockchain
 type BlockchainLogger struct {
 	chain *blockchain.Blockchain
+
+	// trustedProxies are the reverse proxies whose forwarding headers are
+	// believed
+	trustedProxies []netip.Prefix
 }
 
-// NewBlockchainLogger creates a new blockchain logger
-func NewBlockchainLogger(chain *blockchain.Blockchain) *BlockchainLogger {
-	return &BlockchainLogger{chain: chain}
+// NewBlockchainLogger creates a new blockchain logger. Client addresses are
+// only taken from the forwarding headers of requests from the trusted
+// proxies.
+func NewBlockchainLogger(chain *blockchain.Blockchain, trustedProxies []netip.Prefix) *BlockchainLogger {
+	return &BlockchainLogger{chain: chain, trustedProxies: trustedProxies}
 }
 
-// LogTransaction logs a transaction to the blockchain
+// LogTransaction logs a transaction to the blockchain and returns it
 func (bl *BlockchainLogger) LogTransaction(
 	txType blockchain.TransactionType,
 	actor, target, action string,
 	details map[string]interface{},
 	r *http.Request,
-) {
-	ipAddress := getClientIP(r)
+) blockchain.Transaction {
+	ipAddress := bl.clientIP(r)
 	tx := blockchain.NewTransaction(txType, actor, target, action, details, ipAddress)
 	bl.chain.AddTransaction(tx)
+	return tx
 }
 
-// LogVote logs a voting transaction
+// LogVote logs a voting transaction in the current election
 func (bl *BlockchainLogger) LogVote(voterID, candidateID string, r *http.Request) {
+	bl.LogBallot(election, contracts.Ballot{VoterID: voterID, Choices: []string{candidateID}}, r)
+}
+
+// LogBallot logs a ballot cast in an election. The target is the first
+// choice, the special option or "write-in" when there is one; the full
+// choices or scores and the normalised write-in are carried in the details,
+// with the election and round so a recount can tell rounds apart.
+func (bl *BlockchainLogger) LogBallot(e *contracts.Election, ballot contracts.Ballot, r *http.Request) {
+	target := "ballot"
 	details := map[string]interface{}{
-		"voterID":     voterID,
-		"candidateID": candidateID,
+		"election": e.GetNumber(),
+		"round":    e.GetRound(),
+		"voterID":  ballot.VoterID,
+	}
+	if len(ballot.Choices) > 0 {
+		target = ballot.Choices[0]
+		details["candidateID"] = target
+	}
+	if len(ballot.Choices) > 1 {
+		details["choices"] = ballot.Choices
+	}
+	if len(ballot.Scores) > 0 {
+		details["scores"] = ballot.Scores
+	}
+	if ballot.Special != "" {
+		target = string(ballot.Special)
+		details["special"] = ballot.Special
+	}
+	if ballot.WriteIn != "" {
+		target = "write-in"
+		details["writeIn"] = ballot.WriteIn
+	}
+	if ballot.Sequence > 0 {
+		details["sequence"] = ballot.Sequence
+	}
+	if ballot.Supersedes > 0 {
+		details["supersedes"] = ballot.Supersedes
 	}
-	bl.LogTransaction(blockchain.TxTypeVote, voterID, candidateID, "Cast vote", details, r)
+	if len(ballot.Answers) > 0 {
+		details["answers"] = ballot.Answers
+	}
+	if ballot.Provisional != "" {
+		details["provisional"] = ballot.Provisional
+	}
+	bl.LogTransaction(blockchain.TxTypeVote, ballot.VoterID, target, "Cast vote", details, r)
 }
 
 // LogVoterRegistration logs voter registration
@@ -61,6 +116,9 @@ func (bl *BlockchainLogger) LogCandidateAction(action, adminUser, candidateID, c
 	case "delete":
 		txType = blockchain.TxTypeDeleteCandidate
 		actionDesc = "Deleted candidate"
+	case "status":
+		txType = blockchain.TxTypeCandidateStatus
+		actionDesc = "Changed candidate status"
 	}
 
 	if details == nil {
@@ -98,6 +156,77 @@ func (bl *BlockchainLogger) LogPartyAction(action, adminUser, partyID, partyName
 	bl.LogTransaction(txType, adminUser, partyID, actionDesc, details, r)
 }
 
+// LogQuestionAction logs changes to the question contests on the ballot
+func (bl *BlockchainLogger) LogQuestionAction(action, adminUser, questionID, title string, details map[string]interface{}, r *http.Request) {
+	var txType blockchain.TransactionType
+	var actionDesc string
+
+	switch action {
+	case "add":
+		txType = blockchain.TxTypeAddQuestion
+		actionDesc = "Added ballot question"
+	case "delete":
+		txType = blockchain.TxTypeDeleteQuestion
+		actionDesc = "Removed ballot question"
+	}
+
+	if details == nil {
+		details = make(map[string]interface{})
+	}
+	details["questionID"] = questionID
+	details["title"] = title
+
+	bl.LogTransaction(txType, adminUser, questionID, actionDesc, details, r)
+}
+
+// LogNominationAction logs a step of the candidate nomination pipeline
+func (bl *BlockchainLogger) LogNominationAction(action, actor string, n contracts.Nomination, r *http.Request) {
+	var txType blockchain.TransactionType
+	var actionDesc string
+
+	switch action {
+	case "submit":
+		txType = blockchain.TxTypeSubmitNomination
+		actionDesc = "Submitted nomination"
+	case "review":
+		txType = blockchain.TxTypeReviewNomination
+		actionDesc = "Started nomination review"
+	case "approve":
+		txType = blockchain.TxTypeApproveNomination
+		actionDesc = "Approved nomination"
+	case "reject":
+		txType = blockchain.TxTypeRejectNomination
+		actionDesc = "Rejected nomination"
+	}
+
+	details := map[string]interface{}{
+		"nominationID": n.ID,
+		"candidateID":  n.CandidateID,
+		"name":         n.Name,
+		"partyID":      n.PartyID,
+		"status":       n.Status,
+	}
+	if len(n.Violations) > 0 {
+		details["violations"] = n.Violations
+	}
+	if n.Reason != "" {
+		details["reason"] = n.Reason
+	}
+
+	bl.LogTransaction(txType, actor, n.ID, actionDesc, details, r)
+}
+
+// LogNominationRules logs a change of the nomination rule set
+func (bl *BlockchainLogger) LogNominationRules(actor string, rules contracts.NominationRules, r *http.Request) {
+	details := map[string]interface{}{
+		"minAge":         rules.MinAge,
+		"maxPerParty":    rules.MaxPerParty,
+		"requiredFields": rules.RequiredFields,
+		"oneCandidacy":   rules.OneCandidacy,
+	}
+	bl.LogTransaction(blockchain.TxTypeNominationPolicy, actor, rules.Source, "Loaded nomination rules", details, r)
+}
+
 // LogElectionAction logs election management actions
 func (bl *BlockchainLogger) LogElectionAction(action, adminUser, description string, details map[string]interface{}, r *http.Request) {
 	var txType blockchain.TransactionType
@@ -110,6 +239,15 @@ func (bl *BlockchainLogger) LogElectionAction(action, adminUser, description str
 	case "stop":
 		txType = blockchain.TxTypeStopElection
 		actionDesc = "Stopped election"
+	case "pause":
+		txType = blockchain.TxTypePauseElection
+		actionDesc = "Paused voting"
+	case "resume":
+		txType = blockchain.TxTypeResumeElection
+		actionDesc = "Resumed voting"
+	case "extend":
+		txType = blockchain.TxTypeExtendElection
+		actionDesc = "Extended voting"
 	}
 
 	if details == nil {
@@ -120,6 +258,210 @@ func (bl *BlockchainLogger) LogElectionAction(action, adminUser, description str
 	bl.LogTransaction(txType, adminUser, "election", actionDesc, details, r)
 }
 
+// LogElectionTransition logs a change of election lifecycle state. Scheduled
+// transitions have no request and are logged with the scheduler as actor.
+func (bl *BlockchainLogger) LogElectionTransition(actor string, t contracts.StateTransition, r *http.Request) blockchain.Transaction {
+	details := map[string]interface{}{
+		"from":   t.From,
+		"to":     t.To,
+		"at":     t.At,
+		"reason": t.Reason,
+	}
+	return bl.LogTransaction(blockchain.TxTypeElectionState, actor, string(t.To), "Election state changed", details, r)
+}
+
+// LogCertification anchors a signed final result document on the chain. The
+// returned transaction ID is what the document is then published under.
+func (bl *BlockchainLogger) LogCertification(actor string, cert contracts.CertifiedResult, r *http.Request) blockchain.Transaction {
+	doc := cert.Document
+	details := map[string]interface{}{
+		"documentHash":  cert.DocumentHash,
+		"signature":     cert.Signature,
+		"publicKey":     cert.PublicKey,
+		"chainHeight":   doc.ChainHeight,
+		"chainHeadHash": doc.ChainHeadHash,
+		"ballotsCast":   doc.BallotsCast,
+		"description":   doc.Description,
+	}
+	return bl.LogTransaction(blockchain.TxTypeCertifyResults, actor, cert.DocumentHash, "Certified final results", details, r)
+}
+
+// LogTiePolicy logs the tie policy and, for lots, the commitment to the seed
+// the lots will be drawn from
+func (bl *BlockchainLogger) LogTiePolicy(actor string, policy contracts.TiePolicy, commitment string, r *http.Request) {
+	details := map[string]interface{}{
+		"policy": policy,
+	}
+	if commitment != "" {
+		details["seedCommitment"] = commitment
+	}
+	bl.LogTransaction(blockchain.TxTypeTiePolicy, actor, string(policy), "Set tie policy", details, r)
+}
+
+// LogTieResolution records how a contest's tie was resolved. The returned
+// transaction ID is stored with the resolution.
+func (bl *BlockchainLogger) LogTieResolution(actor string, res contracts.TieResolution, r *http.Request) blockchain.Transaction {
+	details := map[string]interface{}{
+		"policy":    res.Policy,
+		"tied":      res.Tied,
+		"seatsOpen": res.SeatsOpen,
+		"winners":   res.Winners,
+	}
+	if res.Round > 0 {
+		details["round"] = res.Round
+	}
+	if res.Runoff {
+		details["runoff"] = true
+	}
+	if res.Seed != "" {
+		details["seed"] = res.Seed
+		details["seedCommitment"] = res.Commitment
+		details["drawOrder"] = res.DrawOrder
+	}
+	if res.Reason != "" {
+		details["reason"] = res.Reason
+	}
+	return bl.LogTransaction(blockchain.TxTypeTieResolution, actor, res.ContestID, "Resolved tie", details, r)
+}
+
+// LogRunoffTrigger records the certified result that calls for a runoff
+func (bl *BlockchainLogger) LogRunoffTrigger(actor string, trigger contracts.RunoffTrigger, r *http.Request) blockchain.Transaction {
+	details := map[string]interface{}{
+		"reason":       trigger.Reason,
+		"candidates":   trigger.Candidates,
+		"seats":        trigger.Seats,
+		"documentHash": trigger.DocumentHash,
+	}
+	if trigger.Threshold > 0 {
+		details["threshold"] = trigger.Threshold
+		details["leaderShare"] = trigger.LeaderShare
+	}
+	return bl.LogTransaction(blockchain.TxTypeRunoffTriggered, actor, strconv.Itoa(trigger.Round), "Runoff required", details, r)
+}
+
+// LogRunoffCreated records the link between a runoff round and the round
+// that triggered it
+func (bl *BlockchainLogger) LogRunoffCreated(actor string, next *contracts.Election, triggerTxID string, r *http.Request) blockchain.Transaction {
+	link := next.PreviousRound
+	candidates := make([]string, 0, len(next.Candidates))
+	for id := range next.Candidates {
+		candidates = append(candidates, id)
+	}
+	sort.Strings(candidates)
+	details := map[string]interface{}{
+		"previousRound":        link.Round,
+		"previousFile":         link.File,
+		"previousDocumentHash": link.DocumentHash,
+		"triggerTransactionId": triggerTxID,
+		"candidates":           candidates,
+		"ballot":               next.GetBallotConfig(),
+	}
+	return bl.LogTransaction(blockchain.TxTypeRunoffCreated, actor, strconv.Itoa(next.GetRound()), "Created runoff round", details, r)
+}
+
+// LogWriteInAdjudication records an officer's decision on a write-in
+// spelling. The returned transaction ID is stored with the decision.
+func (bl *BlockchainLogger) LogWriteInAdjudication(actor string, d contracts.WriteInAdjudication, ballots int, r *http.Request) blockchain.Transaction {
+	details := map[string]interface{}{
+		"status":  d.Status,
+		"ballots": ballots,
+	}
+	if d.CandidateID != "" {
+		details["candidateId"] = d.CandidateID
+	}
+	if d.Reason != "" {
+		details["reason"] = d.Reason
+	}
+	return bl.LogTransaction(blockchain.TxTypeWriteInAdjudication, actor, d.Text, "Adjudicated write-in", details, r)
+}
+
+// LogProvisionalBallot logs a sealed provisional ballot. Only the hash of the
+// sealed ballot is recorded; its choices reach the chain as a VOTE if it is
+// accepted.
+func (bl *BlockchainLogger) LogProvisionalBallot(p contracts.ProvisionalBallot, r *http.Request) {
+	details := map[string]interface{}{
+		"voterID":   p.VoterID,
+		"challenge": p.Challenge,
+		"sealHash":  p.SealHash,
+	}
+	bl.LogTransaction(blockchain.TxTypeProvisionalBallot, p.VoterID, p.ID, "Cast provisional ballot", details, r)
+}
+
+// LogProvisionalDecision logs an officer accepting or rejecting a
+// provisional ballot
+func (bl *BlockchainLogger) LogProvisionalDecision(actor string, p contracts.ProvisionalBallot, r *http.Request) {
+	details := map[string]interface{}{
+		"voterID":  p.VoterID,
+		"status":   p.Status,
+		"sealHash": p.SealHash,
+	}
+	if p.Reason != "" {
+		details["reason"] = p.Reason
+	}
+	if p.Sequence > 0 {
+		details["sequence"] = p.Sequence
+	}
+	bl.LogTransaction(blockchain.TxTypeProvisionalDecision, actor, p.ID, "Decided provisional ballot", details, r)
+}
+
+// LogProposal logs a proposed critical action being proposed, approved,
+// carried out or withdrawn. The request is identified by the SHA-256 of its
+// body, so the logged proposal can be matched to what was executed.
+func (bl *BlockchainLogger) LogProposal(event, actor string, p contracts.Proposal, r *http.Request) blockchain.Transaction {
+	var txType blockchain.TransactionType
+	actionDesc := p.Action
+
+	sum := sha256.Sum256([]byte(p.Body))
+	details := map[string]interface{}{
+		"action":     p.Action,
+		"request":    p.Method + " " + p.Path,
+		"bodyHash":   hex.EncodeToString(sum[:]),
+		"proposedBy": p.ProposedBy,
+		"required":   p.Required,
+		"approvals":  len(p.Approvals),
+		"status":     p.Status,
+	}
+
+	switch event {
+	case "propose":
+		txType = blockchain.TxTypeProposalCreated
+		details["expiresAt"] = p.ExpiresAt
+		if p.Reason != "" {
+			details["reason"] = p.Reason
+		}
+	case "approve":
+		txType = blockchain.TxTypeProposalApproved
+		actionDesc = "Approved " + p.Action
+	case "execute":
+		txType = blockchain.TxTypeProposalExecuted
+		actionDesc = "Executed " + p.Action
+		if p.Status == contracts.ProposalFailed {
+			actionDesc = "Failed " + p.Action
+		}
+		approvers := make([]string, 0, len(p.Approvals))
+		for _, a := range p.Approvals {
+			approvers = append(approvers, a.Admin)
+		}
+		details["approvedBy"] = approvers
+		details["resultStatus"] = p.ResultStatus
+	case "cancel":
+		txType = blockchain.TxTypeProposalCancelled
+		actionDesc = "Withdrew " + p.Action
+	}
+
+	return bl.LogTransaction(txType, actor, p.ID, actionDesc, details, r)
+}
+
+// LogProposalPolicy logs a change to the quorum critical actions need
+func (bl *BlockchainLogger) LogProposalPolicy(actor string, policy contracts.ProposalPolicy, r *http.Request) {
+	details := map[string]interface{}{
+		"required":    policy.Required,
+		"windowHours": policy.WindowHours,
+	}
+	target := fmt.Sprintf("%d approval(s) within %dh", policy.Required, policy.WindowHours)
+	bl.LogTransaction(blockchain.TxTypeProposalPolicy, actor, target, "Set proposal policy", details, r)
+}
+
 // LogUserAction logs user management actions
 func (bl *BlockchainLogger) LogUserAction(action, adminUser, userID string, details map[string]interface{}, r *http.Request) {
 	var txType blockchain.TransactionType
@@ -143,8 +485,78 @@ func (bl *BlockchainLogger) LogUserAction(action, adminUser, userID string, deta
 	bl.LogTransaction(txType, adminUser, userID, actionDesc, details, r)
 }
 
-// LogLogin logs login attempts
-func (bl *BlockchainLogger) LogLogin(userType, username string, success bool, r *http.Request) {
+// LogAdminAccountAction logs changes to admin accounts
+func (bl *BlockchainLogger) LogAdminAccountAction(action, actor, username string, details map[string]interface{}, r *http.Request) {
+	var txType blockchain.TransactionType
+	var actionDesc string
+
+	switch action {
+	case "add":
+		txType = blockchain.TxTypeAddAdmin
+		actionDesc = "Added admin"
+	case "update":
+		txType = blockchain.TxTypeUpdateAdmin
+		actionDesc = "Updated admin"
+	case "delete":
+		txType = blockchain.TxTypeDeleteAdmin
+		actionDesc = "Deleted admin"
+	}
+
+	bl.LogTransaction(txType, actor, username, actionDesc, details, r)
+}
+
+// LogTwoFactor logs an account enrolling in two-factor authentication,
+// having it reset, or logging in with a recovery code. The account is an
+// admin's username or a voter's ID.
+func (bl *BlockchainLogger) LogTwoFactor(event, actor, account string, details map[string]interface{}, r *http.Request) {
+	var txType blockchain.TransactionType
+	var actionDesc string
+
+	switch event {
+	case "enrol":
+		txType = blockchain.TxTypeTwoFactorEnrolled
+		actionDesc = "Enabled two-factor authentication"
+	case "reset":
+		txType = blockchain.TxTypeTwoFactorReset
+		actionDesc = "Reset two-factor authentication"
+	case "recovery":
+		txType = blockchain.TxTypeRecoveryCodeUsed
+		actionDesc = "Logged in with a recovery code"
+	}
+
+	bl.LogTransaction(txType, actor, account, actionDesc, details, r)
+}
+
+// LogEmailVerified logs a voter confirming their email address
+func (bl *BlockchainLogger) LogEmailVerified(voterID string, r *http.Request) {
+	bl.LogTransaction(blockchain.TxTypeEmailVerified, voterID, voterID, "Confirmed email address", map[string]interface{}{}, r)
+}
+
+// LogPasswordEvent logs a voter asking for a password reset, resetting their
+// password or changing it. Neither the password nor the reset token is
+// recorded.
+func (bl *BlockchainLogger) LogPasswordEvent(event, voterID string, r *http.Request) {
+	var txType blockchain.TransactionType
+	var actionDesc string
+
+	switch event {
+	case "reset_requested":
+		txType = blockchain.TxTypePasswordResetRequested
+		actionDesc = "Requested a password reset"
+	case "reset":
+		txType = blockchain.TxTypePasswordReset
+		actionDesc = "Reset password"
+	case "change":
+		txType = blockchain.TxTypePasswordChanged
+		actionDesc = "Changed password"
+	}
+
+	bl.LogTransaction(txType, voterID, voterID, actionDesc, map[string]interface{}{}, r)
+}
+
+// LogLogin logs login attempts. The reason says why an attempt failed or was
+// refused, such as "invalid credentials" or "throttled".
+func (bl *BlockchainLogger) LogLogin(userType, username string, success bool, reason string, r *http.Request) {
 	var txType blockchain.TransactionType
 	var actionDesc string
 
@@ -168,31 +580,82 @@ func (bl *BlockchainLogger) LogLogin(userType, username string, success bool, r
 		"success":  success,
 		"userType": userType,
 	}
+	if reason != "" {
+		details["reason"] = reason
+	}
 
 	bl.LogTransaction(txType, username, "system", actionDesc, details, r)
 }
 
-// getClientIP extracts the client IP address from the request
-func getClientIP(r *http.Request) string {
-	// Check X-Forwarded-For header first
-	forwarded := r.Header.Get("X-Forwarded-For")
-	if forwarded != "" {
-		// X-Forwarded-For can contain multiple IPs, take the first one
-		ips := strings.Split(forwarded, ",")
-		return strings.TrimSpace(ips[0])
+// LogClearLockout logs an admin lifting the login delay or lockout of an
+// account or IP
+func (bl *BlockchainLogger) LogClearLockout(actor, kind, id string, failures int, r *http.Request) {
+	details := map[string]interface{}{
+		"kind":     kind,
+		"failures": failures,
 	}
+	bl.LogTransaction(blockchain.TxTypeClearLockout, actor, id, "Cleared login lockout", details, r)
+}
 
-	// Check X-Real-IP header
-	realIP := r.Header.Get("X-Real-IP")
-	if realIP != "" {
-		return realIP
+// clientIP extracts the client IP address from the request. It is the
+// address the connection came from, unless that is a trusted proxy: then it
+// is the nearest address in X-Forwarded-For that is not a trusted proxy
+// itself, or X-Real-IP. Anyone else can set those headers to anything.
+func (bl *BlockchainLogger) clientIP(r *http.Request) string {
+	// Background jobs log without a request
+	if r == nil {
+		return ""
 	}
 
-	// Fall back to RemoteAddr
-	ip := r.RemoteAddr
-	if strings.Contains(ip, ":") {
-		ip = strings.Split(ip, ":")[0]
+	ip := remoteIP(r)
+	if !bl.trustedProxy(ip) {
+		return ip
 	}
 
+	// Each proxy appends the address it was reached from, so the list is
+	// read from the end and everything before the first untrusted hop is
+	// whatever the client chose to send
+	if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+		hops := strings.Split(strings.Join(forwarded, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if hop == "" {
+				continue
+			}
+			ip = hop
+			if !bl.trustedProxy(hop) {
+				break
+			}
+		}
+		return ip
+	}
+
+	if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); realIP != "" {
+		return realIP
+	}
 	return ip
 }
+
+// remoteIP is the address the request's connection came from
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// trustedProxy reports whether an address is one of the trusted proxies
+func (bl *BlockchainLogger) trustedProxy(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, p := range bl.trustedProxies {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,206 @@
+package server
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"e-voting-blockchain/contracts"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// resultsKeyFile holds the hex seed of the key that signs certified results.
+// It is kept across system resets so earlier documents stay verifiable.
+const resultsKeyFile = "results_signing.key"
+
+// resultsKey is loaded on first use; callers hold electionMu
+var resultsKey ed25519.PrivateKey
+
+// loadResultsKey reads the results signing key, creating one on first use
+func loadResultsKey() (ed25519.PrivateKey, error) {
+	if resultsKey != nil {
+		return resultsKey, nil
+	}
+
+	seed, err := loadSecret(storage.Path(resultsKeyFile), ed25519.SeedSize, "results signing key")
+	if err != nil {
+		return nil, err
+	}
+	resultsKey = ed25519.NewKeyFromSeed(seed)
+	return resultsKey, nil
+}
+
+// loadSecret reads a hex-encoded secret of the given size from a file,
+// generating a random one and writing it with owner-only permissions if the
+// file does not exist
+func loadSecret(file string, size int, name string) ([]byte, error) {
+	data, err := os.ReadFile(file)
+	switch {
+	case err == nil:
+		secret, err := hex.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil || len(secret) != size {
+			return nil, fmt.Errorf("invalid %s in %s", name, file)
+		}
+		return secret, nil
+	case os.IsNotExist(err):
+		secret := make([]byte, size)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(file, []byte(hex.EncodeToString(secret)), 0600); err != nil {
+			return nil, err
+		}
+		log.Printf("Created %s in %s", name, file)
+		return secret, nil
+	default:
+		return nil, err
+	}
+}
+
+// certifyResults tallies a closed election and publishes its signed final
+// result document: the document's hash is signed, anchored on the chain as a
+// CERTIFY_RESULTS transaction and the election moves to certified. Callers
+// hold electionMu and save the election afterwards.
+func certifyResults(actor string, r *http.Request) (*contracts.CertifiedResult, error) {
+	key, err := loadResultsKey()
+	if err != nil {
+		return nil, err
+	}
+
+	// Ties the policy settles are resolved first; a tie awaiting a manual
+	// decision, an unadjudicated write-in or a provisional ballot still under
+	// review holds back certification
+	switch election.State() {
+	case contracts.StateClosed, contracts.StateTallied:
+		if n := election.PendingWriteIns(); n > 0 {
+			return nil, fmt.Errorf("%d write-in spelling(s) awaiting adjudication", n)
+		}
+		if n := election.ProvisionalCounts().Pending; n > 0 {
+			return nil, fmt.Errorf("%d provisional ballot(s) awaiting review", n)
+		}
+		if err := resolvePendingTies(actor, r); err != nil {
+			return nil, err
+		}
+	}
+
+	switch election.State() {
+	case contracts.StateClosed:
+		t, err := election.Transition(contracts.StateTallied, "final count completed")
+		if err != nil {
+			return nil, err
+		}
+		blockchainLogger.LogElectionTransition(actor, t, r)
+	case contracts.StateTallied:
+	default:
+		return nil, errors.New("results can only be certified once polls have closed")
+	}
+
+	now := time.Now()
+	head := chain.LatestBlock()
+	doc, err := election.BuildResultDocument(head.Index+1, head.Hash, now)
+	if err != nil {
+		return nil, err
+	}
+	hash, err := doc.Hash()
+	if err != nil {
+		return nil, err
+	}
+
+	cert := contracts.CertifiedResult{
+		Document:     doc,
+		DocumentHash: hash,
+		Signature:    base64.StdEncoding.EncodeToString(ed25519.Sign(key, []byte(hash))),
+		PublicKey:    base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey)),
+		CertifiedAt:  now,
+	}
+	tx := blockchainLogger.LogCertification(actor, cert, r)
+	cert.TransactionID = tx.ID
+
+	t, err := election.RecordCertification(cert)
+	if err != nil {
+		return nil, err
+	}
+	blockchainLogger.LogElectionTransition(actor, t, r)
+
+	log.Printf("Final results certified: hash=%s tx=%s", hash, tx.ID)
+	return &cert, nil
+}
+
+// HandleCertifyResults certifies the results of a closed election. Closing
+// the election certifies automatically; this covers elections closed before
+// certification existed or where automatic certification failed.
+func HandleCertifyResults(w http.ResponseWriter, r *http.Request) {
+	log.Println("HandleCertifyResults called")
+	w.Header().Set("Content-Type", "application/json")
+
+	electionMu.Lock()
+	defer electionMu.Unlock()
+
+	cert, err := certifyResults(actor(r), r)
+	if err != nil {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	response := map[string]interface{}{
+		"status":        "results certified",
+		"certification": cert,
+	}
+	if trigger, err := generateRunoff(actor(r), r); err != nil {
+		log.Printf("Failed to create runoff: %v", err)
+		response["runoffError"] = err.Error()
+	} else if trigger != nil {
+		response["runoff"] = trigger
+	}
+
+	if saveErr := election.SaveElection(); saveErr != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to save election data"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// HandleCertifiedResults serves the certified final result document
+func HandleCertifiedResults(w http.ResponseWriter, r *http.Request) {
+	log.Println("HandleCertifiedResults called")
+	w.Header().Set("Content-Type", "application/json")
+
+	electionMu.RLock()
+	defer electionMu.RUnlock()
+
+	if election == nil || election.Certification == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Results have not been certified"})
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(election.Certification); err != nil {
+		log.Printf("Failed to encode certified results: %v", err)
+	}
+}
+
+// writeResultsEmbargoed reports that counts are not yet public, with turnout
+// when the visibility rule allows it
+func writeResultsEmbargoed(w http.ResponseWriter) {
+	response := map[string]interface{}{
+		"error":      "Results are embargoed until polls close",
+		"code":       "results_embargoed",
+		"visibility": election.GetResultVisibility(),
+		"state":      election.State(),
+	}
+	if election.TurnoutVisible() {
+		response["ballotsCast"] = len(election.Voters)
+	}
+	w.WriteHeader(http.StatusForbidden)
+	json.NewEncoder(w).Encode(response)
+}
@@ -4,19 +4,24 @@ import (
 	"net/http"
 )
 
-func CorsMiddleware(next http.Handler) http.Handler {
+// CorsMiddleware lets browsers call the API from the configured origins; an
+// origin of "*" allows any
+func CorsMiddleware(origins []string, next http.Handler) http.Handler {
+	allowed := make(map[string]bool, len(origins))
+	for _, origin := range origins {
+		allowed[origin] = true
+	}
+
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Allow frontend from localhost:5173 and any localhost
 		origin := r.Header.Get("Origin")
-		if origin == "http://localhost:5173" || origin == "http://localhost:3000" {
+		if origin != "" && (allowed[origin] || allowed["*"]) {
 			w.Header().Set("Access-Control-Allow-Origin", origin)
-		} else {
-			w.Header().Set("Access-Control-Allow-Origin", "*")
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
 		}
+		w.Header().Add("Vary", "Origin")
 
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Credentials", "true")
 
 		// Handle preflight requests
 		if r.Method == http.MethodOptions {
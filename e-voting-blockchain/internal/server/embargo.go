@@ -0,0 +1,131 @@
+package server
+
+import (
+	"e-voting-blockchain/blockchain"
+	"time"
+)
+
+// Every ballot is written to the chain as it is cast, with its choices in
+// the VOTE transaction's details. While the election's results are
+// embargoed, the chain read paths and the WebSocket feed hold back what its
+// visibility rule does not allow: the contents of the current election's
+// ballots while counts are hidden, and the ballots themselves while turnout
+// is hidden too. Blocks are then published up to the first one holding a
+// ballot, since the index of every later block would give the count away.
+// Everything is published once the polls close.
+
+// voteEmbargo is what the visibility rule in force holds back
+type voteEmbargo struct {
+	since   time.Time // Ballots cast from then on are the current election's
+	counts  bool      // Ballot contents are withheld
+	turnout bool      // Ballots are withheld altogether
+}
+
+// ballotDetails are the details of a VOTE transaction that do not reveal
+// its choices
+var ballotDetails = []string{"election", "round", "voterID", "sequence", "supersedes", "provisional"}
+
+// currentEmbargo returns what the current election's visibility rule holds
+// back of the chain now
+func currentEmbargo() voteEmbargo {
+	electionMu.RLock()
+	defer electionMu.RUnlock()
+
+	if election == nil || election.Status.StartTime.IsZero() {
+		return voteEmbargo{}
+	}
+	return voteEmbargo{
+		since:   election.Status.StartTime,
+		counts:  !election.CountsVisible(),
+		turnout: !election.TurnoutVisible(),
+	}
+}
+
+// covers reports whether a transaction is a ballot of the current election
+// that the embargo applies to
+func (e voteEmbargo) covers(tx blockchain.Transaction) bool {
+	return (e.counts || e.turnout) && tx.Data.Type == blockchain.TxTypeVote && !tx.Data.Timestamp.Before(e.since)
+}
+
+// transaction returns a transaction as it may be published, and false when
+// it may not be published at all
+func (e voteEmbargo) transaction(tx blockchain.Transaction) (blockchain.Transaction, bool) {
+	if !e.covers(tx) {
+		return tx, true
+	}
+	if e.turnout {
+		return blockchain.Transaction{}, false
+	}
+
+	details := map[string]interface{}{"withheld": "ballot contents are published when the polls close"}
+	for _, key := range ballotDetails {
+		if v, ok := tx.Data.Details[key]; ok {
+			details[key] = v
+		}
+	}
+	tx.Data.Target = "ballot"
+	tx.Data.Details = details
+	return tx, true
+}
+
+// transactions returns the transactions that may be published, as they may
+// be published
+func (e voteEmbargo) transactions(txs []blockchain.Transaction) []blockchain.Transaction {
+	visible := make([]blockchain.Transaction, 0, len(txs))
+	for _, tx := range txs {
+		if tx, ok := e.transaction(tx); ok {
+			visible = append(visible, tx)
+		}
+	}
+	return visible
+}
+
+// blocks returns the blocks that may be published, as they may be published
+func (e voteEmbargo) blocks(blocks []blockchain.Block) []blockchain.Block {
+	visible := make([]blockchain.Block, 0, len(blocks))
+	for _, block := range blocks {
+		withheld := false
+		for _, tx := range block.Transactions {
+			withheld = withheld || e.covers(tx)
+		}
+		if !withheld {
+			visible = append(visible, block)
+			continue
+		}
+		if e.turnout {
+			break
+		}
+		block.Transactions = e.transactions(block.Transactions)
+		visible = append(visible, block)
+	}
+	return visible
+}
+
+// withheldVotes counts the ballots withheld altogether
+func (e voteEmbargo) withheldVotes(txs []blockchain.Transaction) int {
+	n := 0
+	for _, tx := range txs {
+		if e.turnout && e.covers(tx) {
+			n++
+		}
+	}
+	return n
+}
+
+// integrityReport limits an integrity report to the first published blocks.
+// Whether the whole chain is valid is still reported.
+func (e voteEmbargo) integrityReport(report blockchain.IntegrityReport, published int) blockchain.IntegrityReport {
+	if published >= report.TotalBlocks {
+		return report
+	}
+	invalid := []blockchain.InvalidBlockInfo{}
+	for _, info := range report.InvalidBlocks {
+		if info.Index < published {
+			invalid = append(invalid, info)
+		}
+	}
+	report.TotalBlocks = published
+	report.ValidBlocks = published - len(invalid)
+	report.InvalidBlocks = invalid
+	return report
+}
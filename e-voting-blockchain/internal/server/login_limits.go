@@ -0,0 +1,293 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Failed logins are counted per account and per client IP. Past a few free
+// failures each further one makes the key wait twice as long before the
+// next attempt, and enough failures lock it out for a while. An attempt is
+// counted before its password is checked and taken back if it was not a
+// failure. Failures are forgotten after loginFailureMemory without another,
+// and a successful login clears the account's count.
+
+// loginPolicy is how failed logins are throttled for one kind of key
+type loginPolicy struct {
+	freeFailures int           // Failures allowed before any delay
+	baseDelay    time.Duration // Delay after the first failure past the free ones
+	maxDelay     time.Duration
+	lockAfter    int // Failures that lock the key out
+	lockFor      time.Duration
+}
+
+var (
+	// An account is guessed at from many addresses, an address may guess at
+	// many accounts, and many voters can share an address behind NAT
+	accountLoginPolicy = loginPolicy{freeFailures: 3, baseDelay: time.Second, maxDelay: 5 * time.Minute, lockAfter: 10, lockFor: 30 * time.Minute}
+	ipLoginPolicy      = loginPolicy{freeFailures: 20, baseDelay: time.Second, maxDelay: 5 * time.Minute, lockAfter: 100, lockFor: time.Hour}
+
+	loginFailureMemory = 24 * time.Hour
+)
+
+// loginAttempts tracks the failed logins of an account or an IP
+type loginAttempts struct {
+	Kind         string    `json:"kind"` // admin, voter or ip
+	ID           string    `json:"id"`   // Username or IP
+	Failures     int       `json:"failures"`
+	LastFailure  time.Time `json:"lastFailure"`
+	BlockedUntil time.Time `json:"blockedUntil"`
+	Locked       bool      `json:"locked"` // Whether the block is a lockout rather than a backoff delay
+}
+
+var (
+	loginMu       sync.Mutex
+	loginFailures = make(map[string]*loginAttempts)
+)
+
+func loginKey(kind, id string) string {
+	return kind + ":" + id
+}
+
+// loginKeys are the keys a login attempt counts against. The IP is the
+// connection's own address; forwarding headers only count when the
+// connection comes from a trusted proxy, so a client cannot spread its
+// attempts over made-up addresses.
+func loginKeys(userType, username string, r *http.Request) [][2]string {
+	return [][2]string{{userType, username}, {"ip", blockchainLogger.clientIP(r)}}
+}
+
+func loginPolicyFor(kind string) loginPolicy {
+	if kind == "ip" {
+		return ipLoginPolicy
+	}
+	return accountLoginPolicy
+}
+
+// pendingLogin is a login attempt throttleLogin let through. It is counted
+// as a failure before the password is checked, so a burst of parallel
+// attempts cannot all be checked before the backoff applies. The handler
+// settles it with failed or succeeded; released unsettled, for an attempt
+// that was neither, its failure is taken back.
+type pendingLogin struct {
+	userType string
+	username string
+	r        *http.Request
+	settled  bool
+}
+
+// reserveLogin counts an attempt against the account and the IP unless
+// either must wait, returning how long
+func reserveLogin(userType, username string, r *http.Request, now time.Time) time.Duration {
+	loginMu.Lock()
+	defer loginMu.Unlock()
+
+	var wait time.Duration
+	for _, k := range loginKeys(userType, username, r) {
+		if a, ok := loginFailures[loginKey(k[0], k[1])]; ok && a.BlockedUntil.After(now) {
+			if d := a.BlockedUntil.Sub(now); d > wait {
+				wait = d
+			}
+		}
+	}
+	if wait > 0 {
+		return wait
+	}
+
+	for key, a := range loginFailures {
+		if now.Sub(a.LastFailure) > loginFailureMemory && !a.BlockedUntil.After(now) {
+			delete(loginFailures, key)
+		}
+	}
+	for _, k := range loginKeys(userType, username, r) {
+		key := loginKey(k[0], k[1])
+		a, ok := loginFailures[key]
+		if !ok {
+			a = &loginAttempts{Kind: k[0], ID: k[1]}
+			loginFailures[key] = a
+		}
+		a.Failures++
+		a.LastFailure = now
+		if a.block() {
+			log.Printf("Locking out logins for %s after %d failures", key, a.Failures)
+		}
+	}
+	return 0
+}
+
+// block sets how long the key must wait after its latest failure,
+// reporting whether it has just been locked out
+func (a *loginAttempts) block() bool {
+	policy := loginPolicyFor(a.Kind)
+	switch {
+	case a.Failures >= policy.lockAfter:
+		a.BlockedUntil = a.LastFailure.Add(policy.lockFor)
+		wasLocked := a.Locked
+		a.Locked = true
+		return !wasLocked
+	case a.Failures > policy.freeFailures:
+		delay := time.Duration(float64(policy.baseDelay) * math.Pow(2, float64(a.Failures-policy.freeFailures-1)))
+		if delay > policy.maxDelay || delay <= 0 {
+			delay = policy.maxDelay
+		}
+		a.BlockedUntil = a.LastFailure.Add(delay)
+	default:
+		a.BlockedUntil = time.Time{}
+	}
+	a.Locked = false
+	return false
+}
+
+// takeBack removes the failure counted for the attempt from the given keys
+func (l *pendingLogin) takeBack(keys [][2]string) {
+	loginMu.Lock()
+	defer loginMu.Unlock()
+
+	for _, k := range keys {
+		key := loginKey(k[0], k[1])
+		a, ok := loginFailures[key]
+		if !ok {
+			continue
+		}
+		a.Failures--
+		if a.Failures <= 0 {
+			delete(loginFailures, key)
+			continue
+		}
+		a.block()
+	}
+}
+
+// failed leaves the attempt counted as a failure and logs it
+func (l *pendingLogin) failed(reason string) {
+	l.settled = true
+
+	// Log to blockchain
+	blockchainLogger.LogLogin(l.userType, l.username, false, reason, l.r)
+}
+
+// succeeded clears the account's failures and takes back the attempt's
+// failure from the IP, whose other failures stay since they may be against
+// other accounts, and logs the login
+func (l *pendingLogin) succeeded() {
+	l.settled = true
+	recordLoginSuccess(l.userType, l.username)
+	l.takeBack([][2]string{{"ip", blockchainLogger.clientIP(l.r)}})
+
+	// Log to blockchain
+	blockchainLogger.LogLogin(l.userType, l.username, true, "", l.r)
+}
+
+// release takes back the failure of an attempt that was not settled, such
+// as one turned away for a missing second factor or a server error
+func (l *pendingLogin) release() {
+	if l.settled {
+		return
+	}
+	l.settled = true
+	l.takeBack(loginKeys(l.userType, l.username, l.r))
+}
+
+// recordLoginSuccess clears the failures of the account that logged in
+func recordLoginSuccess(userType, username string) {
+	loginMu.Lock()
+	defer loginMu.Unlock()
+	delete(loginFailures, loginKey(userType, username))
+}
+
+// secondFactorFailed settles a login whose password was right but whose
+// second factor was missing or wrong. Only a wrong code counts as a
+// failure; a missing one is the first step of a two-step login.
+func (l *pendingLogin) secondFactorFailed(err error) {
+	switch {
+	case errors.Is(err, errInvalidTwoFactor):
+		l.failed("invalid two-factor code")
+	case errors.Is(err, errTwoFactorRequired):
+		blockchainLogger.LogLogin(l.userType, l.username, false, "two-factor code required", l.r)
+	default:
+		blockchainLogger.LogLogin(l.userType, l.username, false, "error", l.r)
+	}
+}
+
+// throttleLogin answers a login attempt that must wait with 429 and logs it,
+// returning nil; otherwise it returns the attempt, counted as pending, for
+// the handler to settle and release. errorKey is the field the handler
+// reports errors in.
+func throttleLogin(w http.ResponseWriter, r *http.Request, userType, username, errorKey string) *pendingLogin {
+	wait := reserveLogin(userType, username, r, time.Now())
+	if wait <= 0 {
+		return &pendingLogin{userType: userType, username: username, r: r}
+	}
+
+	// Log to blockchain
+	blockchainLogger.LogLogin(userType, username, false, "throttled", r)
+
+	seconds := int(math.Ceil(wait.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		errorKey:     "Too many failed login attempts; try again later",
+		"retryAfter": seconds,
+	})
+	return nil
+}
+
+// HandleListLockouts returns the accounts and IPs whose logins are currently
+// delayed or locked out
+func HandleListLockouts(w http.ResponseWriter, r *http.Request) {
+	log.Println("HandleListLockouts called")
+	w.Header().Set("Content-Type", "application/json")
+
+	loginMu.Lock()
+	defer loginMu.Unlock()
+
+	now := time.Now()
+	list := make([]loginAttempts, 0)
+	for _, a := range loginFailures {
+		if a.BlockedUntil.After(now) {
+			list = append(list, *a)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].BlockedUntil.After(list[j].BlockedUntil) })
+	json.NewEncoder(w).Encode(list)
+}
+
+// HandleClearLockout forgets the failed logins of an account or IP, lifting
+// any delay or lockout
+func HandleClearLockout(w http.ResponseWriter, r *http.Request) {
+	log.Println("HandleClearLockout called")
+	w.Header().Set("Content-Type", "application/json")
+
+	vars := mux.Vars(r)
+	kind, id := vars["kind"], vars["id"]
+	if kind != "admin" && kind != "voter" && kind != "ip" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Kind must be admin, voter or ip"})
+		return
+	}
+
+	loginMu.Lock()
+	a, exists := loginFailures[loginKey(kind, id)]
+	delete(loginFailures, loginKey(kind, id))
+	loginMu.Unlock()
+	if !exists {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": fmt.Sprintf("No failed logins recorded for %s %s", kind, id)})
+		return
+	}
+
+	// Log to blockchain
+	blockchainLogger.LogClearLockout(actor(r), kind, id, a.Failures, r)
+
+	json.NewEncoder(w).Encode(map[string]string{"status": "lockout cleared", "kind": kind, "id": id})
+}
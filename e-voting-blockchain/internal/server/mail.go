@@ -0,0 +1,184 @@
+package server
+
+import (
+	"e-voting-blockchain/contracts"
+	"e-voting-blockchain/internal/config"
+	"e-voting-blockchain/internal/mail"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Emails to voters are sent through the configured mailer: smtp, file,
+// writing each message into a directory, or memory. Links in emails point at
+// the server's public URL, except password reset links, which point at the
+// page of the voter site that takes the token.
+const (
+	verificationValid = 48 * time.Hour
+
+	// verificationResendWait is how soon after one verification email
+	// another may be sent
+	verificationResendWait = time.Minute
+)
+
+// voterMailer sends emails to voters, with links to the public site
+type voterMailer struct {
+	mailer    mail.Mailer
+	templates *mail.Templates
+
+	publicBaseURL    string
+	passwordResetURL string
+}
+
+// mailer sends the emails to voters
+var mailer *voterMailer
+
+// newVoterMailer sets up the mailer and templates as configured, with links
+// to the server's public URL
+func newVoterMailer(cfg config.MailConfig, publicURL string) (*voterMailer, error) {
+	m, err := mail.New(cfg.Transport, mail.SMTPMailer{
+		Host:     cfg.SMTPHost,
+		Port:     cfg.SMTPPort,
+		Username: cfg.SMTPUsername,
+		Password: cfg.SMTPPassword,
+		From:     cfg.From,
+	}, cfg.Dir)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Transport == "file" {
+		log.Printf("Emails are written to %s/ rather than sent", cfg.Dir)
+	}
+	return &voterMailer{
+		mailer:           m,
+		templates:        mail.NewTemplates(cfg.TemplateDir),
+		publicBaseURL:    strings.TrimRight(publicURL, "/"),
+		passwordResetURL: cfg.PasswordResetURL,
+	}, nil
+}
+
+// send renders a template in the language and sends it
+func (m *voterMailer) send(lang, template, to string, data map[string]interface{}) error {
+	msg, err := m.templates.Render(lang, template, to, data)
+	if err != nil {
+		return err
+	}
+	return m.mailer.Send(msg)
+}
+
+// publicLink is a link into the site for an email
+func (m *voterMailer) publicLink(path string, query url.Values) string {
+	return m.publicBaseURL + path + "?" + query.Encode()
+}
+
+// passwordResetLink is the link to the voter site's page for choosing a new
+// password with a reset token
+func (m *voterMailer) passwordResetLink(token string) string {
+	return m.passwordResetURL + "?" + url.Values{"token": {token}}.Encode()
+}
+
+// requestLanguage is the language a request asks emails to be written in:
+// the one given explicitly, else the browser's preferred language
+func requestLanguage(r *http.Request, explicit string) string {
+	if explicit != "" {
+		return mail.Language(explicit)
+	}
+	return mail.Language(r.Header.Get("Accept-Language"))
+}
+
+// HandleVerifyEmail confirms a voter's email address from the link sent to
+// it, making the account usable
+func HandleVerifyEmail(w http.ResponseWriter, r *http.Request) {
+	log.Println("HandleVerifyEmail called")
+	w.Header().Set("Content-Type", "application/json")
+
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Verification token is required"})
+		return
+	}
+
+	var voterID string
+	err := updateRegistration(func(u contracts.RegisteredUser) bool {
+		return u.Verification.Matches(token)
+	}, func(u *contracts.RegisteredUser) error {
+		if u.Verification.Expired(time.Now()) {
+			return errLinkExpired
+		}
+		u.Verification = nil
+		voterID = u.VoterID
+		return nil
+	})
+	switch {
+	case errors.Is(err, errLinkExpired):
+		w.WriteHeader(http.StatusGone)
+		json.NewEncoder(w).Encode(map[string]string{"error": "This link has expired; ask for a new one"})
+		return
+	case errors.Is(err, errVoterNotFound):
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid or already used verification link"})
+		return
+	case err != nil:
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to save registration"})
+		return
+	}
+
+	// Log to blockchain
+	blockchainLogger.LogEmailVerified(voterID, r)
+
+	json.NewEncoder(w).Encode(map[string]string{"status": "email verified", "message": "Your email address is confirmed; you can now log in"})
+}
+
+// HandleResendVerification sends a new verification link to a registered
+// address that has not been confirmed. The answer is the same whether or
+// not the address is registered.
+func HandleResendVerification(w http.ResponseWriter, r *http.Request) {
+	log.Println("HandleResendVerification called")
+	w.Header().Set("Content-Type", "application/json")
+
+	type Req struct {
+		Email string `json:"email"`
+	}
+
+	var req Req
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Email == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Email is required"})
+		return
+	}
+
+	now := time.Now()
+	var user contracts.RegisteredUser
+	var token string
+	err := updateRegistration(func(u contracts.RegisteredUser) bool {
+		return u.Email == req.Email && !u.EmailVerified() && now.Sub(u.Verification.SentAt) >= verificationResendWait
+	}, func(u *contracts.RegisteredUser) error {
+		var err error
+		token, u.Verification, err = contracts.NewEmailVerification(now, verificationValid)
+		user = *u
+		return err
+	})
+	switch {
+	case err == nil:
+		err = mailer.send(user.Language, "verify_email", user.Email, map[string]interface{}{
+			"Link":       mailer.publicLink("/verify-email", url.Values{"token": {token}}),
+			"ValidHours": int(verificationValid.Hours()),
+		})
+		if err != nil {
+			log.Printf("Failed to send verification email to voter %s: %v", user.VoterID, err)
+		}
+	case !errors.Is(err, errVoterNotFound):
+		log.Printf("Failed to save registration: %v", err)
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":  "ok",
+		"message": "If the address is registered and not yet confirmed, a new link has been sent to it",
+	})
+}
@@ -1,18 +1,64 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"strings"
+	"time"
 )
 
 type key string
 
 const userKey key = "user"
 
-// AuthMiddleware ensures that only requests with valid JWT proceed.
+// AuthMiddleware ensures that only requests with a valid admin JWT proceed.
+// The admin's account must still be active, and its current role is what
+// each route's permission is checked against.
 func AuthMiddleware(next http.Handler) http.Handler {
+	return requireToken(tokenAccess, func(claims *Claims) error {
+		account, err := activeAdmin(claims.Subject)
+		if err != nil || claims.Role == roleVoter {
+			return errors.New("not an active admin")
+		}
+		claims.Role = string(account.Role)
+		return nil
+	}, next)
+}
+
+// VoterAuthMiddleware ensures that only requests with a valid voter JWT
+// from /login proceed. The voter must still be registered, and changing
+// their password ends the sessions started before.
+func VoterAuthMiddleware(next http.Handler) http.Handler {
+	return requireToken(tokenAccess, func(claims *Claims) error {
+		if claims.Role != roleVoter {
+			return errors.New("not a voter token")
+		}
+		var issuedAt time.Time
+		if claims.IssuedAt != nil {
+			issuedAt = claims.IssuedAt.Time
+		}
+		_, err := activeVoter(claims.Subject, issuedAt)
+		return err
+	}, next)
+}
+
+// EnrolmentAuthMiddleware lets through requests with the enrolment token an
+// admin without two-factor authentication gets at login, which only allows
+// enrolling.
+func EnrolmentAuthMiddleware(next http.Handler) http.Handler {
+	return requireToken(tokenEnrol, func(claims *Claims) error {
+		_, err := activeAdmin(claims.Subject)
+		return err
+	}, next)
+}
+
+// requireToken passes on requests carrying an unexpired, unrevoked token of
+// the given type that passes the check, with its claims in the request
+// context under userKey
+func requireToken(tokenType string, check func(*Claims) error, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		log.Printf("AuthMiddleware: %s %s", r.Method, r.URL.Path)
 
@@ -26,7 +72,6 @@ func AuthMiddleware(next http.Handler) http.Handler {
 		w.Header().Set("Content-Type", "application/json")
 
 		authHeader := r.Header.Get("Authorization")
-		log.Printf("AuthMiddleware: Authorization header: %s", authHeader)
 
 		if authHeader == "" {
 			log.Println("AuthMiddleware: No authorization header")
@@ -39,7 +84,6 @@ func AuthMiddleware(next http.Handler) http.Handler {
 		tokenString := ""
 		if strings.HasPrefix(authHeader, "Bearer ") {
 			tokenString = authHeader[7:]
-			log.Printf("AuthMiddleware: Extracted token: %s", tokenString)
 		} else {
 			log.Println("AuthMiddleware: Invalid authorization format")
 			w.WriteHeader(http.StatusUnauthorized)
@@ -47,22 +91,20 @@ func AuthMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		// Validate token
-		if !isValidToken(tokenString) {
-			log.Printf("AuthMiddleware: Invalid token: %s", tokenString)
+		// Validate token: a signed token of the expected type that has not
+		// expired or been revoked
+		claims, err := parseToken(tokenString, tokenType)
+		if err == nil {
+			err = check(claims)
+		}
+		if err != nil {
+			log.Printf("AuthMiddleware: Invalid token: %v", err)
 			w.WriteHeader(http.StatusUnauthorized)
 			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid or expired token"})
 			return
 		}
 
-		log.Println("AuthMiddleware: Token valid, proceeding to handler")
-		next.ServeHTTP(w, r)
+		log.Printf("AuthMiddleware: Token valid for %s, proceeding to handler", claims.Subject)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userKey, claims)))
 	})
 }
-
-// Simple token validation - replace with your actual implementation
-func isValidToken(token string) bool {
-	valid := strings.HasPrefix(token, "admin_token_") && len(token) > 15
-	log.Printf("isValidToken: %s -> %v", token, valid)
-	return valid
-}
@@ -0,0 +1,241 @@
+package server
+
+import (
+	"e-voting-blockchain/contracts"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// HandleListNominations returns the nominations, optionally filtered by
+// ?status=submitted|under_review|approved|rejected
+func HandleListNominations(w http.ResponseWriter, r *http.Request) {
+	log.Println("HandleListNominations called")
+	w.Header().Set("Content-Type", "application/json")
+
+	status := contracts.NominationStatus(r.URL.Query().Get("status"))
+
+	electionMu.RLock()
+	defer electionMu.RUnlock()
+
+	nominations := election.ListNominations(status)
+	if err := json.NewEncoder(w).Encode(nominations); err != nil {
+		log.Printf("Failed to encode nominations: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to encode nominations"})
+	}
+}
+
+// HandleGetNomination returns a single nomination
+func HandleGetNomination(w http.ResponseWriter, r *http.Request) {
+	log.Println("HandleGetNomination called")
+	w.Header().Set("Content-Type", "application/json")
+
+	electionMu.RLock()
+	defer electionMu.RUnlock()
+
+	nomination, err := election.GetNomination(mux.Vars(r)["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	json.NewEncoder(w).Encode(nomination)
+}
+
+// HandleSubmitNomination puts a nominee forward. The nomination is checked
+// against the election's rules straight away; one that breaks them is
+// recorded as rejected and returned with the violations.
+func HandleSubmitNomination(w http.ResponseWriter, r *http.Request) {
+	log.Println("HandleSubmitNomination called")
+	w.Header().Set("Content-Type", "application/json")
+
+	type Req struct {
+		CandidateID string `json:"candidateId"`
+		NomineeID   string `json:"nomineeId"`
+		Name        string `json:"name"`
+		Bio         string `json:"bio"`
+		PartyID     string `json:"partyId"`
+		Age         int    `json:"age"`
+		ImageURL    string `json:"imageUrl,omitempty"`
+	}
+
+	var req Req
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid JSON format"})
+		return
+	}
+
+	electionMu.Lock()
+	defer electionMu.Unlock()
+
+	nomination, err := election.SubmitNomination(contracts.Nomination{
+		CandidateID: req.CandidateID,
+		NomineeID:   req.NomineeID,
+		Name:        req.Name,
+		Bio:         req.Bio,
+		PartyID:     req.PartyID,
+		Age:         req.Age,
+		ImageURL:    req.ImageURL,
+	}, actor(r), time.Now())
+	if err != nil {
+		w.WriteHeader(candidateErrorStatus(err, http.StatusBadRequest))
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	// Log to blockchain; an automatic rejection is logged as its own step
+	blockchainLogger.LogNominationAction("submit", actor(r), nomination, r)
+	if nomination.Status == contracts.NominationRejected {
+		blockchainLogger.LogNominationAction("reject", "system", nomination, r)
+	}
+
+	if saveErr := election.SaveElection(); saveErr != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to save election data"})
+		return
+	}
+
+	if nomination.Status == contracts.NominationRejected {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	} else {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(nomination)
+}
+
+// HandleReviewNomination marks a nomination as under review by an officer
+func HandleReviewNomination(w http.ResponseWriter, r *http.Request) {
+	log.Println("HandleReviewNomination called")
+	w.Header().Set("Content-Type", "application/json")
+
+	electionMu.Lock()
+	defer electionMu.Unlock()
+
+	nomination, err := election.ReviewNomination(mux.Vars(r)["id"], actor(r), time.Now())
+	if err != nil {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	// Log to blockchain
+	blockchainLogger.LogNominationAction("review", actor(r), nomination, r)
+
+	if saveErr := election.SaveElection(); saveErr != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to save election data"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(nomination)
+}
+
+// HandleDecideNomination approves or rejects a nomination with a reason
+func HandleDecideNomination(w http.ResponseWriter, r *http.Request) {
+	log.Println("HandleDecideNomination called")
+	w.Header().Set("Content-Type", "application/json")
+
+	type Req struct {
+		Approve bool   `json:"approve"`
+		Reason  string `json:"reason"`
+	}
+
+	var req Req
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid JSON format"})
+		return
+	}
+
+	electionMu.Lock()
+	defer electionMu.Unlock()
+
+	nomination, err := election.DecideNomination(mux.Vars(r)["id"], req.Approve, actor(r), req.Reason, time.Now())
+	if err != nil {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	// Log to blockchain
+	action := "reject"
+	if req.Approve {
+		action = "approve"
+	}
+	blockchainLogger.LogNominationAction(action, actor(r), nomination, r)
+
+	if saveErr := election.SaveElection(); saveErr != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to save election data"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(nomination)
+}
+
+// HandleGetNominationRules returns the rules nominations are checked against
+func HandleGetNominationRules(w http.ResponseWriter, r *http.Request) {
+	log.Println("HandleGetNominationRules called")
+	w.Header().Set("Content-Type", "application/json")
+
+	electionMu.RLock()
+	defer electionMu.RUnlock()
+
+	json.NewEncoder(w).Encode(election.GetNominationRules())
+}
+
+// HandleLoadNominationRules loads the election's rules from a file in the
+// policy directory, nomination_policy.json unless another file is named
+func HandleLoadNominationRules(w http.ResponseWriter, r *http.Request) {
+	log.Println("HandleLoadNominationRules called")
+	w.Header().Set("Content-Type", "application/json")
+
+	type Req struct {
+		File string `json:"file"`
+	}
+
+	var req Req
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid JSON format"})
+			return
+		}
+	}
+
+	rules, err := storage.LoadNominationRules(req.File)
+	if err != nil {
+		log.Printf("Failed to load nomination rules: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	electionMu.Lock()
+	defer electionMu.Unlock()
+
+	if err := election.SetNominationRules(rules); err != nil {
+		w.WriteHeader(candidateErrorStatus(err, http.StatusBadRequest))
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	// Log to blockchain
+	blockchainLogger.LogNominationRules(actor(r), rules, r)
+
+	if saveErr := election.SaveElection(); saveErr != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to save election data"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "nomination rules loaded",
+		"rules":  rules,
+	})
+}
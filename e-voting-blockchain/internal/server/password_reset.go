@@ -0,0 +1,275 @@
+package server
+
+import (
+	"e-voting-blockchain/contracts"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+)
+
+// A voter who has lost their password asks for a reset with their voter ID
+// and email address, and gets a single-use link by email that lets them
+// choose a new one. A logged-in voter can change their password directly.
+// Either way every session started before the change is ended, and the voter
+// is told of the change by email.
+const (
+	// minVoterPasswordLength is the shortest password a voter may choose,
+	// the length of the ones generated at registration
+	minVoterPasswordLength = 8
+
+	passwordResetValid = 30 * time.Minute
+
+	// passwordResetWait is how soon after one reset email another may be
+	// sent for the same voter
+	passwordResetWait = time.Minute
+)
+
+var (
+	errWrongPassword = errors.New("current password is incorrect")
+	errResetTooSoon  = errors.New("a password reset was requested recently")
+)
+
+// activeVoter returns the registration of a voter whose session started at
+// the given time, if it is still valid: the voter is still registered and
+// has not changed their password since
+func activeVoter(voterID string, startedAt time.Time) (contracts.RegisteredUser, error) {
+	users, err := storage.LoadRegisteredUsers()
+	if err != nil {
+		return contracts.RegisteredUser{}, err
+	}
+	for _, user := range users {
+		if user.VoterID == voterID {
+			if !user.SessionValid(startedAt) {
+				return contracts.RegisteredUser{}, errors.New("session started before the password was changed")
+			}
+			return user, nil
+		}
+	}
+	return contracts.RegisteredUser{}, errVoterNotFound
+}
+
+// validVoterPassword checks a password a voter has chosen
+func validVoterPassword(w http.ResponseWriter, password string) bool {
+	if len(password) < minVoterPasswordLength {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Password must be at least 8 characters"})
+		return false
+	}
+	return true
+}
+
+// sendPasswordChanged tells a voter their password was changed
+func sendPasswordChanged(user contracts.RegisteredUser, at time.Time) {
+	err := mailer.send(user.Language, "password_changed", user.Email, map[string]interface{}{
+		"VoterID":   user.VoterID,
+		"ChangedAt": at.UTC().Format("2006-01-02 15:04 MST"),
+	})
+	if err != nil {
+		log.Printf("Failed to send password change notice to voter %s: %v", user.VoterID, err)
+	}
+}
+
+// HandleRequestPasswordReset emails a reset link to a voter whose voter ID
+// and email address match their registration. The answer is the same
+// whether or not they do.
+func HandleRequestPasswordReset(w http.ResponseWriter, r *http.Request) {
+	log.Println("HandleRequestPasswordReset called")
+	w.Header().Set("Content-Type", "application/json")
+
+	type Req struct {
+		VoterID string `json:"voterId"`
+		Email   string `json:"email"`
+	}
+
+	var req Req
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.VoterID == "" || req.Email == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Voter ID and email are required"})
+		return
+	}
+
+	now := time.Now()
+	var user contracts.RegisteredUser
+	var token string
+	err := updateRegistration(func(u contracts.RegisteredUser) bool {
+		return u.VoterID == req.VoterID && u.Email == req.Email
+	}, func(u *contracts.RegisteredUser) error {
+		if u.PasswordReset != nil && now.Sub(u.PasswordReset.RequestedAt) < passwordResetWait {
+			return errResetTooSoon
+		}
+		var err error
+		token, u.PasswordReset, err = contracts.NewPasswordReset(now, passwordResetValid)
+		user = *u
+		return err
+	})
+	switch {
+	case err == nil:
+		err = mailer.send(user.Language, "password_reset", user.Email, map[string]interface{}{
+			"VoterID":      user.VoterID,
+			"Link":         mailer.passwordResetLink(token),
+			"ValidMinutes": int(passwordResetValid.Minutes()),
+		})
+		if err != nil {
+			log.Printf("Failed to send password reset email to voter %s: %v", user.VoterID, err)
+			break
+		}
+
+		// Log to blockchain
+		blockchainLogger.LogPasswordEvent("reset_requested", user.VoterID, r)
+	case errors.Is(err, errVoterNotFound), errors.Is(err, errResetTooSoon):
+	default:
+		log.Printf("Failed to save password reset: %v", err)
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":  "ok",
+		"message": "If the voter ID and email match a registration, a reset link has been sent to the email address",
+	})
+}
+
+// HandleConfirmPasswordReset sets a new password for the voter a reset link
+// was sent to. The link also confirms the email address, since it reached
+// it.
+func HandleConfirmPasswordReset(w http.ResponseWriter, r *http.Request) {
+	log.Println("HandleConfirmPasswordReset called")
+	w.Header().Set("Content-Type", "application/json")
+
+	type Req struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+
+	var req Req
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Reset token and new password are required"})
+		return
+	}
+	if !validVoterPassword(w, req.Password) {
+		return
+	}
+
+	hash, err := contracts.HashPassword(req.Password)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to hash password"})
+		return
+	}
+
+	now := time.Now()
+	var user contracts.RegisteredUser
+	err = updateRegistration(func(u contracts.RegisteredUser) bool {
+		return u.PasswordReset.Matches(req.Token)
+	}, func(u *contracts.RegisteredUser) error {
+		if u.PasswordReset.Expired(now) {
+			return errLinkExpired
+		}
+		u.SetPassword(hash, now)
+		u.Verification = nil
+		user = *u
+		return nil
+	})
+	switch {
+	case errors.Is(err, errLinkExpired):
+		w.WriteHeader(http.StatusGone)
+		json.NewEncoder(w).Encode(map[string]string{"error": "This link has expired; ask for a new one"})
+		return
+	case errors.Is(err, errVoterNotFound):
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid or already used reset link"})
+		return
+	case err != nil:
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to save password"})
+		return
+	}
+
+	// Failed logins with the lost password no longer hold the voter back
+	recordLoginSuccess("voter", user.Username)
+
+	// Log to blockchain
+	blockchainLogger.LogPasswordEvent("reset", user.VoterID, r)
+
+	sendPasswordChanged(user, now)
+	json.NewEncoder(w).Encode(map[string]string{"status": "password reset", "message": "Your password has been changed; log in with the new one"})
+}
+
+// HandleChangePassword changes the password of the logged-in voter, who
+// must give their current one. Wrong guesses count as failed logins.
+func HandleChangePassword(w http.ResponseWriter, r *http.Request) {
+	log.Println("HandleChangePassword called")
+	w.Header().Set("Content-Type", "application/json")
+
+	type Req struct {
+		CurrentPassword string `json:"currentPassword"`
+		NewPassword     string `json:"newPassword"`
+	}
+
+	var req Req
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid JSON format"})
+		return
+	}
+	if !validVoterPassword(w, req.NewPassword) {
+		return
+	}
+	if req.NewPassword == req.CurrentPassword {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "New password must differ from the current one"})
+		return
+	}
+
+	claims := requestClaims(r)
+	login := throttleLogin(w, r, "voter", claims.Username, "error")
+	if login == nil {
+		return
+	}
+	defer login.release()
+	hash, err := contracts.HashPassword(req.NewPassword)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to hash password"})
+		return
+	}
+
+	now := time.Now()
+	var account contracts.RegisteredUser
+	err = updateRegisteredUser(claims.Subject, func(user *contracts.RegisteredUser) error {
+		if !contracts.VerifyPassword(user.Password, req.CurrentPassword) {
+			return errWrongPassword
+		}
+		user.SetPassword(hash, now)
+		account = *user
+		return nil
+	})
+	switch {
+	case errors.Is(err, errWrongPassword):
+		login.failed("wrong password on password change")
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Current password is incorrect"})
+		return
+	case errors.Is(err, errVoterNotFound):
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Voter not found"})
+		return
+	case err != nil:
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to save password"})
+		return
+	}
+
+	// The token this request came with may have been issued in the second
+	// of the change, so it is revoked as well
+	if err := revokeToken(claims); err != nil {
+		log.Printf("Failed to revoke voter token: %v", err)
+	}
+
+	// Log to blockchain
+	blockchainLogger.LogPasswordEvent("change", account.VoterID, r)
+
+	sendPasswordChanged(account, now)
+	json.NewEncoder(w).Encode(map[string]string{"status": "password changed", "message": "Your password has been changed; log in again with the new one"})
+}
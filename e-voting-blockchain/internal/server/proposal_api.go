@@ -0,0 +1,470 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"e-voting-blockchain/contracts"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Critical admin routes are held for approval. A request to one is kept as a
+// proposal and answered with 202; once enough other admins approve it, the
+// request is carried out as the proposer made it, by the route's own
+// handler.
+
+// approvedKey marks a request carried out for an approved proposal, holding
+// the proposal's ID
+const approvedKey key = "approvedProposal"
+
+// proposalStore is loaded on first use
+var (
+	proposalMu    sync.Mutex
+	proposalStore *contracts.ProposalStore
+
+	// proposalHandlers maps each critical action to the handler that carries
+	// it out, as registered by SetupRoutes
+	proposalHandlers = make(map[string]http.HandlerFunc)
+)
+
+// loadProposalStore returns the proposals. Callers hold proposalMu.
+func loadProposalStore() (*contracts.ProposalStore, error) {
+	if proposalStore != nil {
+		return proposalStore, nil
+	}
+	store, err := storage.LoadProposalStore()
+	if err != nil {
+		return nil, err
+	}
+	proposalStore = store
+	return proposalStore, nil
+}
+
+// critical wraps an admin handler whose action needs approval. Admins whose
+// role grants the permission may propose it, and approve it when another
+// admin proposes it.
+func critical(perm contracts.Permission, action string, next http.HandlerFunc) http.HandlerFunc {
+	return criticalWhen(perm, action, nil, next)
+}
+
+// criticalWhen is critical for routes where only some requests need
+// approval, as decided by when from the request body
+func criticalWhen(perm contracts.Permission, action string, when func(body []byte) bool, next http.HandlerFunc) http.HandlerFunc {
+	proposalHandlers[action] = next
+	return allow(perm, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "OPTIONS" || r.Context().Value(approvedKey) != nil {
+			next(w, r)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to read request"})
+			return
+		}
+		if when != nil && !when(body) {
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			next(w, r)
+			return
+		}
+		proposeAction(w, r, perm, action, body)
+	})
+}
+
+// opensOrClosesPolls reports whether a lifecycle transition request starts or
+// stops voting
+func opensOrClosesPolls(body []byte) bool {
+	var req struct {
+		State string `json:"state"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return false
+	}
+	state := contracts.ElectionState(req.State)
+	return state == contracts.StateVoting || state == contracts.StateClosed
+}
+
+// proposalApprovers returns the admins who may approve a proposal: the other
+// active admins whose role grants the permission or, when there are none,
+// all the other active admins, which anyRole reports
+func proposalApprovers(perm contracts.Permission, proposer string) (approvers []string, anyRole bool, err error) {
+	adminMu.Lock()
+	defer adminMu.Unlock()
+
+	store, err := loadAdminStore()
+	if err != nil {
+		return nil, false, err
+	}
+	var others []string
+	approvers = []string{}
+	for _, account := range store.ListAdmins() {
+		if account.Username == proposer || account.Disabled {
+			continue
+		}
+		others = append(others, account.Username)
+		if account.Role.Can(perm) {
+			approvers = append(approvers, account.Username)
+		}
+	}
+	if len(approvers) == 0 && len(others) > 0 {
+		return others, true, nil
+	}
+	return approvers, false, nil
+}
+
+// proposeAction records a critical request as a proposal. The proposer can
+// give a reason in the reason query parameter.
+func proposeAction(w http.ResponseWriter, r *http.Request, perm contracts.Permission, action string, body []byte) {
+	log.Printf("Proposing %s", action)
+	w.Header().Set("Content-Type", "application/json")
+
+	approvers, anyRole, err := proposalApprovers(perm, actor(r))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to load admin accounts"})
+		return
+	}
+
+	proposalMu.Lock()
+	store, err := loadProposalStore()
+	if err != nil {
+		proposalMu.Unlock()
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to load proposals"})
+		return
+	}
+	p, err := store.Propose(contracts.Proposal{
+		Action:     action,
+		Permission: perm,
+		Method:     r.Method,
+		Path:       r.URL.Path,
+		Vars:       mux.Vars(r),
+		Body:       string(body),
+		Reason:     r.URL.Query().Get("reason"),
+		ProposedBy: actor(r),
+
+		Approvers:        approvers,
+		ApproversAnyRole: anyRole,
+	}, time.Now())
+	if err != nil {
+		proposalMu.Unlock()
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	// Log to blockchain
+	tx := blockchainLogger.LogProposal("propose", actor(r), p, r)
+	store.RecordProposal(p.ID, tx.ID)
+	p.TransactionID = tx.ID
+	saveErr := store.Save()
+	proposalMu.Unlock()
+	if saveErr != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to save proposal"})
+		return
+	}
+
+	// Without a quorum to wait for, the request is carried out at once
+	if p.Status == contracts.ProposalApproved {
+		p = executeProposal(p, actor(r), r)
+		json.NewEncoder(w).Encode(map[string]interface{}{"status": executionStatus(p), "proposal": p})
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":   "awaiting approval",
+		"proposal": p,
+	})
+}
+
+// capturedResponse records the response of a handler carried out for a
+// proposal
+type capturedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (c *capturedResponse) Header() http.Header { return c.header }
+
+func (c *capturedResponse) WriteHeader(status int) {
+	if c.status == 0 {
+		c.status = status
+	}
+}
+
+func (c *capturedResponse) Write(b []byte) (int, error) {
+	c.WriteHeader(http.StatusOK)
+	return c.body.Write(b)
+}
+
+// executeProposal carries out an approved proposal's request as its proposer
+// made it, and records the response. The proposer must still be an active
+// admin whose role grants the action's permission, or the proposal fails.
+// The proposal must already be approved, so it is carried out only once;
+// callers do not hold proposalMu.
+func executeProposal(p contracts.Proposal, executedBy string, r *http.Request) contracts.Proposal {
+	log.Printf("Executing proposal %s (%s)", p.ID, p.Action)
+
+	failure := ""
+	rec := &capturedResponse{header: make(http.Header)}
+	account, err := activeAdmin(p.ProposedBy)
+	handler, ok := proposalHandlers[p.Action]
+	switch {
+	case err != nil:
+		failure = "The proposer's admin account is no longer active"
+	case !account.Role.Can(p.Permission):
+		failure = "The proposer's role no longer grants " + string(p.Permission)
+	case !ok:
+		log.Printf("No handler for proposal %s (%s)", p.ID, p.Action)
+		failure = "Proposed action cannot be carried out"
+	default:
+		claims := &Claims{Username: p.ProposedBy, Role: string(account.Role), TokenType: tokenAccess}
+		claims.Subject = p.ProposedBy
+		ctx := context.WithValue(context.WithValue(r.Context(), userKey, claims), approvedKey, p.ID)
+		req, err := http.NewRequestWithContext(ctx, p.Method, p.Path, strings.NewReader(p.Body))
+		if err != nil {
+			failure = "Proposed action cannot be carried out"
+			break
+		}
+		req.RemoteAddr = r.RemoteAddr
+		req.Header = r.Header.Clone()
+		handler(rec, mux.SetURLVars(req, p.Vars))
+	}
+
+	proposalMu.Lock()
+	defer proposalMu.Unlock()
+
+	store, err := loadProposalStore()
+	if err != nil {
+		log.Printf("Failed to load proposals: %v", err)
+		return p
+	}
+	if failure != "" {
+		log.Printf("Proposal %s (%s) failed: %s", p.ID, p.Action, failure)
+		p = store.RecordFailure(p.ID, executedBy, failure, time.Now())
+	} else {
+		p = store.RecordExecution(p.ID, executedBy, rec.status, rec.body.Bytes(), time.Now())
+	}
+
+	// Log to blockchain
+	blockchainLogger.LogProposal("execute", executedBy, p, r)
+
+	if err := store.Save(); err != nil {
+		log.Printf("Failed to save proposals: %v", err)
+	}
+	return p
+}
+
+// executionStatus describes the outcome of carrying out a proposal
+func executionStatus(p contracts.Proposal) string {
+	if p.Status == contracts.ProposalFailed {
+		return "proposal failed"
+	}
+	return "proposal executed"
+}
+
+// HandleListProposals returns the proposals, optionally filtered by status
+func HandleListProposals(w http.ResponseWriter, r *http.Request) {
+	log.Println("HandleListProposals called")
+	w.Header().Set("Content-Type", "application/json")
+
+	proposalMu.Lock()
+	defer proposalMu.Unlock()
+
+	store, err := loadProposalStore()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to load proposals"})
+		return
+	}
+	status := contracts.ProposalStatus(r.URL.Query().Get("status"))
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"policy":    store.Policy,
+		"proposals": store.ListProposals(status, time.Now()),
+	})
+}
+
+// HandleGetProposal returns a proposal
+func HandleGetProposal(w http.ResponseWriter, r *http.Request) {
+	log.Println("HandleGetProposal called")
+	w.Header().Set("Content-Type", "application/json")
+
+	proposalMu.Lock()
+	defer proposalMu.Unlock()
+
+	store, err := loadProposalStore()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to load proposals"})
+		return
+	}
+	p, exists := store.GetProposal(mux.Vars(r)["id"], time.Now())
+	if !exists {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Proposal not found"})
+		return
+	}
+	json.NewEncoder(w).Encode(p)
+}
+
+// HandleApproveProposal adds the admin's approval to a proposal, carrying it
+// out once the quorum is met
+func HandleApproveProposal(w http.ResponseWriter, r *http.Request) {
+	log.Println("HandleApproveProposal called")
+	w.Header().Set("Content-Type", "application/json")
+
+	proposalMu.Lock()
+	store, err := loadProposalStore()
+	if err != nil {
+		proposalMu.Unlock()
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to load proposals"})
+		return
+	}
+	id := mux.Vars(r)["id"]
+	role := contracts.AdminRole(requestClaims(r).Role)
+	p, err := store.Approve(id, actor(r), role, time.Now())
+	if err != nil {
+		proposalMu.Unlock()
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	// Log to blockchain
+	tx := blockchainLogger.LogProposal("approve", actor(r), p, r)
+	store.RecordApproval(id, tx.ID)
+	p, _ = store.GetProposal(id, time.Now())
+	saveErr := store.Save()
+	proposalMu.Unlock()
+	if saveErr != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to save proposal"})
+		return
+	}
+
+	if p.Status == contracts.ProposalApproved {
+		p = executeProposal(p, actor(r), r)
+		json.NewEncoder(w).Encode(map[string]interface{}{"status": executionStatus(p), "proposal": p})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":    "approval recorded",
+		"remaining": p.Required - len(p.Approvals),
+		"proposal":  p,
+	})
+}
+
+// HandleCancelProposal lets the proposer withdraw a pending proposal
+func HandleCancelProposal(w http.ResponseWriter, r *http.Request) {
+	log.Println("HandleCancelProposal called")
+	w.Header().Set("Content-Type", "application/json")
+
+	proposalMu.Lock()
+	defer proposalMu.Unlock()
+
+	store, err := loadProposalStore()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to load proposals"})
+		return
+	}
+	p, err := store.Cancel(mux.Vars(r)["id"], actor(r), time.Now())
+	if err != nil {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	// Log to blockchain
+	blockchainLogger.LogProposal("cancel", actor(r), p, r)
+
+	if err := store.Save(); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to save proposal"})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"status": "proposal cancelled", "proposal": p})
+}
+
+// HandleSetProposalPolicy sets how many approvals critical actions need and
+// how long a proposal stays open. Changing it is itself a critical action.
+func HandleSetProposalPolicy(w http.ResponseWriter, r *http.Request) {
+	log.Println("HandleSetProposalPolicy called")
+	w.Header().Set("Content-Type", "application/json")
+
+	type Req struct {
+		Required    int `json:"required"`
+		WindowHours int `json:"windowHours"`
+	}
+
+	var req Req
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid JSON format"})
+		return
+	}
+
+	approvers, err := otherActiveAdmins(actor(r))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to load admin accounts"})
+		return
+	}
+
+	proposalMu.Lock()
+	defer proposalMu.Unlock()
+
+	store, err := loadProposalStore()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to load proposals"})
+		return
+	}
+	policy := contracts.ProposalPolicy{Required: req.Required, WindowHours: req.WindowHours}
+	if err := store.SetPolicy(policy, approvers); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	// Log to blockchain
+	blockchainLogger.LogProposalPolicy(actor(r), policy, r)
+
+	if err := store.Save(); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to save proposal policy"})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"status": "proposal policy set", "policy": store.Policy})
+}
+
+// otherActiveAdmins counts the active admins other than the given one, the
+// most approvals a proposal of theirs could get
+func otherActiveAdmins(username string) (int, error) {
+	adminMu.Lock()
+	defer adminMu.Unlock()
+
+	store, err := loadAdminStore()
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, account := range store.Accounts {
+		if account.Username != username && !account.Disabled {
+			count++
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,154 @@
+package server
+
+import (
+	"e-voting-blockchain/contracts"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// provisionalKeyFile holds the hex key that seals provisional ballots while
+// they await review
+const provisionalKeyFile = "provisional_seal.key"
+
+// provisionalKey is loaded on first use; callers hold electionMu
+var provisionalKey []byte
+
+// loadProvisionalKey reads the provisional ballot seal key, creating one on
+// first use
+func loadProvisionalKey() ([]byte, error) {
+	if provisionalKey != nil {
+		return provisionalKey, nil
+	}
+
+	key, err := loadSecret(storage.Path(provisionalKeyFile), 32, "provisional ballot seal key")
+	if err != nil {
+		return nil, err
+	}
+	provisionalKey = key
+	return provisionalKey, nil
+}
+
+// castProvisional seals the ballot of a voter whose details could not be
+// confirmed and holds it for review. Callers hold electionMu.
+func castProvisional(w http.ResponseWriter, r *http.Request, ballot contracts.Ballot, name, dob, challenge string) {
+	key, err := loadProvisionalKey()
+	if err != nil {
+		log.Printf("Failed to load provisional seal key: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to seal provisional ballot"})
+		return
+	}
+
+	p, err := election.SubmitProvisional(ballot, name, dob, challenge, key, time.Now())
+	if errors.Is(err, contracts.ErrElectionPaused) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Voting is paused", "code": "election_paused"})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to cast provisional ballot: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	// Log to blockchain
+	blockchainLogger.LogProvisionalBallot(p, r)
+
+	if saveErr := election.SaveElection(); saveErr != nil {
+		log.Printf("Failed to save election: %v", saveErr)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to save election data"})
+		return
+	}
+
+	log.Printf("Provisional ballot %s held for review: %s", p.ID, challenge)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":        "provisional ballot accepted for review",
+		"message":       "Your ballot has been sealed and will be counted if your eligibility is confirmed",
+		"provisionalId": p.ID,
+		"challenge":     challenge,
+	})
+}
+
+// HandleListProvisionals returns the provisional ballots, optionally filtered
+// by ?status=pending|accepted|rejected. Sealed contents are never returned.
+func HandleListProvisionals(w http.ResponseWriter, r *http.Request) {
+	log.Println("HandleListProvisionals called")
+	w.Header().Set("Content-Type", "application/json")
+
+	electionMu.RLock()
+	defer electionMu.RUnlock()
+
+	list := election.ListProvisionals(contracts.ProvisionalStatus(r.URL.Query().Get("status")))
+	for i := range list {
+		list[i].Sealed = ""
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"counts":       election.ProvisionalCounts(),
+		"provisionals": list,
+	})
+}
+
+// HandleDecideProvisional accepts a provisional ballot, counting it, or
+// rejects it with a reason
+func HandleDecideProvisional(w http.ResponseWriter, r *http.Request) {
+	log.Println("HandleDecideProvisional called")
+	w.Header().Set("Content-Type", "application/json")
+
+	type Req struct {
+		Accept bool   `json:"accept"`
+		Reason string `json:"reason"`
+	}
+
+	var req Req
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid JSON format"})
+		return
+	}
+
+	electionMu.Lock()
+	defer electionMu.Unlock()
+
+	key, err := loadProvisionalKey()
+	if err != nil {
+		log.Printf("Failed to load provisional seal key: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to open provisional ballot"})
+		return
+	}
+
+	p, counted, err := election.DecideProvisional(mux.Vars(r)["id"], req.Accept, actor(r), req.Reason, key, time.Now())
+	if err != nil {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	// Log to blockchain; an accepted ballot is also logged as a vote so the
+	// recount includes it
+	blockchainLogger.LogProvisionalDecision(actor(r), p, r)
+	if counted != nil {
+		blockchainLogger.LogBallot(election, *counted, r)
+	}
+
+	if saveErr := election.SaveElection(); saveErr != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to save election data"})
+		return
+	}
+
+	p.Sealed = ""
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":      "provisional ballot " + string(p.Status),
+		"provisional": p,
+		"counts":      election.ProvisionalCounts(),
+	})
+}
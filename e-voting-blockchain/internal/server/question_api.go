@@ -0,0 +1,120 @@
+package server
+
+import (
+	"e-voting-blockchain/contracts"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// HandleListQuestions returns the question contests on the ballot
+func HandleListQuestions(w http.ResponseWriter, r *http.Request) {
+	log.Println("HandleListQuestions called")
+	w.Header().Set("Content-Type", "application/json")
+
+	electionMu.RLock()
+	defer electionMu.RUnlock()
+
+	if election == nil {
+		log.Println("Election is nil!")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Election not initialized"})
+		return
+	}
+
+	questions := election.ListQuestions()
+	if err := json.NewEncoder(w).Encode(questions); err != nil {
+		log.Printf("Failed to encode questions: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to encode questions"})
+		return
+	}
+	log.Println("HandleListQuestions completed successfully")
+}
+
+// HandleAddQuestion adds a referendum or other fixed-option question to the ballot
+func HandleAddQuestion(w http.ResponseWriter, r *http.Request) {
+	log.Println("HandleAddQuestion called")
+	w.Header().Set("Content-Type", "application/json")
+
+	type Req struct {
+		ID          string                     `json:"id"`
+		Title       string                     `json:"title"`
+		Description string                     `json:"description"`
+		Options     []contracts.QuestionOption `json:"options"`
+	}
+
+	var req Req
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid JSON format"})
+		return
+	}
+
+	electionMu.Lock()
+	defer electionMu.Unlock()
+
+	if err := election.AddQuestion(req.ID, req.Title, req.Description, req.Options); err != nil {
+		log.Printf("Failed to add question: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	// Log to blockchain
+	question, _ := election.GetQuestion(req.ID)
+	details := map[string]interface{}{
+		"description": question.Description,
+		"options":     question.Options,
+	}
+	blockchainLogger.LogQuestionAction("add", actor(r), question.ID, question.Title, details, r)
+
+	if saveErr := election.SaveElection(); saveErr != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to save election data"})
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":  "success",
+		"message": "Question added successfully",
+	})
+}
+
+// HandleDeleteQuestion removes a question from the ballot before voting starts
+func HandleDeleteQuestion(w http.ResponseWriter, r *http.Request) {
+	log.Println("HandleDeleteQuestion called")
+	w.Header().Set("Content-Type", "application/json")
+
+	id := mux.Vars(r)["id"]
+
+	electionMu.Lock()
+	defer electionMu.Unlock()
+
+	question, err := election.GetQuestion(id)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	if err := election.RemoveQuestion(id); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	// Log to blockchain
+	blockchainLogger.LogQuestionAction("delete", actor(r), id, question.Title, nil, r)
+
+	if saveErr := election.SaveElection(); saveErr != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to save election data"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"status": "question removed"})
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"e-voting-blockchain/blockchain"
+	"e-voting-blockchain/contracts"
+	"encoding/json"
+	"log"
+	"net/http"
+	"reflect"
+)
+
+// voteDetails is the ballot carried in a VOTE transaction's details. Older
+// transactions only have voterID and candidateID, and ones from before
+// elections were numbered have no election or round.
+type voteDetails struct {
+	Election    int               `json:"election"`
+	Round       int               `json:"round"`
+	VoterID     string            `json:"voterID"`
+	CandidateID string            `json:"candidateID"`
+	Choices     []string          `json:"choices"`
+	Scores      map[string]int    `json:"scores"`
+	Special     string            `json:"special"`
+	WriteIn     string            `json:"writeIn"`
+	Answers     map[string]string `json:"answers"`
+}
+
+// castIn reports whether the vote was cast in the given election and round.
+// A vote without them belongs to the first round of the first election.
+func (d voteDetails) castIn(number, round int) bool {
+	return max(d.Election, 1) == number && max(d.Round, 1) == round
+}
+
+// ballotFromTransaction rebuilds the ballot recorded by a VOTE transaction,
+// along with the details it was read from
+func ballotFromTransaction(tx blockchain.Transaction) (contracts.Ballot, voteDetails, error) {
+	// Details are typed values when logged in this process and generic JSON
+	// values when loaded from storage, so decode them through JSON
+	var d voteDetails
+	data, err := json.Marshal(tx.Data.Details)
+	if err != nil {
+		return contracts.Ballot{}, d, err
+	}
+	if err := json.Unmarshal(data, &d); err != nil {
+		return contracts.Ballot{}, d, err
+	}
+
+	ballot := contracts.Ballot{
+		VoterID: d.VoterID,
+		Choices: d.Choices,
+		Scores:  d.Scores,
+		Special: contracts.SpecialOption(d.Special),
+		WriteIn: d.WriteIn,
+		Answers: d.Answers,
+		CastAt:  tx.Data.Timestamp,
+	}
+	if ballot.VoterID == "" {
+		ballot.VoterID = tx.Data.Actor
+	}
+	if len(ballot.Choices) == 0 && ballot.Special == "" && ballot.WriteIn == "" && d.CandidateID != "" {
+		ballot.Choices = []string{d.CandidateID}
+	}
+	return ballot, d, nil
+}
+
+// HandleRecount recounts the election from the VOTE transactions on the
+// chain, independently of the stored ballots, and compares the two results.
+// Only the votes cast in the current election and round are counted.
+func HandleRecount(w http.ResponseWriter, r *http.Request) {
+	log.Println("HandleRecount called")
+	w.Header().Set("Content-Type", "application/json")
+
+	electionMu.Lock()
+	defer electionMu.Unlock()
+
+	if !election.CountsVisible() {
+		writeResultsEmbargoed(w)
+		return
+	}
+
+	txs := chain.GetTransactionsByType(blockchain.TxTypeVote)
+	ballots := make([]contracts.Ballot, 0, len(txs))
+	unreadable, otherRounds := 0, 0
+	for _, tx := range txs {
+		ballot, d, err := ballotFromTransaction(tx)
+		if err != nil {
+			log.Printf("Failed to read ballot from transaction %s: %v", tx.ID, err)
+			unreadable++
+			continue
+		}
+		if !d.castIn(election.GetNumber(), election.GetRound()) {
+			otherRounds++
+			continue
+		}
+		ballots = append(ballots, ballot)
+	}
+
+	recount, rejected := election.Recount(ballots)
+	stored := election.ContestResults()
+
+	response := map[string]interface{}{
+		"election":       election.GetNumber(),
+		"round":          election.GetRound(),
+		"ballotsOnChain": len(ballots) + unreadable,
+		"otherRounds":    otherRounds,
+		"ballotsStored":  len(election.Ballots),
+		"unreadable":     unreadable,
+		"rejected":       rejected,
+		"recount":        recount,
+		"stored":         stored,
+		"matches":        unreadable == 0 && rejected == 0 && reflect.DeepEqual(recount, stored),
+	}
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Failed to encode recount: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to encode recount"})
+	}
+}
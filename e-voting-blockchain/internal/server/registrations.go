@@ -0,0 +1,86 @@
+package server
+
+import (
+	"e-voting-blockchain/contracts"
+	"errors"
+	"sync"
+)
+
+// registrationsMu serialises changes to the registered voters. Each change
+// loads, modifies and saves the whole file, so without it two requests could
+// both read the same state and one of their changes would be lost, or a
+// single-use code or link could be used twice.
+var registrationsMu sync.Mutex
+
+var (
+	errLinkExpired     = errors.New("link has expired")
+	errVoterRegistered = errors.New("voter ID is already registered")
+	errEmailRegistered = errors.New("email is already registered")
+)
+
+// updateRegisteredUser applies a change to a registered voter and saves the
+// registrations
+func updateRegisteredUser(voterID string, update func(*contracts.RegisteredUser) error) error {
+	return updateRegistration(func(user contracts.RegisteredUser) bool { return user.VoterID == voterID }, update)
+}
+
+// updateRegistration applies a change to the first registered voter match
+// picks and saves the registrations. Nothing is saved if the change fails.
+func updateRegistration(match func(contracts.RegisteredUser) bool, update func(*contracts.RegisteredUser) error) error {
+	registrationsMu.Lock()
+	defer registrationsMu.Unlock()
+
+	users, err := storage.LoadRegisteredUsers()
+	if err != nil {
+		return err
+	}
+	for i := range users {
+		if match(users[i]) {
+			if err := update(&users[i]); err != nil {
+				return err
+			}
+			return storage.SaveRegisteredUsers(users)
+		}
+	}
+	return errVoterNotFound
+}
+
+// addRegistration saves a newly registered voter, unless their voter ID or
+// email address is registered already
+func addRegistration(user contracts.RegisteredUser) error {
+	registrationsMu.Lock()
+	defer registrationsMu.Unlock()
+
+	users, err := storage.LoadRegisteredUsers()
+	if err != nil {
+		return err
+	}
+	for _, u := range users {
+		if u.VoterID == user.VoterID {
+			return errVoterRegistered
+		}
+		if u.Email == user.Email {
+			return errEmailRegistered
+		}
+	}
+	return storage.SaveRegisteredUsers(append(users, user))
+}
+
+// removeRegistration deletes a registered voter and returns their
+// registration
+func removeRegistration(voterID string) (contracts.RegisteredUser, error) {
+	registrationsMu.Lock()
+	defer registrationsMu.Unlock()
+
+	users, err := storage.LoadRegisteredUsers()
+	if err != nil {
+		return contracts.RegisteredUser{}, err
+	}
+	for i, user := range users {
+		if user.VoterID == voterID {
+			remaining := append(users[:i:i], users[i+1:]...)
+			return user, storage.SaveRegisteredUsers(remaining)
+		}
+	}
+	return contracts.RegisteredUser{}, errVoterNotFound
+}
@@ -1,25 +1,44 @@
 package server
 
 import (
+	"e-voting-blockchain/contracts"
+	"e-voting-blockchain/internal/config"
 	"net/http"
 
 	"github.com/gorilla/mux"
 )
 
-func SetupRoutes() http.Handler {
+// SetupRoutes returns the server's handler. The server's state must have
+// been loaded by New.
+func SetupRoutes(cfg *config.Config) http.Handler {
 	r := mux.NewRouter()
 
 	// Public endpoints
 	r.HandleFunc("/register", HandleUserRegister).Methods("POST", "OPTIONS")
-	r.HandleFunc("/vote", HandleVote).Methods("POST", "OPTIONS")
+	r.HandleFunc("/verify-email", HandleVerifyEmail).Methods("GET", "OPTIONS")
+	r.HandleFunc("/verify-email/resend", HandleResendVerification).Methods("POST", "OPTIONS")
+	r.HandleFunc("/password-reset", HandleRequestPasswordReset).Methods("POST", "OPTIONS")
+	r.HandleFunc("/password-reset/confirm", HandleConfirmPasswordReset).Methods("POST", "OPTIONS")
+	r.Handle("/vote", VoterAuthMiddleware(http.HandlerFunc(HandleVote))).Methods("POST", "OPTIONS")
 	r.HandleFunc("/tally", HandleTally).Methods("GET", "OPTIONS")
 	r.HandleFunc("/candidates", HandleListCandidates).Methods("GET", "OPTIONS")
 	r.HandleFunc("/candidates/{id}", HandleGetCandidate).Methods("GET", "OPTIONS")
 	r.HandleFunc("/admin/login", HandleAdminLogin).Methods("POST", "OPTIONS")
+	r.HandleFunc("/admin/refresh", HandleRefreshToken).Methods("POST", "OPTIONS")
+	r.Handle("/admin/2fa/enrol", EnrolmentAuthMiddleware(http.HandlerFunc(HandleAdminEnrolTwoFactor))).Methods("POST", "OPTIONS")
+	r.Handle("/admin/2fa/confirm", EnrolmentAuthMiddleware(http.HandlerFunc(HandleAdminConfirmTwoFactor))).Methods("POST", "OPTIONS")
 	r.HandleFunc("/login", HandleUserLogin).Methods("POST", "OPTIONS")
+	r.Handle("/2fa/enrol", VoterAuthMiddleware(http.HandlerFunc(HandleVoterEnrolTwoFactor))).Methods("POST", "OPTIONS")
+	r.Handle("/2fa/confirm", VoterAuthMiddleware(http.HandlerFunc(HandleVoterConfirmTwoFactor))).Methods("POST", "OPTIONS")
+	r.Handle("/2fa/disable", VoterAuthMiddleware(http.HandlerFunc(HandleVoterDisableTwoFactor))).Methods("POST", "OPTIONS")
+	r.Handle("/password", VoterAuthMiddleware(http.HandlerFunc(HandleChangePassword))).Methods("POST", "OPTIONS")
 	r.HandleFunc("/parties", HandleListParties).Methods("GET", "OPTIONS")
 	r.HandleFunc("/election/status", HandleElectionStatus).Methods("GET", "OPTIONS")
 	r.HandleFunc("/election/results", HandleElectionResults).Methods("GET", "OPTIONS")
+	r.HandleFunc("/election/results/certified", HandleCertifiedResults).Methods("GET", "OPTIONS")
+	r.HandleFunc("/election/rounds", HandleListRounds).Methods("GET", "OPTIONS")
+	r.HandleFunc("/election/rounds/{round}", HandleGetRound).Methods("GET", "OPTIONS")
+	r.HandleFunc("/questions", HandleListQuestions).Methods("GET", "OPTIONS")
 
 	// Blockchain endpoints (public for transparency)
 	r.HandleFunc("/blockchain", HandleGetBlockchain).Methods("GET", "OPTIONS")
@@ -30,33 +49,93 @@ func SetupRoutes() http.Handler {
 	r.HandleFunc("/blockchain/verify", HandleVerifyBlockchain).Methods("GET", "OPTIONS")
 	r.HandleFunc("/blockchain/ws", HandleWebSocket).Methods("GET")
 
-	// Admin-only routes
+	// Admin-only routes; each declares the permission the admin's role must
+	// grant
 	admin := r.PathPrefix("/admin").Subrouter()
 	admin.Use(AuthMiddleware)
 
+	// Sessions, token signing keys and login lockouts
+	admin.HandleFunc("/logout", HandleAdminLogout).Methods("POST", "OPTIONS")
+	admin.HandleFunc("/me", allow(contracts.PermView, HandleGetCurrentAdmin)).Methods("GET", "OPTIONS")
+	admin.HandleFunc("/auth/rotate-key", allow(contracts.PermManageAdmins, HandleRotateSigningKey)).Methods("POST", "OPTIONS")
+	admin.HandleFunc("/lockouts", allow(contracts.PermManageAdmins, HandleListLockouts)).Methods("GET", "OPTIONS")
+	admin.HandleFunc("/lockouts/{kind}/{id}", allow(contracts.PermManageAdmins, HandleClearLockout)).Methods("DELETE", "OPTIONS")
+
 	// Candidate management
-	admin.HandleFunc("/candidates", HandleAddCandidate).Methods("POST", "OPTIONS")
-	admin.HandleFunc("/candidates/{id}", HandleUpdateCandidate).Methods("PUT", "OPTIONS")
-	admin.HandleFunc("/candidates/{id}", HandleDeleteCandidate).Methods("DELETE", "OPTIONS")
+	admin.HandleFunc("/candidates", allow(contracts.PermManageBallot, HandleAddCandidate)).Methods("POST", "OPTIONS")
+	admin.HandleFunc("/candidates/{id}", allow(contracts.PermManageBallot, HandleUpdateCandidate)).Methods("PUT", "OPTIONS")
+	admin.HandleFunc("/candidates/{id}", allow(contracts.PermManageBallot, HandleDeleteCandidate)).Methods("DELETE", "OPTIONS")
+	admin.HandleFunc("/candidates/{id}/status", allow(contracts.PermManageBallot, HandleSetCandidateStatus)).Methods("POST", "OPTIONS")
+
+	// Nominations
+	admin.HandleFunc("/nominations", allow(contracts.PermManageBallot, HandleSubmitNomination)).Methods("POST", "OPTIONS")
+	admin.HandleFunc("/nominations", allow(contracts.PermView, HandleListNominations)).Methods("GET", "OPTIONS")
+	admin.HandleFunc("/nominations/rules", allow(contracts.PermView, HandleGetNominationRules)).Methods("GET", "OPTIONS")
+	admin.HandleFunc("/nominations/rules", allow(contracts.PermManageBallot, HandleLoadNominationRules)).Methods("POST", "OPTIONS")
+	admin.HandleFunc("/nominations/{id}", allow(contracts.PermView, HandleGetNomination)).Methods("GET", "OPTIONS")
+	admin.HandleFunc("/nominations/{id}/review", allow(contracts.PermManageBallot, HandleReviewNomination)).Methods("POST", "OPTIONS")
+	admin.HandleFunc("/nominations/{id}/decision", allow(contracts.PermManageBallot, HandleDecideNomination)).Methods("POST", "OPTIONS")
+
+	// Write-in adjudication
+	admin.HandleFunc("/writeins", allow(contracts.PermView, HandleListWriteIns)).Methods("GET", "OPTIONS")
+	admin.HandleFunc("/writeins/adjudicate", allow(contracts.PermRunElection, HandleAdjudicateWriteIn)).Methods("POST", "OPTIONS")
+
+	// Provisional ballot review
+	admin.HandleFunc("/provisional", allow(contracts.PermView, HandleListProvisionals)).Methods("GET", "OPTIONS")
+	admin.HandleFunc("/provisional/{id}/decision", allow(contracts.PermRunElection, HandleDecideProvisional)).Methods("POST", "OPTIONS")
 
 	// Party management
-	admin.HandleFunc("/parties", HandleAddParty).Methods("POST", "OPTIONS")
-	admin.HandleFunc("/parties/{id}", HandleUpdateParty).Methods("PUT", "OPTIONS")
-	admin.HandleFunc("/parties/{id}", HandleDeleteParty).Methods("DELETE", "OPTIONS")
+	admin.HandleFunc("/parties", allow(contracts.PermManageBallot, HandleAddParty)).Methods("POST", "OPTIONS")
+	admin.HandleFunc("/parties/{id}", allow(contracts.PermManageBallot, HandleUpdateParty)).Methods("PUT", "OPTIONS")
+	admin.HandleFunc("/parties/{id}", allow(contracts.PermManageBallot, HandleDeleteParty)).Methods("DELETE", "OPTIONS")
+
+	// Ballot question management
+	admin.HandleFunc("/questions", allow(contracts.PermManageBallot, HandleAddQuestion)).Methods("POST", "OPTIONS")
+	admin.HandleFunc("/questions/{id}", allow(contracts.PermManageBallot, HandleDeleteQuestion)).Methods("DELETE", "OPTIONS")
 
 	// User/Voter management
-	admin.HandleFunc("/users", HandleAddUser).Methods("POST", "OPTIONS")
-	admin.HandleFunc("/users", HandleListUsers).Methods("GET", "OPTIONS")
-	admin.HandleFunc("/users/{id}", HandleGetUser).Methods("GET", "OPTIONS")
-	admin.HandleFunc("/users/{id}", HandleUpdateUser).Methods("PUT", "OPTIONS")
-	admin.HandleFunc("/users/{id}", HandleDeleteUser).Methods("DELETE", "OPTIONS")
-	admin.HandleFunc("/registered-voters", HandleGetRegisteredVoters).Methods("GET", "OPTIONS")
-	admin.HandleFunc("/registered-voters/{voterID}", HandleDeleteRegisteredVoter).Methods("DELETE", "OPTIONS")
+	admin.HandleFunc("/users", allow(contracts.PermManageVoters, HandleAddUser)).Methods("POST", "OPTIONS")
+	admin.HandleFunc("/users", allow(contracts.PermManageVoters, HandleListUsers)).Methods("GET", "OPTIONS")
+	admin.HandleFunc("/users/{id}", allow(contracts.PermManageVoters, HandleGetUser)).Methods("GET", "OPTIONS")
+	admin.HandleFunc("/users/{id}", allow(contracts.PermManageVoters, HandleUpdateUser)).Methods("PUT", "OPTIONS")
+	admin.HandleFunc("/users/{id}", allow(contracts.PermManageVoters, HandleDeleteUser)).Methods("DELETE", "OPTIONS")
+	admin.HandleFunc("/registered-voters", allow(contracts.PermManageVoters, HandleGetRegisteredVoters)).Methods("GET", "OPTIONS")
+	admin.HandleFunc("/registered-voters/{voterID}/2fa", allow(contracts.PermManageVoters, HandleResetVoterTwoFactor)).Methods("DELETE", "OPTIONS")
+	admin.HandleFunc("/registered-voters/{voterID}", critical(contracts.PermManageVoters, "delete_registered_voter", HandleDeleteRegisteredVoter)).Methods("DELETE", "OPTIONS")
 
 	// Election management
-	admin.HandleFunc("/election/start", HandleStartElection).Methods("POST", "OPTIONS")
-	admin.HandleFunc("/election/stop", HandleStopElection).Methods("POST", "OPTIONS")
-	admin.HandleFunc("/election/statistics", HandleElectionStatistics).Methods("GET", "OPTIONS")
+	admin.HandleFunc("/election/start", critical(contracts.PermRunElection, "start_election", HandleStartElection(cfg.Election.DefaultDuration))).Methods("POST", "OPTIONS")
+	admin.HandleFunc("/election/stop", critical(contracts.PermRunElection, "stop_election", HandleStopElection)).Methods("POST", "OPTIONS")
+	admin.HandleFunc("/election/schedule", critical(contracts.PermRunElection, "schedule_election", HandleScheduleElection)).Methods("POST", "OPTIONS")
+	admin.HandleFunc("/election/pause", allow(contracts.PermRunElection, HandlePauseElection)).Methods("POST", "OPTIONS")
+	admin.HandleFunc("/election/resume", allow(contracts.PermRunElection, HandleResumeElection)).Methods("POST", "OPTIONS")
+	admin.HandleFunc("/election/extend", allow(contracts.PermRunElection, HandleExtendElection)).Methods("POST", "OPTIONS")
+	admin.HandleFunc("/election/state", criticalWhen(contracts.PermRunElection, "change_election_state", opensOrClosesPolls, HandleElectionTransition)).Methods("POST", "OPTIONS")
+	admin.HandleFunc("/election/certify", allow(contracts.PermRunElection, HandleCertifyResults)).Methods("POST", "OPTIONS")
+	admin.HandleFunc("/election/recount", allow(contracts.PermView, HandleRecount)).Methods("GET", "OPTIONS")
+	admin.HandleFunc("/election/ties", allow(contracts.PermView, HandleListTies)).Methods("GET", "OPTIONS")
+	admin.HandleFunc("/election/ties/{contestId}/resolve", allow(contracts.PermRunElection, HandleResolveTie)).Methods("POST", "OPTIONS")
+	admin.HandleFunc("/election/runoff", allow(contracts.PermRunElection, HandleGenerateRunoff)).Methods("POST", "OPTIONS")
+	admin.HandleFunc("/election/new", allow(contracts.PermRunElection, HandleNewElection)).Methods("POST", "OPTIONS")
+	admin.HandleFunc("/election/statistics", allow(contracts.PermView, HandleElectionStatistics)).Methods("GET", "OPTIONS")
+
+	// Approval of critical actions. Starting, scheduling or stopping the
+	// election, deleting a registered voter, resetting the system and
+	// adding, changing or deleting admin accounts are wrapped in critical
+	// and wait for other admins' approval.
+	admin.HandleFunc("/proposals", allow(contracts.PermView, HandleListProposals)).Methods("GET", "OPTIONS")
+	admin.HandleFunc("/proposals/policy", critical(contracts.PermManageAdmins, "proposal_policy", HandleSetProposalPolicy)).Methods("PUT", "OPTIONS")
+	admin.HandleFunc("/proposals/{id}", allow(contracts.PermView, HandleGetProposal)).Methods("GET", "OPTIONS")
+	admin.HandleFunc("/proposals/{id}/approve", allow(contracts.PermView, HandleApproveProposal)).Methods("POST", "OPTIONS")
+	admin.HandleFunc("/proposals/{id}/cancel", allow(contracts.PermView, HandleCancelProposal)).Methods("POST", "OPTIONS")
+	admin.HandleFunc("/system/reset", critical(contracts.PermManageAdmins, "system_reset", HandleSystemReset)).Methods("POST", "OPTIONS")
+
+	// Admin accounts
+	admin.HandleFunc("/accounts", allow(contracts.PermManageAdmins, HandleListAdmins)).Methods("GET", "OPTIONS")
+	admin.HandleFunc("/accounts", criticalAccount("add_admin", HandleAddAdmin)).Methods("POST", "OPTIONS")
+	admin.HandleFunc("/accounts/{username}", criticalAccount("update_admin", HandleUpdateAdmin)).Methods("PUT", "OPTIONS")
+	admin.HandleFunc("/accounts/{username}", critical(contracts.PermManageAdmins, "delete_admin", HandleDeleteAdmin)).Methods("DELETE", "OPTIONS")
+	admin.HandleFunc("/accounts/{username}/2fa", allow(contracts.PermManageAdmins, HandleResetAdminTwoFactor)).Methods("DELETE", "OPTIONS")
 
-	return CorsMiddleware(r)
+	return CorsMiddleware(cfg.Server.CORSOrigins, r)
 }
@@ -0,0 +1,139 @@
+package server
+
+import (
+	"e-voting-blockchain/contracts"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// generateRunoff opens the next round when the certified result calls for a
+// runoff. The trigger and the link between the rounds are written to the
+// chain, the finished round is archived to its round file and the runoff
+// becomes the current election as a draft. It returns nil when no runoff is
+// needed. Callers hold electionMu and save the election afterwards.
+func generateRunoff(actor string, r *http.Request) (*contracts.RunoffTrigger, error) {
+	trigger := election.RunoffTrigger()
+	if trigger == nil {
+		return nil, nil
+	}
+
+	next, err := election.NewRunoffRound(*trigger, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	// Lots in the runoff are drawn from a new seed, committed before it opens
+	var commitment string
+	if next.GetTiePolicy() == contracts.TieByLot {
+		if commitment, err = configureTiePolicy(next, contracts.TieByLot); err != nil {
+			return nil, err
+		}
+	}
+
+	triggerTx := blockchainLogger.LogRunoffTrigger(actor, *trigger, r)
+	linkTx := blockchainLogger.LogRunoffCreated(actor, next, triggerTx.ID, r)
+	if commitment != "" {
+		blockchainLogger.LogTiePolicy(actor, contracts.TieByLot, commitment, r)
+	}
+	election.LinkRunoff(next, triggerTx.ID, linkTx.ID)
+	if err := election.SaveRound(); err != nil {
+		election.NextRound = nil
+		return nil, err
+	}
+
+	log.Printf("Runoff round %d created with candidates %v: %s", next.GetRound(), trigger.Candidates, trigger.Reason)
+	election = next
+	return trigger, nil
+}
+
+// HandleGenerateRunoff creates the runoff round for a certified election.
+// Certification does this automatically; this covers a failed attempt.
+func HandleGenerateRunoff(w http.ResponseWriter, r *http.Request) {
+	log.Println("HandleGenerateRunoff called")
+	w.Header().Set("Content-Type", "application/json")
+
+	electionMu.Lock()
+	defer electionMu.Unlock()
+
+	trigger, err := generateRunoff(actor(r), r)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	if trigger == nil {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": "The certified result does not call for a runoff"})
+		return
+	}
+
+	if saveErr := election.SaveElection(); saveErr != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to save election data"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":        "runoff created",
+		"trigger":       trigger,
+		"round":         election.GetRound(),
+		"previousRound": election.PreviousRound,
+	})
+}
+
+// HandleListRounds returns the current round and its links to the rounds
+// before and after it
+func HandleListRounds(w http.ResponseWriter, r *http.Request) {
+	log.Println("HandleListRounds called")
+	w.Header().Set("Content-Type", "application/json")
+
+	electionMu.RLock()
+	defer electionMu.RUnlock()
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"election":         election.GetNumber(),
+		"round":            election.GetRound(),
+		"state":            election.State(),
+		"previousRound":    election.PreviousRound,
+		"nextRound":        election.NextRound,
+		"previousElection": election.PreviousElection,
+	})
+}
+
+// HandleGetRound returns the result of an earlier round from its archive
+func HandleGetRound(w http.ResponseWriter, r *http.Request) {
+	log.Println("HandleGetRound called")
+	w.Header().Set("Content-Type", "application/json")
+
+	electionMu.RLock()
+	defer electionMu.RUnlock()
+
+	round, err := strconv.Atoi(mux.Vars(r)["round"])
+	if err != nil || round < 1 || round >= election.GetRound() {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Round not found; the current round is served by /election/results"})
+		return
+	}
+
+	archived, err := storage.LoadRound(election.GetNumber(), round)
+	if err != nil {
+		log.Printf("Failed to load round %d: %v", round, err)
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Round not found"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"round":          archived.GetRound(),
+		"electionStatus": archived.Status,
+		"ballot":         archived.GetBallotConfig(),
+		"contests":       archived.ContestResults(),
+		"certification":  archived.Certification,
+		"previousRound":  archived.PreviousRound,
+		"nextRound":      archived.NextRound,
+	})
+}
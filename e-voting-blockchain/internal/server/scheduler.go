@@ -0,0 +1,82 @@
+package server
+
+import (
+	"e-voting-blockchain/contracts"
+	"log"
+	"sync"
+	"time"
+)
+
+// schedulerInterval is how often the scheduler checks the voting window
+const schedulerInterval = 5 * time.Second
+
+// schedulerActor is recorded as the actor of transitions made by the scheduler
+const schedulerActor = "scheduler"
+
+// electionMu guards the election, which the scheduler changes from its own
+// goroutine. Handlers that change it hold the lock and those that only read
+// it hold the read lock.
+var electionMu sync.RWMutex
+
+// StartElectionScheduler opens and closes the election at its scheduled
+// times. It checks once immediately so an election whose window passed while
+// the server was down is brought up to date on startup. The function returned
+// stops it, waiting for a check in progress to finish.
+func StartElectionScheduler() (stop func()) {
+	quit := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		runScheduledTransitions(time.Now())
+
+		ticker := time.NewTicker(schedulerInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				runScheduledTransitions(now)
+			case <-quit:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(quit)
+		<-done
+	}
+}
+
+// runScheduledTransitions applies any due transitions, writes each one to the
+// chain and saves the election
+func runScheduledTransitions(now time.Time) {
+	electionMu.Lock()
+	defer electionMu.Unlock()
+
+	if election == nil {
+		return
+	}
+
+	transitions := election.ApplyScheduledTransitions(now)
+	if len(transitions) == 0 {
+		return
+	}
+
+	for _, t := range transitions {
+		log.Printf("Scheduler: election moved from %s to %s (%s)", t.From, t.To, t.Reason)
+		blockchainLogger.LogElectionTransition(schedulerActor, t, nil)
+	}
+
+	// Publish the final result as soon as the voting window closes
+	if election.State() == contracts.StateClosed {
+		if _, err := certifyResults(schedulerActor, nil); err != nil {
+			log.Printf("Scheduler: failed to certify results: %v", err)
+		} else if _, err := generateRunoff(schedulerActor, nil); err != nil {
+			log.Printf("Scheduler: failed to create runoff: %v", err)
+		}
+	}
+
+	if err := election.SaveElection(); err != nil {
+		log.Printf("Scheduler: failed to save election: %v", err)
+	}
+}
@@ -0,0 +1,114 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"e-voting-blockchain/internal/config"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+)
+
+// Server is the election server. New loads its state from the data directory
+// and starts its background work; Shutdown stops them and closes the data
+// cleanly.
+type Server struct {
+	http          *http.Server
+	tls           bool
+	stopScheduler func()
+}
+
+// New loads the election, blockchain, accounts and keys as configured and
+// returns a server ready to listen
+func New(cfg *config.Config) (*Server, error) {
+	if err := initialize(cfg); err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		http: &http.Server{
+			Addr:              cfg.Server.Addr,
+			Handler:           SetupRoutes(cfg),
+			ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+			ReadTimeout:       cfg.Server.ReadTimeout,
+			WriteTimeout:      cfg.Server.WriteTimeout,
+			IdleTimeout:       cfg.Server.IdleTimeout,
+		},
+		tls: cfg.Server.TLS.Enabled(),
+	}
+	if s.tls {
+		tlsConfig, err := loadTLSConfig(cfg.Server.TLS)
+		if err != nil {
+			chain.Close()
+			return nil, err
+		}
+		s.http.TLSConfig = tlsConfig
+	}
+
+	// Start WebSocket hub for real-time notifications
+	StartWebSocketHub()
+
+	// Open and close the election at its scheduled times
+	s.stopScheduler = StartElectionScheduler()
+	return s, nil
+}
+
+// loadTLSConfig reads the server certificate and, for mutual TLS, the CAs
+// client certificates must be signed by
+func loadTLSConfig(cfg config.TLSConfig) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("loading TLS certificate: %v", err)
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if cfg.ClientCAFile != "" {
+		pem, err := os.ReadFile(cfg.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading TLS client CAs: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.ClientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
+}
+
+// ListenAndServe serves requests until Shutdown is called, when it returns
+// http.ErrServerClosed
+func (s *Server) ListenAndServe() error {
+	if s.tls {
+		log.Printf("Server started on https://%s", s.http.Addr)
+		return s.http.ListenAndServeTLS("", "")
+	}
+	log.Printf("Server started on http://%s", s.http.Addr)
+	return s.http.ListenAndServe()
+}
+
+// Shutdown stops accepting requests and waits, until the context is done, for
+// those in progress to finish. It then stops the scheduler, disconnects
+// WebSocket clients, and closes the chain database once any block being
+// added has been saved.
+func (s *Server) Shutdown(ctx context.Context) error {
+	err := s.http.Shutdown(ctx)
+	if err != nil {
+		log.Printf("Requests still in progress at shutdown: %v", err)
+	}
+
+	s.stopScheduler()
+	CloseWebSockets()
+
+	if closeErr := chain.Close(); closeErr != nil {
+		err = errors.Join(err, fmt.Errorf("closing chain database: %v", closeErr))
+	}
+	return err
+}
@@ -0,0 +1,155 @@
+package server
+
+import (
+	"crypto/rand"
+	"e-voting-blockchain/contracts"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// newTieSeed draws a random seed for drawing lots
+func newTieSeed() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// configureTiePolicy sets an election's tie policy, drawing a fresh random
+// seed when lots are to be drawn
+func configureTiePolicy(e *contracts.Election, policy contracts.TiePolicy) (string, error) {
+	seed := ""
+	if policy == contracts.TieByLot {
+		var err error
+		if seed, err = newTieSeed(); err != nil {
+			return "", err
+		}
+	}
+	return e.ConfigureTiePolicy(policy, seed)
+}
+
+// resolveTie resolves a contest's tie, anchors the resolution on the chain
+// and applies it to the results. Callers hold electionMu and save the
+// election afterwards.
+func resolveTie(actor, contestID string, winners []string, reason string, r *http.Request) (contracts.TieResolution, error) {
+	res, err := election.ResolveTie(contestID, winners, actor, reason, time.Now())
+	if err != nil {
+		return contracts.TieResolution{}, err
+	}
+	tx := blockchainLogger.LogTieResolution(actor, res, r)
+	res.TransactionID = tx.ID
+	election.RecordTieResolution(res)
+
+	log.Printf("Tie in contest %s resolved by %s: winners=%v", contestID, res.Policy, res.Winners)
+	return res, nil
+}
+
+// resolvePendingTies resolves every tie the policy settles without an
+// officer: lots are drawn and runoffs recorded. Resolving a ranked count's
+// last-place tie lets the count go on, possibly to another tie, so it repeats
+// until none is left. Ties left for a manual decision are returned as an
+// error, since results cannot be certified until they are decided.
+func resolvePendingTies(actor string, r *http.Request) error {
+	for {
+		var manual []string
+		resolved := false
+		for _, c := range election.UnresolvedTies() {
+			switch c.Tie.Policy {
+			case contracts.TieByLot:
+				if _, err := resolveTie(actor, c.ContestID, nil, "drawn by lot", r); err != nil {
+					return err
+				}
+				resolved = true
+			case contracts.TieByRunoff:
+				if _, err := resolveTie(actor, c.ContestID, nil, "tied candidates go to a runoff", r); err != nil {
+					return err
+				}
+			default:
+				manual = append(manual, c.ContestID)
+			}
+		}
+		if len(manual) > 0 {
+			return fmt.Errorf("ties awaiting a manual decision in: %s", strings.Join(manual, ", "))
+		}
+		if !resolved {
+			return nil
+		}
+	}
+}
+
+// HandleListTies returns the contests with a tie for their last seats, with
+// each tie's resolution if it has one
+func HandleListTies(w http.ResponseWriter, r *http.Request) {
+	log.Println("HandleListTies called")
+	w.Header().Set("Content-Type", "application/json")
+
+	electionMu.RLock()
+	defer electionMu.RUnlock()
+
+	if !election.CountsVisible() {
+		writeResultsEmbargoed(w)
+		return
+	}
+
+	ties := []contracts.ContestResult{}
+	for _, c := range election.ContestResults() {
+		if c.Tie != nil {
+			ties = append(ties, c)
+		}
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"policy":         election.GetTiePolicy(),
+		"seedCommitment": election.TieSeedCommitment,
+		"ties":           ties,
+	})
+}
+
+// HandleResolveTie resolves a contest's tie under the election's tie policy.
+// A manual decision names the winners and gives a reason.
+func HandleResolveTie(w http.ResponseWriter, r *http.Request) {
+	log.Println("HandleResolveTie called")
+	w.Header().Set("Content-Type", "application/json")
+
+	type Req struct {
+		Winners []string `json:"winners"`
+		Reason  string   `json:"reason"`
+	}
+
+	var req Req
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid JSON format"})
+			return
+		}
+	}
+
+	electionMu.Lock()
+	defer electionMu.Unlock()
+
+	res, err := resolveTie(actor(r), mux.Vars(r)["contestId"], req.Winners, req.Reason, r)
+	if err != nil {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	if saveErr := election.SaveElection(); saveErr != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to save election data"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":     "tie resolved",
+		"resolution": res,
+	})
+}
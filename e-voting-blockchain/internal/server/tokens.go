@@ -0,0 +1,311 @@
+package server
+
+import (
+	"crypto/rand"
+	"e-voting-blockchain/contracts"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Tokens are HS256 JWTs. Each carries the ID of the key that signed it in
+// its kid header, so the signing key can be rotated while tokens signed with
+// an earlier key stay valid until they expire.
+const (
+	// Unless the keys are configured, as comma separated kid:hexsecret pairs
+	// the first of which signs new tokens, they are kept in signingKeysFile in
+	// the data directory, which is created on first start
+	signingKeysFile = "jwt_keys.json"
+
+	// maxSigningKeys is how many keys are kept for verification after a
+	// rotation, including the active one
+	maxSigningKeys = 3
+
+	revokedTokensFile = "revoked_tokens.json"
+
+	accessTokenTTL  = 15 * time.Minute
+	refreshTokenTTL = 7 * 24 * time.Hour
+	voterTokenTTL   = time.Hour
+	enrolTokenTTL   = 10 * time.Minute
+)
+
+// Token types and roles carried in the claims
+const (
+	tokenAccess  = "access"
+	tokenRefresh = "refresh"
+	tokenEnrol   = "enrol" // Only for enrolling in two-factor authentication
+
+	roleVoter = "voter"
+)
+
+// Claims are the claims of every token the server issues. The subject is the
+// admin's username or the voter's ID, and the role is the admin's role or
+// "voter", and the ID is the token's jti, used to
+// revoke it. Challenge is set on a voter token when the eligibility check at
+// login failed, leaving the voter only a provisional ballot.
+type Claims struct {
+	Username  string `json:"username"`
+	Role      string `json:"role"`
+	TokenType string `json:"typ"`
+	Challenge string `json:"challenge,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// signingKey is one key in the keyring
+type signingKey struct {
+	ID        string    `json:"kid"`
+	Secret    string    `json:"secret"` // Hex
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// tokenKeys is the keyring, newest key first. keysFromConfig marks keys set
+// by configuration, which cannot be rotated from the API.
+var (
+	tokenMu        sync.Mutex
+	tokenKeys      []signingKey
+	keysFromConfig bool
+
+	// revokedTokens maps the jti of each revoked token to when it expires,
+	// after which it no longer needs to be kept
+	revokedTokens map[string]time.Time
+)
+
+// loadSigningKeys takes the keyring from the configured keys, or else reads
+// it from its file, creating the file with a new key if there is none
+func loadSigningKeys(configured string) error {
+	tokenMu.Lock()
+	defer tokenMu.Unlock()
+
+	if configured = strings.TrimSpace(configured); configured != "" {
+		keys, err := parseSigningKeys(configured)
+		if err != nil {
+			return err
+		}
+		tokenKeys, keysFromConfig = keys, true
+		log.Printf("Loaded %d token signing key(s) from the configuration", len(keys))
+		return nil
+	}
+
+	data, err := storage.ReadFile(signingKeysFile)
+	switch {
+	case err == nil:
+		var keys []signingKey
+		if err := json.Unmarshal(data, &keys); err != nil || len(keys) == 0 {
+			return fmt.Errorf("invalid token signing keys in %s", signingKeysFile)
+		}
+		tokenKeys = keys
+		return nil
+	case os.IsNotExist(err):
+		key, err := newSigningKey()
+		if err != nil {
+			return err
+		}
+		tokenKeys = []signingKey{key}
+		log.Printf("Created token signing key %s in %s", key.ID, signingKeysFile)
+		return saveSigningKeys()
+	default:
+		return err
+	}
+}
+
+func parseSigningKeys(configured string) ([]signingKey, error) {
+	var keys []signingKey
+	for _, entry := range strings.Split(configured, ",") {
+		kid, secret, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if raw, err := hex.DecodeString(secret); !ok || kid == "" || err != nil || len(raw) < 32 {
+			return nil, errors.New("signing keys must be kid:hexsecret with a secret of at least 32 bytes")
+		}
+		keys = append(keys, signingKey{ID: kid, Secret: secret})
+	}
+	return keys, nil
+}
+
+func newSigningKey() (signingKey, error) {
+	buf := make([]byte, 40)
+	if _, err := rand.Read(buf); err != nil {
+		return signingKey{}, err
+	}
+	return signingKey{
+		ID:        hex.EncodeToString(buf[:8]),
+		Secret:    hex.EncodeToString(buf[8:]),
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+func saveSigningKeys() error {
+	data, err := json.MarshalIndent(tokenKeys, "", "  ")
+	if err != nil {
+		return err
+	}
+	return storage.WriteFile(signingKeysFile, data, 0600)
+}
+
+// rotateSigningKey makes a new key the signing key. Earlier keys still
+// verify tokens until they drop out of the keyring.
+func rotateSigningKey() (signingKey, error) {
+	tokenMu.Lock()
+	defer tokenMu.Unlock()
+
+	if keysFromConfig {
+		return signingKey{}, errors.New("signing keys are set by the configuration; rotate them there")
+	}
+	key, err := newSigningKey()
+	if err != nil {
+		return signingKey{}, err
+	}
+	tokenKeys = append([]signingKey{key}, tokenKeys...)
+	if len(tokenKeys) > maxSigningKeys {
+		tokenKeys = tokenKeys[:maxSigningKeys]
+	}
+	if err := saveSigningKeys(); err != nil {
+		return signingKey{}, err
+	}
+	return key, nil
+}
+
+// issueToken signs a token for the claims, filling in its jti and lifetime
+func issueToken(claims *Claims, ttl time.Duration) (string, error) {
+	tokenMu.Lock()
+	if len(tokenKeys) == 0 {
+		tokenMu.Unlock()
+		return "", errors.New("no token signing key loaded")
+	}
+	key := tokenKeys[0]
+	tokenMu.Unlock()
+
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", err
+	}
+	now := time.Now()
+	claims.ID = hex.EncodeToString(jti)
+	claims.IssuedAt = jwt.NewNumericDate(now)
+	claims.ExpiresAt = jwt.NewNumericDate(now.Add(ttl))
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["kid"] = key.ID
+	secret, _ := hex.DecodeString(key.Secret)
+	return token.SignedString(secret)
+}
+
+// issueAdminTokens returns a fresh access and refresh token pair
+func issueAdminTokens(account contracts.AdminAccount) (map[string]interface{}, error) {
+	claims := &Claims{Username: account.Username, Role: string(account.Role), TokenType: tokenAccess}
+	claims.Subject = account.Username
+	access, err := issueToken(claims, accessTokenTTL)
+	if err != nil {
+		return nil, err
+	}
+	refreshClaims := &Claims{Username: account.Username, Role: string(account.Role), TokenType: tokenRefresh}
+	refreshClaims.Subject = account.Username
+	refresh, err := issueToken(refreshClaims, refreshTokenTTL)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"token":        access,
+		"refreshToken": refresh,
+		"expiresAt":    claims.ExpiresAt.Time,
+	}, nil
+}
+
+// parseToken verifies a token's signature, expiry, type and revocation
+func parseToken(tokenString, tokenType string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		tokenMu.Lock()
+		defer tokenMu.Unlock()
+		for _, key := range tokenKeys {
+			if key.ID == kid {
+				return hex.DecodeString(key.Secret)
+			}
+		}
+		return nil, errors.New("unknown signing key")
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, err
+	}
+	if claims.TokenType != tokenType {
+		return nil, fmt.Errorf("expected a %s token", tokenType)
+	}
+	if claims.ID == "" || isTokenRevoked(claims.ID) {
+		return nil, errTokenRevoked
+	}
+	return claims, nil
+}
+
+// loadRevokedTokens reads the revocation list
+func loadRevokedTokens() error {
+	tokenMu.Lock()
+	defer tokenMu.Unlock()
+
+	revokedTokens = make(map[string]time.Time)
+	data, err := storage.ReadFile(revokedTokensFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &revokedTokens)
+}
+
+// errTokenRevoked is returned for a token on the revocation list
+var errTokenRevoked = errors.New("token has been revoked")
+
+// revokeToken adds a token to the revocation list until it expires, dropping
+// entries for tokens that have expired since
+func revokeToken(claims *Claims) error {
+	tokenMu.Lock()
+	defer tokenMu.Unlock()
+	return revokeTokenLocked(claims)
+}
+
+// consumeToken revokes a single-use token, returning errTokenRevoked if it
+// has been used already. The check and the revocation are made under one
+// lock so two requests cannot both use the token.
+func consumeToken(claims *Claims) error {
+	tokenMu.Lock()
+	defer tokenMu.Unlock()
+
+	if _, revoked := revokedTokens[claims.ID]; revoked {
+		return errTokenRevoked
+	}
+	return revokeTokenLocked(claims)
+}
+
+// revokeTokenLocked does the work of revokeToken; callers hold tokenMu
+func revokeTokenLocked(claims *Claims) error {
+	if revokedTokens == nil {
+		revokedTokens = make(map[string]time.Time)
+	}
+	now := time.Now()
+	for jti, exp := range revokedTokens {
+		if exp.Before(now) {
+			delete(revokedTokens, jti)
+		}
+	}
+	revokedTokens[claims.ID] = claims.ExpiresAt.Time
+
+	data, err := json.MarshalIndent(revokedTokens, "", "  ")
+	if err != nil {
+		return err
+	}
+	return storage.WriteFile(revokedTokensFile, data, 0600)
+}
+
+func isTokenRevoked(jti string) bool {
+	tokenMu.Lock()
+	defer tokenMu.Unlock()
+	_, revoked := revokedTokens[jti]
+	return revoked
+}
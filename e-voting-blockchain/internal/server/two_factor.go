@@ -0,0 +1,358 @@
+package server
+
+import (
+	"e-voting-blockchain/contracts"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Two-factor authentication is mandatory for admins: until an admin has
+// enrolled, logging in only gives an enrolment token. Voters may enrol, and
+// must then give a code at every login.
+
+// totpIssuer names the service in authenticator apps
+const totpIssuer = "DeVote"
+
+var (
+	errTwoFactorRequired = errors.New("two-factor code required")
+	errInvalidTwoFactor  = errors.New("invalid two-factor code")
+	errNotEnrolled       = errors.New("two-factor authentication is not enabled")
+	errVoterNotFound     = errors.New("voter not found")
+)
+
+// checkSecondFactor checks the code, or failing that the recovery code, an
+// enrolled account gives at login. The enrolment is updated with the used
+// code, so the caller saves it.
+func checkSecondFactor(tf *contracts.TwoFactor, account, code, recoveryCode string, r *http.Request) error {
+	switch {
+	case code != "":
+		if tf.VerifyCode(code, time.Now()) {
+			return nil
+		}
+	case recoveryCode != "":
+		if tf.UseRecoveryCode(recoveryCode) {
+			// Log to blockchain
+			blockchainLogger.LogTwoFactor("recovery", account, account, map[string]interface{}{
+				"remainingCodes": len(tf.RecoveryCodes),
+			}, r)
+			return nil
+		}
+	default:
+		return errTwoFactorRequired
+	}
+	return errInvalidTwoFactor
+}
+
+// adminSecondFactor checks an enrolled admin's second factor at login
+func adminSecondFactor(username, code, recoveryCode string, r *http.Request) error {
+	adminMu.Lock()
+	defer adminMu.Unlock()
+
+	store, err := loadAdminStore()
+	if err != nil {
+		return err
+	}
+	account, _ := store.GetAdmin(username)
+	if err := checkSecondFactor(account.TwoFactor, username, code, recoveryCode, r); err != nil {
+		return err
+	}
+	if err := store.SetTwoFactor(username, account.TwoFactor); err != nil {
+		return err
+	}
+	return store.Save()
+}
+
+// writeTwoFactorError answers a failed two-factor step
+func writeTwoFactorError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errTwoFactorRequired):
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]interface{}{"error": "Two-factor code required", "twoFactorRequired": true})
+	case errors.Is(err, errInvalidTwoFactor):
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid two-factor code"})
+	case errors.Is(err, errNotEnrolled):
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Two-factor authentication is not enabled"})
+	case errors.Is(err, errVoterNotFound):
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Voter not found"})
+	default:
+		log.Printf("Two-factor authentication failed: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to check two-factor code"})
+	}
+}
+
+// HandleAdminEnrolTwoFactor starts an admin's TOTP enrolment, returning the
+// secret and the otpauth URI for the authenticator app. Starting again
+// before confirming replaces the secret.
+func HandleAdminEnrolTwoFactor(w http.ResponseWriter, r *http.Request) {
+	log.Println("HandleAdminEnrolTwoFactor called")
+	w.Header().Set("Content-Type", "application/json")
+
+	adminMu.Lock()
+	defer adminMu.Unlock()
+
+	store, err := loadAdminStore()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to load admin accounts"})
+		return
+	}
+	username := actor(r)
+	account, _ := store.GetAdmin(username)
+	if account.TwoFactor.Enabled() {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+	tf, err := contracts.NewTwoFactor(time.Now())
+	if err == nil {
+		err = store.SetTwoFactor(username, tf)
+	}
+	if err == nil {
+		err = store.Save()
+	}
+	if err != nil {
+		log.Printf("Failed to start two-factor enrolment: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to start two-factor enrolment"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{
+		"secret":     tf.Secret,
+		"otpauthUri": contracts.TOTPURI(totpIssuer, username, tf.Secret),
+	})
+}
+
+// HandleAdminConfirmTwoFactor completes an admin's enrolment with the first
+// code from the app. The enrolment token is spent, and the admin gets a
+// session and recovery codes.
+func HandleAdminConfirmTwoFactor(w http.ResponseWriter, r *http.Request) {
+	log.Println("HandleAdminConfirmTwoFactor called")
+	w.Header().Set("Content-Type", "application/json")
+
+	type Req struct {
+		Code string `json:"code"`
+	}
+
+	var req Req
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid JSON format"})
+		return
+	}
+
+	adminMu.Lock()
+	store, err := loadAdminStore()
+	if err != nil {
+		adminMu.Unlock()
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to load admin accounts"})
+		return
+	}
+	username := actor(r)
+	account, _ := store.GetAdmin(username)
+	codes, err := account.TwoFactor.Confirm(req.Code, time.Now())
+	if err != nil {
+		adminMu.Unlock()
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	store.SetTwoFactor(username, account.TwoFactor)
+	saveErr := store.Save()
+	adminMu.Unlock()
+	if saveErr != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to save two-factor enrolment"})
+		return
+	}
+
+	// Log to blockchain
+	blockchainLogger.LogTwoFactor("enrol", username, username, map[string]interface{}{"accountType": "admin"}, r)
+
+	if err := revokeToken(requestClaims(r)); err != nil {
+		log.Printf("Failed to revoke enrolment token: %v", err)
+	}
+	response, err := issueAdminTokens(account)
+	if err != nil {
+		log.Printf("Failed to issue admin tokens: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to issue token"})
+		return
+	}
+	response["recoveryCodes"] = codes
+	response["message"] = "Two-factor authentication enabled; store the recovery codes safely"
+	json.NewEncoder(w).Encode(response)
+}
+
+// HandleResetAdminTwoFactor removes an admin's enrolment, for an admin who
+// has lost their authenticator and recovery codes. They enrol again at
+// their next login.
+func HandleResetAdminTwoFactor(w http.ResponseWriter, r *http.Request) {
+	log.Println("HandleResetAdminTwoFactor called")
+	w.Header().Set("Content-Type", "application/json")
+
+	adminMu.Lock()
+	defer adminMu.Unlock()
+
+	store, err := loadAdminStore()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to load admin accounts"})
+		return
+	}
+	username := mux.Vars(r)["username"]
+	if err := store.SetTwoFactor(username, nil); err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	if err := store.Save(); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to save admin accounts"})
+		return
+	}
+
+	// Log to blockchain
+	blockchainLogger.LogTwoFactor("reset", actor(r), username, map[string]interface{}{"accountType": "admin"}, r)
+
+	json.NewEncoder(w).Encode(map[string]string{"status": "two-factor authentication reset", "username": username})
+}
+
+// HandleVoterEnrolTwoFactor starts a voter's TOTP enrolment
+func HandleVoterEnrolTwoFactor(w http.ResponseWriter, r *http.Request) {
+	log.Println("HandleVoterEnrolTwoFactor called")
+	w.Header().Set("Content-Type", "application/json")
+
+	claims := requestClaims(r)
+	var tf *contracts.TwoFactor
+	err := updateRegisteredUser(claims.Subject, func(user *contracts.RegisteredUser) error {
+		if user.TwoFactor.Enabled() {
+			return errors.New("two-factor authentication is already enabled")
+		}
+		var err error
+		tf, err = contracts.NewTwoFactor(time.Now())
+		user.TwoFactor = tf
+		return err
+	})
+	if err != nil {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{
+		"secret":     tf.Secret,
+		"otpauthUri": contracts.TOTPURI(totpIssuer, claims.Username, tf.Secret),
+	})
+}
+
+// HandleVoterConfirmTwoFactor completes a voter's enrolment with the first
+// code from the app and returns the recovery codes
+func HandleVoterConfirmTwoFactor(w http.ResponseWriter, r *http.Request) {
+	log.Println("HandleVoterConfirmTwoFactor called")
+	w.Header().Set("Content-Type", "application/json")
+
+	type Req struct {
+		Code string `json:"code"`
+	}
+
+	var req Req
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid JSON format"})
+		return
+	}
+
+	voterID := requestClaims(r).Subject
+	var codes []string
+	err := updateRegisteredUser(voterID, func(user *contracts.RegisteredUser) error {
+		var err error
+		codes, err = user.TwoFactor.Confirm(req.Code, time.Now())
+		return err
+	})
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	// Log to blockchain
+	blockchainLogger.LogTwoFactor("enrol", voterID, voterID, map[string]interface{}{"accountType": "voter"}, r)
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":        "two-factor authentication enabled",
+		"recoveryCodes": codes,
+	})
+}
+
+// HandleVoterDisableTwoFactor turns off a voter's two-factor authentication,
+// given a current code or a recovery code
+func HandleVoterDisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	log.Println("HandleVoterDisableTwoFactor called")
+	w.Header().Set("Content-Type", "application/json")
+
+	type Req struct {
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recoveryCode"`
+	}
+
+	var req Req
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid JSON format"})
+		return
+	}
+
+	voterID := requestClaims(r).Subject
+	err := updateRegisteredUser(voterID, func(user *contracts.RegisteredUser) error {
+		if !user.TwoFactor.Enabled() {
+			return errNotEnrolled
+		}
+		if err := checkSecondFactor(user.TwoFactor, voterID, req.Code, req.RecoveryCode, r); err != nil {
+			return err
+		}
+		user.TwoFactor = nil
+		return nil
+	})
+	if err != nil {
+		writeTwoFactorError(w, err)
+		return
+	}
+
+	// Log to blockchain
+	blockchainLogger.LogTwoFactor("reset", voterID, voterID, map[string]interface{}{"accountType": "voter"}, r)
+
+	json.NewEncoder(w).Encode(map[string]string{"status": "two-factor authentication disabled"})
+}
+
+// HandleResetVoterTwoFactor removes a voter's enrolment, for a voter who has
+// lost their authenticator and recovery codes
+func HandleResetVoterTwoFactor(w http.ResponseWriter, r *http.Request) {
+	log.Println("HandleResetVoterTwoFactor called")
+	w.Header().Set("Content-Type", "application/json")
+
+	voterID := mux.Vars(r)["voterID"]
+	err := updateRegisteredUser(voterID, func(user *contracts.RegisteredUser) error {
+		user.TwoFactor = nil
+		return nil
+	})
+	if err != nil {
+		writeTwoFactorError(w, err)
+		return
+	}
+
+	// Log to blockchain
+	blockchainLogger.LogTwoFactor("reset", actor(r), voterID, map[string]interface{}{"accountType": "voter"}, r)
+
+	json.NewEncoder(w).Encode(map[string]string{"status": "two-factor authentication reset", "voterID": voterID})
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"e-voting-blockchain/contracts"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+)
+
+// HandleListWriteIns returns the write-in adjudication queue, optionally
+// filtered by ?status=pending|mapped|invalid. Ballot counts per spelling are
+// withheld while results are embargoed.
+func HandleListWriteIns(w http.ResponseWriter, r *http.Request) {
+	log.Println("HandleListWriteIns called")
+	w.Header().Set("Content-Type", "application/json")
+
+	electionMu.RLock()
+	defer electionMu.RUnlock()
+
+	queue := election.WriteInQueue(contracts.WriteInStatus(r.URL.Query().Get("status")))
+	if !election.CountsVisible() {
+		for i := range queue {
+			queue[i].Ballots = 0
+		}
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"allowWriteIns": election.AllowWriteIns,
+		"pending":       election.PendingWriteIns(),
+		"writeIns":      queue,
+	})
+}
+
+// HandleAdjudicateWriteIn maps a write-in spelling onto a registered
+// candidate, or marks it invalid with a reason when no candidate is named
+func HandleAdjudicateWriteIn(w http.ResponseWriter, r *http.Request) {
+	log.Println("HandleAdjudicateWriteIn called")
+	w.Header().Set("Content-Type", "application/json")
+
+	type Req struct {
+		Text        string `json:"text"`
+		CandidateID string `json:"candidateId"`
+		Reason      string `json:"reason"`
+	}
+
+	var req Req
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid JSON format"})
+		return
+	}
+
+	electionMu.Lock()
+	defer electionMu.Unlock()
+
+	decision, err := election.AdjudicateWriteIn(req.Text, req.CandidateID, req.Reason, actor(r), time.Now())
+	if err != nil {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	// Log to blockchain
+	ballots := 0
+	for _, entry := range election.WriteInQueue("") {
+		if entry.Text == decision.Text {
+			ballots = entry.Ballots
+		}
+	}
+	tx := blockchainLogger.LogWriteInAdjudication(actor(r), decision, ballots, r)
+	decision.TransactionID = tx.ID
+	election.RecordWriteIn(decision)
+
+	if saveErr := election.SaveElection(); saveErr != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to save election data"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":   "write-in adjudicated",
+		"decision": decision,
+		"pending":  election.PendingWriteIns(),
+	})
+}